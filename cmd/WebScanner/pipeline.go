@@ -0,0 +1,537 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Marryname/WebScanner/internal/alive"
+	"github.com/Marryname/WebScanner/internal/cdn"
+	"github.com/Marryname/WebScanner/internal/dnsscan"
+	"github.com/Marryname/WebScanner/internal/fingerprint"
+	"github.com/Marryname/WebScanner/internal/pipeline"
+	"github.com/Marryname/WebScanner/internal/portscan"
+	"github.com/Marryname/WebScanner/internal/subdomain"
+	"github.com/Marryname/WebScanner/internal/tlsscan"
+	"github.com/Marryname/WebScanner/internal/vulnscan"
+	"github.com/Marryname/WebScanner/pkg/common"
+	"github.com/Marryname/WebScanner/pkg/utils"
+	"github.com/spf13/viper"
+)
+
+// targetReport 单个目标的扫描结果
+type targetReport struct {
+	Ports           []portscan.ScanResult    `json:"ports,omitempty"`
+	Subdomains      []subdomain.Result       `json:"subdomains,omitempty"`
+	Wildcards       []subdomain.Wildcard     `json:"wildcards,omitempty"`
+	Filtered        []subdomain.Filtered     `json:"filtered_subdomains,omitempty"`
+	DNS             *dnsscan.ScanResult      `json:"dns,omitempty"`
+	CDN             *cdn.CDNInfo             `json:"cdn,omitempty"`
+	Alive           *alive.DetectResult      `json:"alive,omitempty"`
+	Services        []fingerprint.ScanResult `json:"services,omitempty"`
+	TLS             []tlsscan.ScanResult     `json:"tls,omitempty"`
+	Vulnerabilities []vulnscan.VulnResult    `json:"vulnerabilities,omitempty"`
+	Skipped         []common.Result          `json:"skipped,omitempty"`
+
+	// mu 保护 Skipped，多个阶段会同时记录跳过的项目
+	mu sync.Mutex
+}
+
+// skip 记录因命中排除列表而跳过的项目
+func (r *targetReport) skip(target, reason string) {
+	r.mu.Lock()
+	r.Skipped = append(r.Skipped, common.NewSkippedResult(target, reason))
+	r.mu.Unlock()
+}
+
+// excludedName 判断发现的名称是否应跳过，返回跳过的原因：名称本身被排除，
+// 或者名称解析出的地址全部被排除
+func excludedName(result subdomain.Result) (string, bool) {
+	if excludeList.Contains(result.Name) {
+		return "excluded target", true
+	}
+
+	var ips []net.IP
+	for _, addr := range append(append([]string{}, result.A...), result.AAAA...) {
+		if ip := net.ParseIP(addr); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	if _, err := excludeList.FilterIPs(result.Name, ips); err != nil {
+		return "excluded address", true
+	}
+	return "", false
+}
+
+// 各模块在流水线中的阶段名称
+var stageNames = map[string]string{
+	"port":      "端口扫描",
+	"subdomain": "子域名发现",
+	"dns":       "DNS记录",
+	"cdn":       "CDN检测",
+	"alive":     "存活探测",
+	"finger":    "服务识别",
+	"tls":       "TLS检测",
+	"vuln":      "漏洞扫描",
+}
+
+// runScanModules 为目标构建扫描流水线并运行，各模块通过事件总线传递结果，
+// 下游模块在上游结果产生时立即开始处理
+func runScanModules(ctx context.Context, target string) (*targetReport, error) {
+	bus := pipeline.NewBus()
+	p := pipeline.New(bus.Topics()...)
+	report := &targetReport{}
+
+	// 收集器需要先于其他阶段订阅，保证不错过任何事件
+	p.Stage("collect", collectStage(bus, report))
+
+	if shouldRunModule("port") {
+		p.Stage(stageNames["port"], portStage(target, bus, report), &bus.Ports)
+	}
+	if shouldRunModule("subdomain") {
+		p.Stage(stageNames["subdomain"], subdomainStage(target, bus, report), &bus.Subdomains)
+	}
+	if shouldRunModule("dns") {
+		p.Stage(stageNames["dns"], dnsStage(target, bus), &bus.DNS)
+	}
+	if shouldRunModule("cdn") {
+		p.Stage(stageNames["cdn"], cdnStage(target, bus), &bus.CDN)
+	}
+	if shouldRunModule("alive") {
+		p.Stage(stageNames["alive"], aliveStage(target, bus), &bus.Alive)
+	}
+	if shouldRunModule("finger") {
+		p.Stage(stageNames["finger"], fingerprintStage(target, bus, shouldRunModule("port")), &bus.Services)
+	}
+	if shouldRunModule("tls") {
+		// 证书中的域名同样作为子域名发布
+		p.Stage(stageNames["tls"], tlsStage(target, bus, report, shouldRunModule("port")), &bus.TLS, &bus.Subdomains)
+	}
+	if shouldRunModule("vuln") {
+		p.Stage(stageNames["vuln"], vulnStage(target, bus), &bus.Findings)
+	}
+
+	log.Info("执行模块: %s", activeModules())
+	return report, p.Run(ctx)
+}
+
+// activeModules 返回本次启用的模块名称
+func activeModules() []string {
+	var names []string
+	for _, m := range []string{"port", "subdomain", "dns", "cdn", "alive", "finger", "tls", "vuln"} {
+		if shouldRunModule(m) {
+			names = append(names, stageNames[m])
+		}
+	}
+	return names
+}
+
+// collectStage 订阅全部主题并汇总为目标报告
+func collectStage(bus *pipeline.Bus, report *targetReport) func(ctx context.Context) error {
+	ports := bus.Ports.Subscribe()
+	aliveResults := bus.Alive.Subscribe()
+	subdomains := bus.Subdomains.Subscribe()
+	dnsResults := bus.DNS.Subscribe()
+	cdnResults := bus.CDN.Subscribe()
+	services := bus.Services.Subscribe()
+	tlsResults := bus.TLS.Subscribe()
+	findings := bus.Findings.Subscribe()
+
+	return func(ctx context.Context) error {
+		seen := make(map[string]int)
+		for ports != nil || aliveResults != nil || subdomains != nil || dnsResults != nil ||
+			cdnResults != nil || services != nil || tlsResults != nil || findings != nil {
+			select {
+			case ev, ok := <-ports:
+				if !ok {
+					ports = nil
+					continue
+				}
+				report.Ports = append(report.Ports, ev.Result)
+			case ev, ok := <-aliveResults:
+				if !ok {
+					aliveResults = nil
+					continue
+				}
+				report.Alive = ev.Result
+			case ev, ok := <-subdomains:
+				if !ok {
+					subdomains = nil
+					continue
+				}
+				// 子域名可能同时来自子域名发现和证书，合并来源并保留解析记录
+				if i, ok := seen[ev.Result.Name]; !ok {
+					seen[ev.Result.Name] = len(report.Subdomains)
+					report.Subdomains = append(report.Subdomains, ev.Result)
+				} else {
+					report.Subdomains[i].Merge(ev.Result)
+				}
+			case ev, ok := <-dnsResults:
+				if !ok {
+					dnsResults = nil
+					continue
+				}
+				report.DNS = ev.Result
+			case ev, ok := <-cdnResults:
+				if !ok {
+					cdnResults = nil
+					continue
+				}
+				report.CDN = ev.Info
+			case ev, ok := <-services:
+				if !ok {
+					services = nil
+					continue
+				}
+				report.Services = append(report.Services, ev.Result)
+			case ev, ok := <-tlsResults:
+				if !ok {
+					tlsResults = nil
+					continue
+				}
+				report.TLS = append(report.TLS, ev.Result)
+			case ev, ok := <-findings:
+				if !ok {
+					findings = nil
+					continue
+				}
+				report.Vulnerabilities = append(report.Vulnerabilities, ev.Result)
+			}
+		}
+
+		// 并发产生的结果按端口排序，保证报告稳定
+		sort.Slice(report.Ports, func(i, j int) bool {
+			return report.Ports[i].Port < report.Ports[j].Port
+		})
+		sort.Slice(report.Services, func(i, j int) bool {
+			if report.Services[i].Port != report.Services[j].Port {
+				return report.Services[i].Port < report.Services[j].Port
+			}
+			return report.Services[i].IP < report.Services[j].IP
+		})
+		sort.Slice(report.TLS, func(i, j int) bool {
+			return report.TLS[i].Port < report.TLS[j].Port
+		})
+		sort.Slice(report.Subdomains, func(i, j int) bool {
+			return report.Subdomains[i].Name < report.Subdomains[j].Name
+		})
+		return nil
+	}
+}
+
+// portStage 端口扫描，每个端口完成后立即发布
+func portStage(target string, bus *pipeline.Bus, report *targetReport) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		log.Info("执行端口扫描...")
+		scanner := portscan.NewPortScanner(target, ports, time.Duration(timeout)*time.Second, threads)
+		scanner.SetRetries(viper.GetInt("scanner.retry"))
+		scanner.SetRateLimiter(rateLimiter)
+		scanner.SetExcludeList(excludeList)
+		if targetPool != nil {
+			scanner.SetResolverPool(targetPool)
+		}
+		if err := scanner.SetScanType(scanType); err != nil {
+			return err
+		}
+		// 发布失败时停止扫描，回调串行执行，无需加锁
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var publishErr error
+		scanner.OnResult(func(result portscan.ScanResult) {
+			if publishErr != nil {
+				return
+			}
+			if err := bus.Ports.Publish(ctx, pipeline.PortEvent{Target: target, Result: result}); err != nil {
+				publishErr = err
+				cancel()
+			}
+		})
+
+		// 记录被排除的端口
+		for _, port := range skippedPorts {
+			report.skip(net.JoinHostPort(target, strconv.Itoa(port)), "excluded port")
+		}
+
+		_, err := scanner.Scan(ctx)
+		if publishErr != nil {
+			return publishErr
+		}
+		return err
+	}
+}
+
+// subdomainStage 子域名发现，合并字典爆破和被动数据源的结果，目标为IP时跳过；
+// 检测到的泛解析和被过滤的子域名直接记入报告，命中排除列表的子域名记为跳过，出错的数据源只记录警告
+func subdomainStage(target string, bus *pipeline.Bus, report *targetReport) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if net.ParseIP(target) != nil {
+			log.Debug("目标 %s 为IP地址，跳过子域名发现", target)
+			return nil
+		}
+
+		log.Info("执行子域名发现...")
+		finder := subdomain.NewFinder(target)
+		finder.SetWordlist(subdomainWords)
+		finder.SetSources(subdomainSources)
+		if err := finder.SetTechniques(viper.GetStringSlice("subdomain.techniques")); err != nil {
+			return err
+		}
+		finder.SetPermutations(viper.GetBool("subdomain.permutations"))
+		finder.SetRecursion(viper.GetInt("subdomain.recursion_depth"))
+		finder.SetResolverPool(resolverPool)
+		finder.SetTimeout(time.Duration(timeout) * time.Second)
+		finder.SetConcurrent(threads)
+
+		results, err := finder.Find(ctx)
+		if err != nil {
+			return err
+		}
+		for name, err := range finder.SourceErrors() {
+			log.Warn("子域名数据源 %s 查询失败: %v", name, err)
+		}
+		report.Wildcards = finder.Wildcards()
+		report.Filtered = finder.Filtered()
+		for _, result := range results {
+			if reason, excluded := excludedName(result); excluded {
+				log.Debug("跳过被排除的子域名: %s", result.Name)
+				report.skip(result.Name, reason)
+				continue
+			}
+			if err := bus.Subdomains.Publish(ctx, pipeline.SubdomainEvent{Target: target, Result: result}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// dnsStage 收集目标的DNS记录，目标为IP时只做反向解析
+func dnsStage(target string, bus *pipeline.Bus) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		log.Info("执行DNS记录查询...")
+		scanner := dnsscan.NewScanner(target, time.Duration(timeout)*time.Second)
+		// 没有配置 dns.servers 时使用 --resolvers 指定的解析器
+		servers := viper.GetStringSlice("dns.servers")
+		if len(servers) == 0 && explicitResolvers {
+			servers = resolverServers
+		}
+		scanner.SetServers(servers)
+		if err := scanner.SetTypes(viper.GetStringSlice("dns.types")); err != nil {
+			return err
+		}
+		scanner.SetTCP(viper.GetBool("dns.tcp"))
+		scanner.SetRateLimiter(rateLimiter)
+
+		result, err := scanner.Scan(ctx)
+		if err != nil {
+			return err
+		}
+		return bus.DNS.Publish(ctx, pipeline.DNSEvent{Target: target, Result: result})
+	}
+}
+
+// cdnStage CDN检测
+func cdnStage(target string, bus *pipeline.Bus) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		log.Info("执行CDN检测...")
+		detector := cdn.NewDetector(target)
+		if resolverPool != nil {
+			detector.SetResolverPool(resolverPool)
+		}
+		cdnInfo, err := detector.Detect(ctx)
+		if err != nil {
+			return err
+		}
+		return bus.CDN.Publish(ctx, pipeline.CDNEvent{Target: target, Info: cdnInfo})
+	}
+}
+
+// aliveStage 存活探测
+func aliveStage(target string, bus *pipeline.Bus) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		log.Info("执行存活探测...")
+		detector := alive.NewDetector(target, time.Duration(timeout)*time.Second, threads)
+		detector.SetRateLimiter(rateLimiter)
+		detector.SetExcludeList(excludeList)
+		if targetPool != nil {
+			detector.SetResolverPool(targetPool)
+		}
+		aliveResult, err := detector.Detect(ctx)
+		if err != nil {
+			return err
+		}
+		return bus.Alive.Publish(ctx, pipeline.AliveEvent{Target: target, Result: aliveResult})
+	}
+}
+
+// fingerprintStage 服务识别，订阅端口事件，端口一旦发现开放即在目标的所有IP上识别该端口；
+// 未启用端口扫描时探测常用端口
+func fingerprintStage(target string, bus *pipeline.Bus, portsEnabled bool) func(ctx context.Context) error {
+	portEvents := bus.Ports.Subscribe()
+
+	return func(ctx context.Context) error {
+		log.Info("执行服务识别...")
+		scanner := fingerprint.NewScanner(target, time.Duration(timeout)*time.Second)
+		scanner.SetRateLimiter(rateLimiter)
+		scanner.SetExcludeList(excludeList)
+		scanner.SetProbes(serviceProbes)
+		scanner.SetDatabase(signatureDB)
+		scanner.SetIntensity(viper.GetInt("fingerprint.intensity"))
+		scanner.SetTechnologies(webTechnologies)
+		if targetPool != nil {
+			scanner.SetResolverPool(targetPool)
+		}
+		if viper.IsSet("fingerprint.web") {
+			scanner.SetWeb(viper.GetBool("fingerprint.web"))
+		}
+
+		if !portsEnabled {
+			for range portEvents {
+			}
+			results, err := scanner.Scan(ctx)
+			for _, result := range results {
+				if err := bus.Services.Publish(ctx, pipeline.ServiceEvent{Target: target, Result: result}); err != nil {
+					return err
+				}
+			}
+			return err
+		}
+
+		group := utils.NewTaskGroup(ctx, threads, false)
+		for ev := range portEvents {
+			if !ev.Open() || ev.Result.Protocol != portscan.ScanTCP {
+				continue
+			}
+
+			port := ev.Result.Port
+			// 上下文取消后不再提交任务，但仍需读完端口事件
+			group.Go(func(ctx context.Context) error {
+				results, err := scanner.ScanPorts(ctx, target, []int{port})
+				for _, result := range results {
+					if err := bus.Services.Publish(ctx, pipeline.ServiceEvent{Target: target, Result: result}); err != nil {
+						return err
+					}
+				}
+				return err
+			})
+		}
+		return group.Wait()
+	}
+}
+
+// tlsStage TLS检测，订阅端口事件，对每个开放的TCP端口尝试TLS握手，
+// 证书中属于目标域名的名称作为子域名发布，被排除的名称记为跳过；未启用端口扫描时检测常见TLS端口
+func tlsStage(target string, bus *pipeline.Bus, report *targetReport, portsEnabled bool) func(ctx context.Context) error {
+	portEvents := bus.Ports.Subscribe()
+
+	return func(ctx context.Context) error {
+		log.Info("执行TLS检测...")
+		scanner := tlsscan.NewScanner(target, time.Duration(timeout)*time.Second)
+		scanner.SetRateLimiter(rateLimiter)
+		scanner.SetExcludeList(excludeList)
+		if targetPool != nil {
+			scanner.SetResolverPool(targetPool)
+		}
+
+		var (
+			mu        sync.Mutex
+			published = make(map[string]bool)
+		)
+		publish := func(ctx context.Context, result tlsscan.ScanResult) error {
+			if err := bus.TLS.Publish(ctx, pipeline.TLSEvent{Target: target, Result: result}); err != nil {
+				return err
+			}
+			for _, name := range certSubdomains(target, result.DNSNames()) {
+				mu.Lock()
+				seen := published[name]
+				published[name] = true
+				mu.Unlock()
+				if seen {
+					continue
+				}
+				found := subdomain.Result{Name: name, Sources: []string{subdomain.SourceCertificate}}
+				if reason, excluded := excludedName(found); excluded {
+					log.Debug("跳过证书中被排除的名称: %s", name)
+					report.skip(name, reason)
+					continue
+				}
+				if err := bus.Subdomains.Publish(ctx, pipeline.SubdomainEvent{Target: target, Result: found}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if !portsEnabled {
+			for range portEvents {
+			}
+			results, err := scanner.Scan(ctx, tlsscan.DefaultPorts)
+			for _, result := range results {
+				if err := publish(ctx, result); err != nil {
+					return err
+				}
+			}
+			return err
+		}
+
+		group := utils.NewTaskGroup(ctx, threads, false)
+		for ev := range portEvents {
+			if !ev.Open() || ev.Result.Protocol != portscan.ScanTCP {
+				continue
+			}
+
+			port := ev.Result.Port
+			group.Go(func(ctx context.Context) error {
+				// 不支持TLS的端口没有结果
+				results, err := scanner.Scan(ctx, []int{port})
+				for _, result := range results {
+					if err := publish(ctx, result); err != nil {
+						return err
+					}
+				}
+				return err
+			})
+		}
+		return group.Wait()
+	}
+}
+
+// certSubdomains 返回证书名称中属于目标域名的名称，通配符名称取其父域名；
+// 目标为IP时证书中的名称都会返回
+func certSubdomains(target string, names []string) []string {
+	isIP := net.ParseIP(target) != nil
+	target = strings.ToLower(target)
+
+	var result []string
+	for _, name := range names {
+		name = strings.TrimPrefix(strings.ToLower(name), "*.")
+		if isIP || name == target || strings.HasSuffix(name, "."+target) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// vulnStage 漏洞扫描
+func vulnStage(target string, bus *pipeline.Bus) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		log.Info("执行漏洞扫描...")
+		scanner := vulnscan.NewScanner(target, time.Duration(timeout)*time.Second, threads)
+		scanner.SetRateLimiter(rateLimiter)
+		scanner.SetExcludeList(excludeList)
+		if targetPool != nil {
+			scanner.SetResolverPool(targetPool)
+		}
+		vulnResults, err := scanner.Scan(ctx)
+		for _, result := range vulnResults {
+			if err := bus.Findings.Publish(ctx, pipeline.FindingEvent{Target: target, Result: result}); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+}
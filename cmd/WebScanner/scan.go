@@ -3,40 +3,95 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/Marryname/WebScanner/internal/alive"
-	"github.com/Marryname/WebScanner/internal/cdn"
 	"github.com/Marryname/WebScanner/internal/fingerprint"
+	"github.com/Marryname/WebScanner/internal/pipeline"
 	"github.com/Marryname/WebScanner/internal/portscan"
 	"github.com/Marryname/WebScanner/internal/subdomain"
-	"github.com/Marryname/WebScanner/internal/vulnscan"
+	"github.com/Marryname/WebScanner/pkg/common"
 	"github.com/Marryname/WebScanner/pkg/logger"
+	"github.com/Marryname/WebScanner/pkg/utils"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // 命令行参数
 var (
-	target     string
-	portRange  string
-	threads    int
-	timeout    int
-	modules    []string
-	outputFile string
-	verbose    bool
+	target       string
+	targetFile   string
+	exclude      string
+	excludeFile  string
+	excludePorts string
+	portRange    string
+	scanType     string
+	threads      int
+	rate         float64
+	hostRate     float64
+	timeout      int
+	maxTime      int
+	modules      []string
+	outputFile   string
+	verbose      bool
+	wordlist     string
+	sources      []string
+	resolvers    string
+
+	// 展开后的目标列表
+	targets []string
+	// 解析后的端口列表
+	ports []int
+	// 各模块共享的速率限制器
+	rateLimiter *utils.RateLimiter
+	// 配置文件指定的服务探针，为空时使用内置探针
+	serviceProbes *fingerprint.ProbeSet
+	// 合并了签名目录的服务签名库，为空时使用内置签名
+	signatureDB *fingerprint.Database
+	// 配置文件指定的Web技术规则，为空时使用内置规则
+	webTechnologies *fingerprint.TechnologySet
+	// 子域名爆破字典，为空时使用内置字典
+	subdomainWords []string
+	// 子域名被动数据源
+	subdomainSources []subdomain.Source
+	// 子域名发现和CDN检测使用的解析器
+	resolverServers []string
+	// 是否通过 --resolvers 或 subdomain.resolvers_file 指定了解析器文件
+	explicitResolvers bool
+	// 子域名发现和CDN检测共享的解析器池，记录每个解析器的健康状况
+	resolverPool *utils.ResolverPool
+	// 连接目标的模块解析主机名使用的解析器池，只有指定了解析器文件时才使用 resolverPool，
+	// 否则为nil，使用系统解析器，保证内网、split-horizon 和 /etc/hosts 中的名称可以解析
+	targetPool *utils.ResolverPool
+	// 排除列表，各模块连接前据此检查解析出的地址，发现的子域名同样按它过滤
+	excludeList *common.ExcludeList
+	// 因命中排除列表而跳过的目标和端口
+	skippedTargets []string
+	skippedPorts   []int
 )
 
+// scanReport 扫描报告
+type scanReport struct {
+	Targets map[string]*targetReport `json:"targets"`
+	Skipped []common.Result          `json:"skipped,omitempty"`
+}
+
 // 有效的扫描模块
 var validModules = map[string]bool{
 	"port":      true,
 	"subdomain": true,
+	"dns":       true,
 	"cdn":       true,
 	"alive":     true,
 	"finger":    true,
+	"tls":       true,
 	"vuln":      true,
 	"all":       true,
 }
@@ -46,9 +101,9 @@ var scanCmd = &cobra.Command{
 	Short: "执行安全扫描",
 	Long:  `对目标执行综合安全扫描，包括端口扫描、服务识别、漏洞扫描等`,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
-		// 验证目标
-		if target == "" {
-			return fmt.Errorf("请指定扫描目标")
+		// 展开目标
+		if err := loadTargets(); err != nil {
+			return err
 		}
 
 		// 验证并修正参数
@@ -59,6 +114,31 @@ var scanCmd = &cobra.Command{
 			timeout = 5
 		}
 
+		// 未指定端口时使用配置文件中的默认范围
+		if !cmd.Flags().Changed("ports") && viper.IsSet("ports.default_range") {
+			portRange = viper.GetString("ports.default_range")
+		}
+
+		// 解析端口范围及预设
+		var err error
+		if ports, err = common.ParsePorts(portRange, viper.GetIntSlice("ports.common_ports")); err != nil {
+			return fmt.Errorf("无效的端口范围: %v", err)
+		}
+		if len(ports) == 0 {
+			return fmt.Errorf("端口范围为空: %s", portRange)
+		}
+
+		// 应用排除列表
+		if err := applyExclusions(); err != nil {
+			return err
+		}
+
+		// 验证扫描类型
+		scanType = strings.ToLower(scanType)
+		if scanType != portscan.ScanTCP && scanType != portscan.ScanUDP {
+			return fmt.Errorf("无效的扫描类型: %s", scanType)
+		}
+
 		// 验证模块
 		for _, m := range modules {
 			if !validModules[strings.ToLower(m)] {
@@ -66,22 +146,177 @@ var scanCmd = &cobra.Command{
 			}
 		}
 
+		// 加载子域名字典，命令行参数优先于配置文件
+		if !cmd.Flags().Changed("wordlist") && viper.IsSet("subdomain.wordlist") {
+			wordlist = viper.GetString("subdomain.wordlist")
+		}
+		if wordlist != "" && shouldRunModule("subdomain") {
+			if subdomainWords, err = subdomain.LoadWordlist(wordlist); err != nil {
+				return err
+			}
+		}
+		if !cmd.Flags().Changed("sources") {
+			sources = viper.GetStringSlice("subdomain.sources")
+		}
+		if shouldRunModule("subdomain") {
+			if subdomainSources, err = loadSources(sources); err != nil {
+				return err
+			}
+		}
+
+		// 加载解析器列表，命令行参数优先于配置文件。只有指定了解析器文件时连接目标的模块才通过解析器池
+		// 解析主机名，配置文件中的 subdomain.resolvers 只用于子域名发现和CDN检测
+		if !cmd.Flags().Changed("resolvers") {
+			resolvers = viper.GetString("subdomain.resolvers_file")
+		}
+		explicitResolvers = resolvers != ""
+		if explicitResolvers {
+			if resolverServers, err = utils.LoadResolvers(resolvers); err != nil {
+				return err
+			}
+		} else if shouldRunModule("subdomain") || shouldRunModule("cdn") {
+			resolverServers = viper.GetStringSlice("subdomain.resolvers")
+		}
+
+		// 加载 nmap-service-probes 格式的探针文件
+		if path := viper.GetString("fingerprint.probes_file"); path != "" && shouldRunModule("finger") {
+			if serviceProbes, err = fingerprint.LoadProbes(path); err != nil {
+				return err
+			}
+		}
+
+		// 加载 Wappalyzer 格式的Web技术规则
+		if path := viper.GetString("fingerprint.technologies_file"); path != "" && shouldRunModule("finger") {
+			if webTechnologies, err = fingerprint.LoadTechnologies(path); err != nil {
+				return err
+			}
+			if webTechnologies.Unsupported > 0 {
+				log.Warn("忽略 %d 条不支持的Web技术规则", webTechnologies.Unsupported)
+			}
+		}
+
+		// 加载签名目录，目录不存在时只使用内置签名
+		if path := viper.GetString("fingerprint.signatures_path"); path != "" && shouldRunModule("finger") {
+			if _, err := os.Stat(path); err == nil {
+				signatureDB = fingerprint.NewDatabase()
+				if err := signatureDB.LoadSignatures(path); err != nil {
+					return err
+				}
+			}
+		}
+
 		return nil
 	},
 	Run: runScan,
 }
 
 func init() {
-	scanCmd.Flags().StringVarP(&target, "target", "t", "", "扫描目标 (必需)")
-	scanCmd.Flags().StringVarP(&portRange, "ports", "p", "1-1000", "端口范围")
+	scanCmd.Flags().StringVarP(&target, "target", "t", "",
+		"扫描目标，支持逗号分隔、CIDR(10.0.0.0/24)和IP范围(10.0.0.1-50)")
+	scanCmd.Flags().StringVar(&targetFile, "target-file", "", "目标列表文件，每行一个目标")
+	scanCmd.Flags().StringVar(&exclude, "exclude", "", "排除的目标，支持IP、CIDR、IP范围、主机名和 *.example.com")
+	scanCmd.Flags().StringVar(&excludeFile, "exclude-file", "", "排除列表文件，每行一个排除项")
+	scanCmd.Flags().StringVarP(&portRange, "ports", "p", "1-1000",
+		"端口范围，支持预设 top100|top1000|common|all")
+	scanCmd.Flags().StringVar(&excludePorts, "exclude-ports", "", "排除的端口范围")
+	scanCmd.Flags().StringVar(&scanType, "scan-type", "tcp", "端口扫描类型 (tcp|udp)")
 	scanCmd.Flags().IntVarP(&threads, "threads", "n", 100, "并发线程数")
-	scanCmd.Flags().IntVar(&timeout, "timeout", 5, "超时时间(秒)")
+	scanCmd.Flags().Float64Var(&rate, "rate", 0, "全局每秒请求数上限 (0表示不限制)")
+	scanCmd.Flags().Float64Var(&hostRate, "host-rate", 0, "单个主机(按IP计算)每秒请求数上限 (0表示不限制)")
+	scanCmd.Flags().IntVar(&timeout, "timeout", 5, "单次连接和探测的超时时间(秒)")
+	scanCmd.Flags().IntVar(&maxTime, "max-time", 0, "整个扫描的最长时间(秒)，超过后跳过剩余目标 (0表示不限制)")
 	scanCmd.Flags().StringSliceVarP(&modules, "modules", "m", []string{"all"},
-		"扫描模块 (port|subdomain|cdn|alive|finger|vuln|all)")
+		"扫描模块 (port|subdomain|dns|cdn|alive|finger|tls|vuln|all)")
 	scanCmd.Flags().StringVarP(&outputFile, "output", "o", "", "输出文件路径")
-	scanCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "显示详细信息")
+	scanCmd.Flags().BoolVar(&verbose, "verbose", false, "显示详细信息")
+	scanCmd.Flags().StringVar(&wordlist, "wordlist", "", "子域名字典文件，每行一个前缀，默认使用内置字典")
+	scanCmd.Flags().StringSliceVar(&sources, "sources", nil,
+		"子域名被动数据源 (crtsh|otx|securitytrails|wayback)，默认读取配置文件")
+	scanCmd.Flags().StringVar(&resolvers, "resolvers", "", "DNS解析器列表文件，每行一个解析器，支持 https:// 和 tls:// 地址")
+}
 
-	scanCmd.MarkFlagRequired("target")
+// loadSources 按名称创建子域名被动数据源，接口地址和API密钥从配置文件的
+// subdomain.<数据源> 中读取
+func loadSources(names []string) ([]subdomain.Source, error) {
+	var result []subdomain.Source
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		source, err := subdomain.NewSource(name, subdomain.SourceConfig{
+			BaseURL: viper.GetString("subdomain." + name + ".url"),
+			APIKey:  viper.GetString("subdomain." + name + ".api_key"),
+			Timeout: time.Duration(viper.GetInt("subdomain.source_timeout")) * time.Second,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, source)
+	}
+	return result, nil
+}
+
+// loadTargets 合并 --target 和 --target-file 并展开为去重后的目标列表
+func loadTargets() error {
+	if target == "" && targetFile == "" {
+		return fmt.Errorf("请指定扫描目标 (--target 或 --target-file)")
+	}
+
+	var all []string
+	if target != "" {
+		parsed, err := common.ParseTargets(target)
+		if err != nil {
+			return err
+		}
+		all = append(all, parsed...)
+	}
+	if targetFile != "" {
+		parsed, err := common.ReadTargetFile(targetFile)
+		if err != nil {
+			return err
+		}
+		all = append(all, parsed...)
+	}
+
+	targets = common.DedupTargets(all)
+	if len(targets) == 0 {
+		return fmt.Errorf("没有有效的扫描目标")
+	}
+	return nil
+}
+
+// applyExclusions 在目标展开和端口解析之后移除被排除的目标和端口
+func applyExclusions() error {
+	excludes := common.NewExcludeList()
+	if exclude != "" {
+		if err := excludes.Add(exclude); err != nil {
+			return err
+		}
+	}
+	if excludeFile != "" {
+		if err := excludes.AddFile(excludeFile); err != nil {
+			return err
+		}
+	}
+	excludeList = excludes
+	targets, skippedTargets = excludes.Filter(targets)
+	if len(targets) == 0 {
+		return fmt.Errorf("所有目标均被排除")
+	}
+
+	if excludePorts != "" {
+		excluded, err := common.ParsePortRange(excludePorts)
+		if err != nil {
+			return fmt.Errorf("无效的排除端口: %v", err)
+		}
+		ports, skippedPorts = common.FilterPorts(ports, excluded)
+		if len(ports) == 0 {
+			return fmt.Errorf("所有端口均被排除")
+		}
+	}
+
+	return nil
 }
 
 func runScan(cmd *cobra.Command, args []string) {
@@ -98,142 +333,158 @@ func runScan(cmd *cobra.Command, args []string) {
 	defer log.Close()
 
 	startTime := time.Now()
-	log.Info("开始扫描目标: %s", target)
+	log.Info("开始扫描，共 %d 个目标", len(targets))
+
+	// 所有目标和模块共享同一个限速器
+	rateLimiter = utils.NewRateLimiter(rate, hostRate)
+
+	// --timeout 只用于单次连接和探测，整个扫描只在收到中断信号或超过 --max-time 时取消
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if maxTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(maxTime)*time.Second)
+		defer cancel()
+	}
+
+	// 所有目标共享同一个解析器池
+	if len(resolverServers) > 0 {
+		if err := setupResolverPool(ctx); err != nil {
+			log.Error("%v", err)
+			os.Exit(1)
+		}
+		if explicitResolvers {
+			targetPool = resolverPool
+		}
+	}
+
+	// 每个目标的结果单独存放
+	report := &scanReport{
+		Targets: make(map[string]*targetReport),
+	}
+	for _, t := range skippedTargets {
+		log.Info("跳过被排除的目标: %s", t)
+		report.Skipped = append(report.Skipped, common.NewSkippedResult(t, "excluded target"))
+	}
+
+	for _, t := range targets {
+		if ctx.Err() != nil {
+			log.Warn("扫描被中断，跳过剩余目标")
+			break
+		}
 
-	// 创建带超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-	defer cancel()
+		// 主机名解析出的地址全部被排除时不扫描该目标
+		excluded, all := excludedAddresses(ctx, t)
+		if all {
+			log.Info("跳过目标 %s: 解析出的地址均被排除", t)
+			report.Skipped = append(report.Skipped, common.NewSkippedResult(t, "excluded address"))
+			continue
+		}
 
-	results := make(map[string]interface{})
+		log.Info("开始扫描目标: %s", t)
 
-	// 执行各个模块的扫描
-	if err := runScanModules(ctx, results); err != nil {
-		log.Error("扫描过程中出错: %v", err)
+		// 执行各个模块的扫描
+		results, err := runScanModules(ctx, t)
+		logStageErrors(t, err)
+		for _, ip := range excluded {
+			log.Info("跳过目标 %s 被排除的地址: %s", t, ip)
+			results.Skipped = append(results.Skipped, common.NewSkippedResult(ip, "excluded address"))
+		}
+		report.Targets[t] = results
 	}
 
 	// 生成报告
 	duration := time.Since(startTime)
 	log.Info("扫描完成，用时: %v", duration)
 
-	if err := saveResults(results, outputFile); err != nil {
+	if err := saveResults(report, outputFile); err != nil {
 		log.Error("保存结果失败: %v", err)
 	}
 
 	// 打印摘要
-	printSummary(results)
+	printSummary(report)
+	logResolverStats()
 }
 
-func runScanModules(ctx context.Context, results map[string]interface{}) error {
-	// 端口扫描
-	if shouldRunModule("port") {
-		if err := runPortScan(ctx, results); err != nil {
-			log.Error("端口扫描失败: %v", err)
-		}
+// excludedAddresses 解析主机名目标，返回其中被排除的地址，all 表示全部地址都被排除。
+// 解析失败时不跳过，由各模块报告错误；各模块连接前还会再次检查解析出的地址
+func excludedAddresses(ctx context.Context, target string) (excluded []string, all bool) {
+	if excludeList.Len() == 0 || net.ParseIP(target) != nil {
+		return nil, false
 	}
 
-	// 子域名发现
-	if shouldRunModule("subdomain") {
-		if err := runSubdomainScan(ctx, results); err != nil {
-			log.Error("子域名发现失败: %v", err)
-		}
+	ips, err := utils.LookupIP(ctx, targetPool, target)
+	if err != nil {
+		return nil, false
 	}
-
-	// CDN检测
-	if shouldRunModule("cdn") {
-		if err := runCDNDetection(results); err != nil {
-			log.Error("CDN检测失败: %v", err)
+	for _, ip := range ips {
+		if excludeList.Contains(ip.String()) {
+			excluded = append(excluded, ip.String())
 		}
 	}
+	return excluded, len(ips) > 0 && len(excluded) == len(ips)
+}
 
-	// 存活探测
-	if shouldRunModule("alive") {
-		if err := runAliveDetection(results); err != nil {
-			log.Error("存活探测失败: %v", err)
-		}
+// setupResolverPool 创建解析器池，按配置用控制查询检查解析器，
+// 剔除不响应、返回错误地址或劫持不存在名称的解析器
+func setupResolverPool(ctx context.Context) error {
+	resolverPool = utils.NewResolverPool(resolverServers, time.Duration(timeout)*time.Second)
+	resolverPool.SetRateLimiter(rateLimiter)
+	if viper.IsSet("subdomain.max_error_rate") || viper.IsSet("subdomain.min_queries") {
+		resolverPool.SetEviction(viper.GetFloat64("subdomain.max_error_rate"), viper.GetInt("subdomain.min_queries"))
 	}
 
-	// 服务识别
-	if shouldRunModule("finger") && results["ports"] != nil {
-		if err := runFingerprint(ctx, results); err != nil {
-			log.Error("服务识别失败: %v", err)
-		}
+	if !viper.GetBool("subdomain.validate_resolvers") {
+		return nil
 	}
-
-	// 漏洞扫描
-	if shouldRunModule("vuln") {
-		if err := runVulnScan(ctx, results); err != nil {
-			log.Error("漏洞扫描失败: %v", err)
+	remaining, err := resolverPool.Validate(ctx, utils.DefaultControlQueries)
+	for _, stats := range resolverPool.Stats() {
+		if stats.Evicted {
+			log.Warn("剔除DNS解析器 %s: %s", stats.Server, stats.Reason)
 		}
 	}
-
-	return nil
-}
-
-// 各个模块的扫描函数
-func runPortScan(ctx context.Context, results map[string]interface{}) error {
-	log.Info("执行端口扫描...")
-	scanner := portscan.NewPortScanner(target, time.Duration(timeout)*time.Second)
-	portResults, err := scanner.Scan(ctx)
 	if err != nil {
 		return err
 	}
-	results["ports"] = portResults
+	log.Info("可用DNS解析器: %d 个 (共 %d 个)", remaining, len(resolverPool.Stats()))
 	return nil
 }
 
-func runSubdomainScan(ctx context.Context, results map[string]interface{}) error {
-	log.Info("执行子域名发现...")
-	finder := subdomain.NewFinder(target)
-	subdomains, err := finder.Find(ctx)
-	if err != nil {
-		return err
+// logResolverStats 记录解析器池中每个解析器的查询统计
+func logResolverStats() {
+	if resolverPool == nil {
+		return
 	}
-	results["subdomains"] = subdomains
-	return nil
-}
-
-func runCDNDetection(results map[string]interface{}) error {
-	log.Info("执行CDN检测...")
-	detector := cdn.NewDetector(target)
-	cdnInfo, err := detector.Detect()
-	if err != nil {
-		return err
+	for _, stats := range resolverPool.Stats() {
+		if stats.Queries == 0 {
+			continue
+		}
+		log.Debug("DNS解析器 %s: 查询 %d 次, 出错 %d, 超时 %d, SERVFAIL %d",
+			stats.Server, stats.Queries, stats.Errors, stats.Timeouts, stats.ServFail)
+		if stats.Evicted && stats.Reason == utils.EvictErrorRate {
+			log.Warn("DNS解析器 %s 出错率 %.0f%%，已被剔除", stats.Server, stats.ErrorRate()*100)
+		}
 	}
-	results["cdn"] = cdnInfo
-	return nil
 }
 
-func runAliveDetection(results map[string]interface{}) error {
-	log.Info("执行存活探测...")
-	detector := alive.NewDetector(target, time.Duration(timeout)*time.Second, threads)
-	aliveResult, err := detector.Detect()
-	if err != nil {
-		return err
+// logStageErrors 记录各模块的错误
+func logStageErrors(target string, err error) {
+	if err == nil {
+		return
 	}
-	results["alive"] = aliveResult
-	return nil
-}
 
-func runFingerprint(ctx context.Context, results map[string]interface{}) error {
-	log.Info("执行服务识别...")
-	scanner := fingerprint.NewScanner(target, time.Duration(timeout)*time.Second)
-	fingerResults, err := scanner.Scan(ctx)
-	if err != nil {
-		return err
+	errs, ok := err.(utils.MultiError)
+	if !ok {
+		errs = utils.MultiError{err}
 	}
-	results["services"] = fingerResults
-	return nil
-}
-
-func runVulnScan(ctx context.Context, results map[string]interface{}) error {
-	log.Info("执行漏洞扫描...")
-	scanner := vulnscan.NewScanner(target, time.Duration(timeout)*time.Second, threads)
-	vulnResults, err := scanner.Scan(ctx)
-	if err != nil {
-		return err
+	for _, e := range errs {
+		var stageErr *pipeline.StageError
+		if errors.As(e, &stageErr) {
+			log.Error("[%s] %s失败: %v", target, stageErr.Stage, stageErr.Err)
+		} else {
+			log.Error("[%s] 扫描过程中出错: %v", target, e)
+		}
 	}
-	results["vulnerabilities"] = vulnResults
-	return nil
 }
 
 func shouldRunModule(module string) bool {
@@ -248,7 +499,7 @@ func shouldRunModule(module string) bool {
 	return false
 }
 
-func saveResults(results map[string]interface{}, outputFile string) error {
+func saveResults(report *scanReport, outputFile string) error {
 	if outputFile == "" {
 		return nil
 	}
@@ -259,7 +510,7 @@ func saveResults(results map[string]interface{}, outputFile string) error {
 	}
 
 	// 保存为JSON格式
-	data, err := json.MarshalIndent(results, "", "  ")
+	data, err := json.MarshalIndent(report, "", "  ")
 	if err != nil {
 		return fmt.Errorf("JSON编码失败: %v", err)
 	}
@@ -271,30 +522,121 @@ func saveResults(results map[string]interface{}, outputFile string) error {
 	return nil
 }
 
-func printSummary(results map[string]interface{}) {
+func printSummary(report *scanReport) {
 	log.Info("\n扫描结果摘要:")
 
-	if ports, ok := results["ports"].([]portscan.ScanResult); ok {
-		log.Info("开放端口: %d 个", len(ports))
-		for _, port := range ports {
-			log.Info("  - %d (%s)", port.Port, port.Service)
+	if len(report.Skipped) > 0 {
+		log.Info("跳过目标: %d 个", len(report.Skipped))
+	}
+
+	for _, target := range targets {
+		results, ok := report.Targets[target]
+		if !ok {
+			continue
+		}
+		log.Info("[%s]", target)
+		printTargetSummary(results)
+	}
+}
+
+func printTargetSummary(results *targetReport) {
+	if len(results.Ports) > 0 {
+		open := portscan.OpenPorts(results.Ports)
+		log.Info("开放端口: %d 个 (共扫描 %d 个)", len(open), len(results.Ports))
+		for _, port := range results.Ports {
+			if port.State == portscan.StateOpen {
+				log.Info("  - %d/%s (%s)", port.Port, port.Protocol, port.Service)
+			}
+		}
+	}
+
+	if len(results.Skipped) > 0 {
+		// 跳过的项目包括端口、地址和子域名，按原因分别统计
+		var reasons []string
+		counts := make(map[string]int)
+		for _, skipped := range results.Skipped {
+			if counts[skipped.Reason] == 0 {
+				reasons = append(reasons, skipped.Reason)
+			}
+			counts[skipped.Reason]++
+		}
+		sort.Strings(reasons)
+		log.Info("跳过项目: %d 个", len(results.Skipped))
+		for _, reason := range reasons {
+			log.Info("  - %s: %d 个", reason, counts[reason])
+		}
+	}
+
+	if len(results.Subdomains) > 0 {
+		log.Info("发现子域名: %d 个", len(results.Subdomains))
+		for _, sub := range results.Subdomains {
+			addrs := append(append([]string{}, sub.A...), sub.AAAA...)
+			if sub.CNAME != "" {
+				log.Info("  - %s %v (CNAME %s) %v", sub.Name, addrs, sub.CNAME, sub.Sources)
+			} else {
+				log.Info("  - %s %v %v", sub.Name, addrs, sub.Sources)
+			}
+		}
+	}
+
+	for _, wildcard := range results.Wildcards {
+		addrs := append(append([]string{}, wildcard.A...), wildcard.AAAA...)
+		log.Info("泛解析: *.%s %v %v", wildcard.Domain, addrs, wildcard.CNAME)
+	}
+	if len(results.Filtered) > 0 {
+		log.Info("过滤泛解析子域名: %d 个", len(results.Filtered))
+	}
+
+	if results.DNS != nil && len(results.DNS.Records) > 0 {
+		log.Info("DNS记录: %d 条", len(results.DNS.Records))
+		for _, record := range results.DNS.Records {
+			log.Info("  - %s %d %s %s", record.Name, record.TTL, record.Type, record.Value)
 		}
 	}
 
-	if subdomains, ok := results["subdomains"].([]string); ok {
-		log.Info("发现子域名: %d 个", len(subdomains))
-		for _, subdomain := range subdomains {
-			log.Info("  - %s", subdomain)
+	if results.CDN != nil {
+		log.Info("CDN服务: %v (TTL %d)", results.CDN.IsCDN, results.CDN.TTL)
+		for _, record := range results.CDN.CNAMEChain {
+			log.Info("  - %s %d CNAME %s", record.Name, record.TTL, record.Target)
 		}
 	}
 
-	if cdnInfo, ok := results["cdn"].(*cdn.CDNInfo); ok {
-		log.Info("CDN服务: %v", cdnInfo.IsCDN)
+	if len(results.Services) > 0 {
+		log.Info("识别服务: %d 个", len(results.Services))
+		for _, service := range results.Services {
+			log.Info("  - %s:%d %s %s %s (置信度 %.0f%%, 依据 %s)", service.IP, service.Port,
+				service.ServiceName, service.Product, service.Version, service.Confidence*100, service.Evidence)
+			if service.CPE != "" {
+				log.Info("    %s", service.CPE)
+			}
+			if web := service.Web; web != nil {
+				log.Info("    %s [%d] %s (Server: %s, 长度 %d, favicon %d)", web.URL, web.StatusCode,
+					web.Title, web.Server, web.ContentLength, web.FaviconHash)
+				for _, tech := range web.Technologies {
+					log.Info("    %s %s %v", tech.Name, tech.Version, tech.Categories)
+				}
+			}
+		}
+	}
+
+	if len(results.TLS) > 0 {
+		log.Info("TLS服务: %d 个", len(results.TLS))
+		for _, result := range results.TLS {
+			log.Info("  - %d %v", result.Port, result.Versions)
+			if len(result.Chain) > 0 {
+				leaf := result.Chain[0]
+				log.Info("    证书: %s (签发者 %s, 有效期至 %s)", leaf.Subject, leaf.Issuer,
+					leaf.NotAfter.Format("2006-01-02"))
+			}
+			if len(result.Weaknesses) > 0 {
+				log.Warn("    弱配置: %s", strings.Join(result.Weaknesses, ", "))
+			}
+		}
 	}
 
-	if vulns, ok := results["vulnerabilities"].([]vulnscan.VulnResult); ok {
-		log.Info("发现漏洞: %d 个", len(vulns))
-		for _, vuln := range vulns {
+	if len(results.Vulnerabilities) > 0 {
+		log.Info("发现漏洞: %d 个", len(results.Vulnerabilities))
+		for _, vuln := range results.Vulnerabilities {
 			log.Info("  - [%s] %s", vuln.Severity, vuln.Name)
 		}
 	}
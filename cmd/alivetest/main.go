@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -28,7 +29,7 @@ func main() {
 	defer log.Close()
 
 	detector := alive.NewDetector(*target, time.Duration(*timeout)*time.Second, *threads)
-	result, err := detector.Detect()
+	result, err := detector.Detect(context.Background())
 	if err != nil {
 		log.Error("存活探测失败: %v", err)
 		return
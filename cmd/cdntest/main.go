@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -24,7 +25,7 @@ func main() {
 	detector := cdn.NewDetector(*target)
 	detector.SetTimeout(time.Duration(*timeout) * time.Second)
 
-	info, err := detector.Detect()
+	info, err := detector.Detect(context.Background())
 	if err != nil {
 		log.Fatalf("CDN检测失败: %v", err)
 	}
@@ -34,10 +35,10 @@ func main() {
 	fmt.Printf("是否使用CDN: %v\n", info.IsCDN)
 
 	if *verbose {
-		if len(info.CNAMEs) > 0 {
+		if len(info.CNAMEChain) > 0 {
 			fmt.Printf("\nCNAME记录:\n")
-			for _, cname := range info.CNAMEs {
-				fmt.Printf("- %s\n", cname)
+			for _, record := range info.CNAMEChain {
+				fmt.Printf("- %s -> %s (TTL %d)\n", record.Name, record.Target, record.TTL)
 			}
 		}
 
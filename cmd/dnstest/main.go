@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Marryname/WebScanner/internal/dnsscan"
+)
+
+func main() {
+	target := flag.String("target", "", "目标域名或IP")
+	servers := flag.String("servers", "", "DNS服务器，逗号分隔，支持 host:port、https:// (DoH) 和 tls:// (DoT)")
+	types := flag.String("types", "", "记录类型，逗号分隔，默认查询全部支持的类型")
+	tcp := flag.Bool("tcp", false, "总是通过TCP查询")
+	timeout := flag.Int("timeout", 3, "单次查询超时时间(秒)")
+	verbose := flag.Bool("verbose", false, "显示每次查询的响应")
+	flag.Parse()
+
+	if *target == "" {
+		log.Fatal("请指定目标域名或IP")
+	}
+
+	scanner := dnsscan.NewScanner(*target, time.Duration(*timeout)*time.Second)
+	scanner.SetTCP(*tcp)
+	if *servers != "" {
+		scanner.SetServers(strings.Split(*servers, ","))
+	}
+	if *types != "" {
+		if err := scanner.SetTypes(strings.Split(*types, ",")); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fmt.Printf("\n[+] 开始查询 %s 的DNS记录...\n", *target)
+
+	result, err := scanner.Scan(context.Background())
+	if err != nil {
+		log.Fatalf("DNS查询失败: %v", err)
+	}
+
+	fmt.Printf("\n记录:\n")
+	for _, record := range result.Records {
+		fmt.Printf("%s\t%d\t%s\t%s\n", record.Name, record.TTL, record.Type, record.Value)
+	}
+
+	if *verbose {
+		fmt.Printf("\n响应:\n")
+		for _, resp := range result.Responses {
+			fmt.Printf("- %s %s @%s/%s %s (%d 字节)\n", resp.Name, resp.Type, resp.Server, resp.Network,
+				resp.RCode, len(resp.Raw))
+			for _, record := range resp.Authority {
+				fmt.Printf("  授权: %s\t%d\t%s\t%s\n", record.Name, record.TTL, record.Type, record.Value)
+			}
+		}
+	}
+}
@@ -5,15 +5,23 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/Marryname/WebScanner/internal/fingerprint"
+	"github.com/Marryname/WebScanner/pkg/common"
 	"github.com/Marryname/WebScanner/pkg/logger"
 )
 
 func main() {
 	target := flag.String("target", "", "目标主机或域名")
+	portRange := flag.String("ports", "", "端口范围，默认探测常用端口")
 	timeout := flag.Int("timeout", 5, "超时时间(秒)")
+	probesFile := flag.String("probes", "", "nmap-service-probes 格式的探针文件")
+	intensity := flag.Int("intensity", fingerprint.DefaultIntensity, "探测强度(1-9)")
+	signatures := flag.String("signatures", "", "签名文件或目录(YAML/JSON)")
+	technologies := flag.String("technologies", "", "Wappalyzer 格式的Web技术规则文件")
+	web := flag.Bool("web", true, "对HTTP服务进行Web指纹识别")
 	flag.Parse()
 
 	if *target == "" {
@@ -27,19 +35,77 @@ func main() {
 	}
 	defer log.Close()
 
+	ports := fingerprint.DefaultPorts
+	if *portRange != "" {
+		if ports, err = common.ParsePortRange(*portRange); err != nil {
+			log.Error("无效的端口范围: %v", err)
+			return
+		}
+	}
+
 	scanner := fingerprint.NewScanner(*target, time.Duration(*timeout)*time.Second)
-	results, err := scanner.Scan(context.Background())
+	scanner.SetIntensity(*intensity)
+	scanner.SetWeb(*web)
+	if *probesFile != "" {
+		probes, err := fingerprint.LoadProbes(*probesFile)
+		if err != nil {
+			log.Error("%v", err)
+			return
+		}
+		if probes.Unsupported > 0 {
+			log.Warn("忽略 %d 条不支持的匹配规则", probes.Unsupported)
+		}
+		scanner.SetProbes(probes)
+	}
+	if *signatures != "" {
+		db := fingerprint.NewDatabase()
+		if err := db.LoadSignatures(*signatures); err != nil {
+			log.Error("%v", err)
+			return
+		}
+		scanner.SetDatabase(db)
+	}
+	if *technologies != "" {
+		techs, err := fingerprint.LoadTechnologies(*technologies)
+		if err != nil {
+			log.Error("%v", err)
+			return
+		}
+		if techs.Unsupported > 0 {
+			log.Warn("忽略 %d 条不支持的Web技术规则", techs.Unsupported)
+		}
+		scanner.SetTechnologies(techs)
+	}
+	results, err := scanner.ScanPorts(context.Background(), *target, ports)
 	if err != nil {
 		log.Error("服务识别失败: %v", err)
 		return
 	}
 
 	for _, result := range results {
-		fmt.Printf("端口 %d:\n", result.Port)
+		fmt.Printf("%s 端口 %d:\n", result.IP, result.Port)
 		fmt.Printf("  服务: %s\n", result.ServiceName)
+		fmt.Printf("  产品: %s %s\n", result.Vendor, result.Product)
 		fmt.Printf("  版本: %s\n", result.Version)
+		if result.CPE != "" {
+			fmt.Printf("  CPE: %s\n", result.CPE)
+		}
+		fmt.Printf("  置信度: %.0f%% (%s)\n", result.Confidence*100, result.Evidence)
 		if result.Banner != "" {
 			fmt.Printf("  Banner: %s\n", result.Banner)
 		}
+		if web := result.Web; web != nil {
+			fmt.Printf("  页面: %s [%d] %s\n", web.URL, web.StatusCode, web.Title)
+			fmt.Printf("  Server: %s, 长度: %d\n", web.Server, web.ContentLength)
+			if len(web.RedirectChain) > 0 {
+				fmt.Printf("  重定向: %s\n", strings.Join(web.RedirectChain, " -> "))
+			}
+			if web.FaviconURL != "" {
+				fmt.Printf("  Favicon: %s (mmh3 %d)\n", web.FaviconURL, web.FaviconHash)
+			}
+			for _, tech := range web.Technologies {
+				fmt.Printf("  技术: %s %s %v (置信度 %d%%)\n", tech.Name, tech.Version, tech.Categories, tech.Confidence)
+			}
+		}
 	}
 }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Marryname/WebScanner/internal/subdomain"
+	"github.com/Marryname/WebScanner/pkg/utils"
+)
+
+func main() {
+	domain := flag.String("domain", "", "目标域名")
+	wordlist := flag.String("wordlist", "", "子域名字典文件，默认使用内置字典")
+	resolvers := flag.String("resolvers", "", "DNS解析器，逗号分隔，支持 host:port、https:// (DoH) 和 tls:// (DoT)")
+	resolversFile := flag.String("resolvers-file", "", "DNS解析器列表文件，每行一个解析器")
+	validate := flag.Bool("validate", false, "用控制查询检查解析器，剔除不可信的解析器")
+	threads := flag.Int("threads", 50, "并发查询数")
+	timeout := flag.Int("timeout", 3, "单次查询超时时间(秒)")
+	techniques := flag.String("techniques", "", "DNS协议发现技术，逗号分隔 (axfr|srv|nsec)")
+	permute := flag.Bool("permutations", false, "为发现的子域名生成排列")
+	depth := flag.Int("depth", 0, "递归爆破层级，0表示不递归")
+	sources := flag.String("sources", "", "被动数据源，逗号分隔 (crtsh|otx|securitytrails|wayback)")
+	otxKey := flag.String("otx-key", "", "AlienVault OTX API密钥")
+	securityTrailsKey := flag.String("securitytrails-key", "", "SecurityTrails API密钥")
+	flag.Parse()
+
+	if *domain == "" {
+		log.Fatal("请指定测试域名")
+	}
+
+	finder := subdomain.NewFinder(*domain)
+	finder.SetConcurrent(*threads)
+	finder.SetTimeout(time.Duration(*timeout) * time.Second)
+	finder.SetPermutations(*permute)
+	if *techniques != "" {
+		if err := finder.SetTechniques(strings.Split(*techniques, ",")); err != nil {
+			log.Fatal(err)
+		}
+	}
+	finder.SetRecursion(*depth)
+	if *wordlist != "" {
+		words, err := subdomain.LoadWordlist(*wordlist)
+		if err != nil {
+			log.Fatal(err)
+		}
+		finder.SetWordlist(words)
+	}
+	servers := subdomain.DefaultResolvers
+	if *resolvers != "" {
+		servers = strings.Split(*resolvers, ",")
+	}
+	if *resolversFile != "" {
+		var err error
+		if servers, err = utils.LoadResolvers(*resolversFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	pool := utils.NewResolverPool(servers, time.Duration(*timeout)*time.Second)
+	if *validate {
+		remaining, err := pool.Validate(context.Background(), nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("[+] %d 个解析器通过控制查询\n", remaining)
+	}
+	finder.SetResolverPool(pool)
+	if *sources != "" {
+		keys := map[string]string{
+			subdomain.SourceOTX:            *otxKey,
+			subdomain.SourceSecurityTrails: *securityTrailsKey,
+		}
+		var list []subdomain.Source
+		for _, name := range strings.Split(*sources, ",") {
+			source, err := subdomain.NewSource(name, subdomain.SourceConfig{APIKey: keys[name]})
+			if err != nil {
+				log.Fatal(err)
+			}
+			list = append(list, source)
+		}
+		finder.SetSources(list)
+	}
+
+	fmt.Printf("\n[+] 开始发现 %s 的子域名...\n", *domain)
+
+	start := time.Now()
+	results, err := finder.Find(context.Background())
+	if err != nil {
+		log.Fatalf("子域名发现失败: %v", err)
+	}
+
+	for _, result := range results {
+		fmt.Printf("%s %v\n", result.Name, result.Sources)
+		if result.CNAME != "" {
+			fmt.Printf("  CNAME: %s\n", result.CNAME)
+		}
+		for _, ip := range result.A {
+			fmt.Printf("  A: %s\n", ip)
+		}
+		for _, ip := range result.AAAA {
+			fmt.Printf("  AAAA: %s\n", ip)
+		}
+	}
+	for name, err := range finder.SourceErrors() {
+		fmt.Printf("\n[!] 数据源 %s 查询失败: %v\n", name, err)
+	}
+	for _, wildcard := range finder.Wildcards() {
+		fmt.Printf("\n[!] *.%s 存在泛解析: %v %v %v\n", wildcard.Domain, wildcard.A, wildcard.AAAA, wildcard.CNAME)
+	}
+	for _, filtered := range finder.Filtered() {
+		fmt.Printf("  已过滤 %s (%s)\n", filtered.Result.Name, filtered.Reason)
+	}
+	fmt.Printf("\n[+] 发现 %d 个子域名，耗时 %v\n", len(results), time.Since(start))
+
+	fmt.Println("\n[+] 解析器统计:")
+	for _, stats := range pool.Stats() {
+		fmt.Printf("  %s 查询 %d 出错 %d 超时 %d SERVFAIL %d", stats.Server,
+			stats.Queries, stats.Errors, stats.Timeouts, stats.ServFail)
+		if stats.Evicted {
+			fmt.Printf(" 已剔除(%s)", stats.Reason)
+		}
+		fmt.Println()
+	}
+}
@@ -8,11 +8,15 @@ import (
 	"time"
 
 	"github.com/Marryname/WebScanner/internal/portscan"
+	"github.com/Marryname/WebScanner/pkg/common"
 	"github.com/Marryname/WebScanner/pkg/logger"
 )
 
 func main() {
 	target := flag.String("target", "", "目标主机或域名")
+	portRange := flag.String("ports", "1-1000", "端口范围")
+	scanType := flag.String("scan-type", "tcp", "扫描类型 (tcp|udp)")
+	threads := flag.Int("threads", 100, "并发线程数")
 	timeout := flag.Int("timeout", 5, "超时时间(秒)")
 	flag.Parse()
 
@@ -27,7 +31,18 @@ func main() {
 	}
 	defer log.Close()
 
-	scanner := portscan.NewPortScanner(*target, time.Duration(*timeout)*time.Second)
+	ports, err := common.ParsePortRange(*portRange)
+	if err != nil {
+		log.Error("无效的端口范围: %v", err)
+		return
+	}
+
+	scanner := portscan.NewPortScanner(*target, ports, time.Duration(*timeout)*time.Second, *threads)
+	if err := scanner.SetScanType(*scanType); err != nil {
+		log.Error("%v", err)
+		return
+	}
+
 	results, err := scanner.Scan(context.Background())
 	if err != nil {
 		log.Error("扫描失败: %v", err)
@@ -35,6 +50,6 @@ func main() {
 	}
 
 	for _, result := range results {
-		fmt.Printf("端口 %d: %s\n", result.Port, result.State)
+		fmt.Printf("端口 %d/%s: %s\n", result.Port, result.Protocol, result.State)
 	}
 }
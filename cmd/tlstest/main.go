@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Marryname/WebScanner/internal/tlsscan"
+	"github.com/Marryname/WebScanner/pkg/common"
+)
+
+func main() {
+	target := flag.String("target", "", "目标主机或域名")
+	portRange := flag.String("ports", "", "端口范围，默认检测常见TLS端口")
+	timeout := flag.Int("timeout", 5, "超时时间(秒)")
+	verbose := flag.Bool("verbose", false, "显示详细信息")
+	flag.Parse()
+
+	if *target == "" {
+		log.Fatal("请指定测试目标")
+	}
+
+	ports := tlsscan.DefaultPorts
+	if *portRange != "" {
+		var err error
+		if ports, err = common.ParsePortRange(*portRange); err != nil {
+			log.Fatalf("无效的端口范围: %v", err)
+		}
+	}
+
+	fmt.Printf("\n[+] 开始检测目标 %s 的TLS配置...\n", *target)
+
+	scanner := tlsscan.NewScanner(*target, time.Duration(*timeout)*time.Second)
+	results, err := scanner.Scan(context.Background(), ports)
+	if err != nil {
+		log.Fatalf("TLS检测失败: %v", err)
+	}
+
+	for _, result := range results {
+		fmt.Printf("\n%s 端口 %d:\n", result.IP, result.Port)
+		fmt.Printf("  协议版本: %s\n", strings.Join(result.Versions, ", "))
+		for i, cert := range result.Chain {
+			fmt.Printf("  证书[%d]: %s\n", i, cert.Subject)
+			fmt.Printf("    签发者: %s\n", cert.Issuer)
+			fmt.Printf("    有效期: %s - %s\n", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+			fmt.Printf("    密钥: %s %d, 签名算法: %s\n", cert.KeyType, cert.KeySize, cert.SignatureAlgorithm)
+			if len(cert.SANs) > 0 {
+				fmt.Printf("    SAN: %s\n", strings.Join(cert.SANs, ", "))
+			}
+		}
+		if *verbose {
+			fmt.Printf("  密码套件:\n")
+			for _, cipher := range result.Ciphers {
+				fmt.Printf("    - %s %s\n", cipher.Version, cipher.Name)
+			}
+		}
+		if len(result.Weaknesses) > 0 {
+			fmt.Printf("  弱配置: %s\n", strings.Join(result.Weaknesses, ", "))
+		}
+	}
+}
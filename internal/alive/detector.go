@@ -7,9 +7,13 @@ import (
 	"net/http"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/Marryname/WebScanner/pkg/common"
+	"github.com/Marryname/WebScanner/pkg/utils"
 )
 
 // DetectResult 存储探测结果
@@ -26,6 +30,12 @@ type Detector struct {
 	target     string
 	timeout    time.Duration
 	concurrent int
+	limiter    *utils.RateLimiter
+	pool       *utils.ResolverPool
+	exclude    *common.ExcludeList
+
+	mu sync.Mutex
+	ip string
 }
 
 // NewDetector 创建新的存活探测器
@@ -37,65 +47,65 @@ func NewDetector(target string, timeout time.Duration, concurrent int) *Detector
 	}
 }
 
-// Detect 执行综合探测
-func (d *Detector) Detect() (*DetectResult, error) {
+// SetRateLimiter 设置共享的速率限制器
+func (d *Detector) SetRateLimiter(limiter *utils.RateLimiter) {
+	d.limiter = limiter
+}
+
+// SetResolverPool 设置解析目标使用的解析器池，未设置时使用系统解析器
+func (d *Detector) SetResolverPool(pool *utils.ResolverPool) {
+	d.pool = pool
+}
+
+// SetExcludeList 设置排除列表，解析出的地址在列表中时不连接
+func (d *Detector) SetExcludeList(exclude *common.ExcludeList) {
+	d.exclude = exclude
+}
+
+// Detect 执行综合探测，上下文取消时正在进行的探测立即结束并返回上下文错误
+func (d *Detector) Detect(ctx context.Context) (*DetectResult, error) {
 	result := &DetectResult{
 		Target: d.target,
 	}
 
 	// 并发执行多种探测方法
-	var wg sync.WaitGroup
-	resultChan := make(chan string, 3) // 存储成功的探测方法
-	errorChan := make(chan error, 3)   // 存储错误信息
-
-	// 1. ICMP探测
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if alive, err := d.icmpDetect(); err != nil {
-			errorChan <- fmt.Errorf("ICMP探测失败: %v", err)
-		} else if alive {
-			resultChan <- "ICMP"
-		}
-	}()
-
-	// 2. TCP SYN探测
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if alive, err := d.tcpDetect(); err != nil {
-			errorChan <- fmt.Errorf("TCP探测失败: %v", err)
-		} else if alive {
-			resultChan <- "TCP"
-		}
-	}()
-
-	// 3. HTTP探测
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if alive, err := d.httpDetect(); err != nil {
-			errorChan <- fmt.Errorf("HTTP探测失败: %v", err)
-		} else if alive {
-			resultChan <- "HTTP"
-		}
-	}()
+	probes := []struct {
+		method string
+		detect func(ctx context.Context) (bool, error)
+	}{
+		{"ICMP", d.icmpDetect}, // 1. ICMP探测
+		{"TCP", d.tcpDetect},   // 2. TCP连接探测
+		{"HTTP", d.httpDetect}, // 3. HTTP探测
+	}
+	succeeded := make([]bool, len(probes))
 
-	// 等待所有探测完成
-	go func() {
-		wg.Wait()
-		close(resultChan)
-		close(errorChan)
-	}()
+	group := utils.NewTaskGroup(ctx, len(probes), false)
+	for i, probe := range probes {
+		i, probe := i, probe
+		group.Go(func(ctx context.Context) error {
+			alive, err := probe.detect(ctx)
+			if err != nil {
+				return fmt.Errorf("%s探测失败: %v", probe.method, err)
+			}
+			succeeded[i] = alive
+			return nil
+		})
+	}
+	group.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	// 收集结果
-	for method := range resultChan {
-		result.Methods = append(result.Methods, method)
+	// 按探测顺序收集成功的方法
+	for i, probe := range probes {
+		if succeeded[i] {
+			result.Methods = append(result.Methods, probe.method)
+		}
 	}
 
 	// 收集错误
 	var errors []string
-	for err := range errorChan {
+	for _, err := range group.Errors() {
 		errors = append(errors, err.Error())
 	}
 
@@ -108,34 +118,83 @@ func (d *Detector) Detect() (*DetectResult, error) {
 	return result, nil
 }
 
+// resolve 通过解析器池解析目标，跳过被排除的地址，优先使用IPv4地址，结果会被缓存。
+// 各探测方法都连接该地址，速率限制也按该地址计算
+func (d *Detector) resolve(ctx context.Context) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.ip != "" {
+		return d.ip, nil
+	}
+
+	ips, err := utils.LookupIP(ctx, d.pool, d.target)
+	if err != nil {
+		return "", fmt.Errorf("解析目标失败: %v", err)
+	}
+	if ips, err = d.exclude.FilterIPs(d.target, ips); err != nil {
+		return "", err
+	}
+	if len(ips) == 0 {
+		return "", fmt.Errorf("解析目标失败: %s 没有可用地址", d.target)
+	}
+	d.ip = ips[0].String()
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			d.ip = ip.String()
+			break
+		}
+	}
+	return d.ip, nil
+}
+
 // icmpDetect 执行ICMP探测
-func (d *Detector) icmpDetect() (bool, error) {
+func (d *Detector) icmpDetect(ctx context.Context) (bool, error) {
+	ip, err := d.resolve(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err := d.limiter.Wait(ctx, ip); err != nil {
+		return false, err
+	}
+
 	var cmd *exec.Cmd
 
 	switch runtime.GOOS {
 	case "windows":
-		cmd = exec.Command("ping", "-n", "1", "-w", fmt.Sprintf("%d", d.timeout/time.Millisecond), d.target)
+		cmd = exec.CommandContext(ctx, "ping", "-n", "1", "-w", fmt.Sprintf("%d", d.timeout/time.Millisecond), ip)
 	default: // Linux, Darwin
-		cmd = exec.Command("ping", "-c", "1", "-W", fmt.Sprintf("%d", d.timeout/time.Second), d.target)
+		cmd = exec.CommandContext(ctx, "ping", "-c", "1", "-W", fmt.Sprintf("%d", d.timeout/time.Second), ip)
 	}
 
 	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
 		return false, nil // 目标不可达
 	}
 	return true, nil
 }
 
 // tcpDetect 执行TCP SYN探测
-func (d *Detector) tcpDetect() (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+func (d *Detector) tcpDetect(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
 	defer cancel()
 
+	ip, err := d.resolve(ctx)
+	if err != nil {
+		return false, err
+	}
+
 	// 常用端口列表
 	ports := []int{80, 443, 22, 21, 25, 3389}
 
 	for _, port := range ports {
+		if err := d.limiter.Wait(ctx, ip); err != nil {
+			return false, err
+		}
+
 		var dialer net.Dialer
-		conn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", d.target, port))
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, strconv.Itoa(port)))
 		if err == nil {
 			conn.Close()
 			return true, nil
@@ -145,10 +204,28 @@ func (d *Detector) tcpDetect() (bool, error) {
 	return false, nil
 }
 
-// httpDetect 执行HTTP请求探测
-func (d *Detector) httpDetect() (bool, error) {
+// httpDetect 执行HTTP请求探测，请求使用主机名，连接固定到解析出的地址
+func (d *Detector) httpDetect(ctx context.Context) (bool, error) {
+	ip, err := d.resolve(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	dialer := &net.Dialer{Timeout: d.timeout}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			_, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			return dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		},
+	}
+	defer transport.CloseIdleConnections()
+
 	client := &http.Client{
-		Timeout: d.timeout,
+		Transport: transport,
+		Timeout:   d.timeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse // 不跟随重定向
 		},
@@ -158,8 +235,12 @@ func (d *Detector) httpDetect() (bool, error) {
 	protocols := []string{"http", "https"}
 
 	for _, protocol := range protocols {
+		if err := d.limiter.Wait(ctx, ip); err != nil {
+			return false, err
+		}
+
 		url := fmt.Sprintf("%s://%s", protocol, d.target)
-		req, err := http.NewRequest("HEAD", url, nil)
+		req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
 		if err != nil {
 			continue
 		}
@@ -169,6 +250,9 @@ func (d *Detector) httpDetect() (bool, error) {
 			resp.Body.Close()
 			return true, nil
 		}
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
 	}
 
 	return false, nil
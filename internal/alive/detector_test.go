@@ -1,8 +1,11 @@
 package alive
 
 import (
+	"context"
 	"testing"
 	"time"
+
+	"github.com/Marryname/WebScanner/pkg/utils"
 )
 
 func TestAliveDetector(t *testing.T) {
@@ -51,7 +54,7 @@ func TestAliveDetector(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			detector := NewDetector(tt.target, tt.timeout, tt.concurrent)
-			result, err := detector.Detect()
+			result, err := detector.Detect(context.Background())
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("错误状态不符合预期: %v", err)
@@ -78,19 +81,46 @@ func TestIndividualMethods(t *testing.T) {
 
 	// 测试ICMP探测
 	t.Run("ICMP探测", func(t *testing.T) {
-		alive, err := detector.icmpDetect()
+		alive, err := detector.icmpDetect(context.Background())
 		t.Logf("ICMP探测结果: %v, 错误: %v", alive, err)
 	})
 
 	// 测试TCP探测
 	t.Run("TCP探测", func(t *testing.T) {
-		alive, err := detector.tcpDetect()
+		alive, err := detector.tcpDetect(context.Background())
 		t.Logf("TCP探测结果: %v, 错误: %v", alive, err)
 	})
 
 	// 测试HTTP探测
 	t.Run("HTTP探测", func(t *testing.T) {
-		alive, err := detector.httpDetect()
+		alive, err := detector.httpDetect(context.Background())
 		t.Logf("HTTP探测结果: %v, 错误: %v", alive, err)
 	})
 }
+
+func TestDetectorWithContext(t *testing.T) {
+	detector := NewDetector("127.0.0.1", 5*time.Second, 10)
+
+	// 测试上下文取消
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if _, err := detector.Detect(ctx); err == nil {
+		t.Error("Detect() 在上下文取消时应返回错误")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Detect() 在上下文取消后仍运行了 %v", elapsed)
+	}
+}
+
+func TestDetectorRateLimit(t *testing.T) {
+	// 每个主机每100秒一次，第二次连接前就会超时
+	detector := NewDetector("127.0.0.1", 200*time.Millisecond, 10)
+	detector.SetRateLimiter(utils.NewRateLimiter(0, 0.01))
+
+	// 限速等待被取消时返回错误，而不是当作目标不存活
+	if _, err := detector.tcpDetect(context.Background()); err == nil {
+		t.Error("tcpDetect() 在限速等待超时时应返回错误")
+	}
+}
@@ -6,23 +6,40 @@ import (
 	"net"
 	"strings"
 	"time"
+
+	"github.com/Marryname/WebScanner/pkg/utils"
+	"golang.org/x/net/dns/dnsmessage"
 )
 
-// CDNInfo 存储 CDN 检测结果
+// CDN通常使用较小的TTL，低于该值的记录视为CDN特征
+const shortTTL = 300
+
+// CDNInfo 存储 CDN 检测结果，TTL 为A记录的最小TTL，取自权威服务器
 type CDNInfo struct {
 	Domain      string
 	IsCDN       bool
 	CNAMEs      []string
+	CNAMEChain  []CNAMERecord // 按解析顺序排列的CNAME链及每一跳的TTL
 	IPs         []string
 	TTL         int
 	GeoLocation map[string][]string // IP所属地理位置
 }
 
+// CNAMERecord CNAME链中的一跳
+type CNAMERecord struct {
+	Name   string
+	Target string
+	TTL    int
+}
+
 // Detector CDN检测器结构体
 type Detector struct {
 	target           string
 	cdnCNAMEKeywords []string
 	timeout          time.Duration
+	pool             *utils.ResolverPool
+	// nsPort 权威服务器的端口，测试时指向本地服务器
+	nsPort string
 }
 
 // NewDetector 创建新的CDN检测器
@@ -34,79 +51,175 @@ func NewDetector(target string) *Detector {
 			"cloudflare", "edgecast", "chinacache",
 			"wscdns", "cdn.dnsv1",
 		},
+		nsPort: "53",
 	}
 }
 
-// Detect 执行CDN检测
-func (d *Detector) Detect() (*CDNInfo, error) {
+// Detect 执行CDN检测。CNAME链和地址通过解析器池查询，TTL再向各名称所在区域的权威服务器
+// 不带RD标志查询，得到区域实际设置的值，权威服务器不可用时保留解析器返回的TTL
+func (d *Detector) Detect(ctx context.Context) (*CDNInfo, error) {
 	info := &CDNInfo{
 		Domain:      d.target,
 		GeoLocation: make(map[string][]string),
 	}
 
-	// 1. 检查CNAME记录
-	cnames, err := d.checkCNAME()
+	// 1. 查询A记录，应答中包含CNAME链和各记录的实际TTL
+	resp, err := d.resolverPool().Lookup(ctx, d.target, dnsmessage.TypeA)
 	if err != nil {
-		return nil, fmt.Errorf("CNAME检查失败: %v", err)
+		return nil, fmt.Errorf("DNS查询失败: %v", err)
+	}
+	if resp.RCode != "NOERROR" {
+		return nil, fmt.Errorf("DNS查询失败: %s", resp.RCode)
+	}
+
+	// 2. 检查CNAME记录
+	info.CNAMEChain = d.checkCNAME(resp.Answers)
+	for _, record := range info.CNAMEChain {
+		info.CNAMEs = append(info.CNAMEs, record.Target)
 	}
-	info.CNAMEs = cnames
 
-	// 2. 获取IP地址和TTL
-	ips, ttl, err := d.getIPsAndTTL()
+	// 3. 获取IP地址和TTL
+	ips, ttl, err := d.getIPsAndTTL(resp.Answers)
 	if err != nil {
 		return nil, fmt.Errorf("IP获取失败: %v", err)
 	}
 	info.IPs = ips
 	info.TTL = ttl
+	d.authoritativeTTL(ctx, info)
 
-	// 3. 检查是否为CDN
+	// 4. 检查是否为CDN
 	info.IsCDN = d.analyzeResults(info)
 
 	return info, nil
 }
 
-// checkCNAME 检查CNAME记录
-func (d *Detector) checkCNAME() ([]string, error) {
-	cnames, err := net.LookupCNAME(d.target)
-	if err != nil {
-		return nil, err
+// checkCNAME 从目标开始沿应答中的CNAME记录还原CNAME链
+func (d *Detector) checkCNAME(answers []utils.DNSRecord) []CNAMERecord {
+	var chain []CNAMERecord
+	name := strings.TrimSuffix(d.target, ".")
+	// 链的长度不超过应答记录数，避免CNAME环
+	for range answers {
+		next := false
+		for _, record := range answers {
+			if record.Type == "CNAME" && strings.EqualFold(record.Name, name) {
+				chain = append(chain, CNAMERecord{Name: record.Name, Target: record.Value, TTL: int(record.TTL)})
+				name = record.Value
+				next = true
+				break
+			}
+		}
+		if !next {
+			break
+		}
 	}
+	return chain
+}
 
-	var results []string
-	for _, cname := range strings.Split(cnames, "\n") {
-		if cname != "" {
-			results = append(results, strings.TrimSuffix(cname, "."))
+// getIPsAndTTL 获取IP地址和TTL值，TTL取A记录中的最小值
+func (d *Detector) getIPsAndTTL(answers []utils.DNSRecord) ([]string, int, error) {
+	var (
+		ips []string
+		ttl int
+	)
+	for _, record := range answers {
+		if record.Type != "A" {
+			continue
 		}
+		ips = append(ips, record.Value)
+		if len(ips) == 1 || int(record.TTL) < ttl {
+			ttl = int(record.TTL)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, 0, fmt.Errorf("%s 没有IPv4地址", d.target)
 	}
-	return results, nil
+	return ips, ttl, nil
 }
 
-// getIPsAndTTL 获取IP地址和TTL值
-func (d *Detector) getIPsAndTTL() ([]string, int, error) {
-	resolver := &net.Resolver{
-		PreferGo: true,
-		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-			d := net.Dialer{
-				Timeout: time.Second * 5,
+// authoritativeTTL 向权威服务器查询CNAME链上的每个名称，用区域中设置的TTL替换解析器返回的值。
+// 递归解析器返回的是缓存剩余时间，可能远小于区域设置的TTL，导致误判为CDN
+func (d *Detector) authoritativeTTL(ctx context.Context, info *CDNInfo) {
+	client := utils.NewDNSClient(d.queryTimeout())
+	client.NoRecursion = true
+
+	name := strings.TrimSuffix(d.target, ".")
+	for i := 0; i <= len(info.CNAMEChain); i++ {
+		if i > 0 {
+			name = info.CNAMEChain[i-1].Target
+		}
+		// 链中的名称取CNAME记录的TTL，链尾取A记录的最小TTL
+		want := dnsmessage.TypeA
+		if i < len(info.CNAMEChain) {
+			want = dnsmessage.TypeCNAME
+		}
+
+		ttl := -1
+		for _, record := range d.queryAuthoritative(ctx, client, name) {
+			if record.Header.Type == want && (ttl < 0 || int(record.Header.TTL) < ttl) {
+				ttl = int(record.Header.TTL)
 			}
-			return d.DialContext(ctx, network, "8.8.8.8:53")
-		},
+		}
+		if ttl < 0 {
+			continue
+		}
+		if i < len(info.CNAMEChain) {
+			info.CNAMEChain[i].TTL = ttl
+		} else {
+			info.TTL = ttl
+		}
 	}
+}
 
-	ips, err := resolver.LookupIP(context.Background(), "ip4", d.target)
+// queryAuthoritative 依次向名称所在区域的权威服务器查询A记录，返回第一个权威应答中属于该名称的记录
+func (d *Detector) queryAuthoritative(ctx context.Context, client *utils.DNSClient, name string) []dnsmessage.Resource {
+	servers, err := d.nameservers(ctx, name)
 	if err != nil {
-		return nil, 0, err
+		return nil
 	}
-
-	var ipStrings []string
-	for _, ip := range ips {
-		ipStrings = append(ipStrings, ip.String())
+	for _, server := range servers {
+		resp, err := client.Query(ctx, server, name, dnsmessage.TypeA)
+		if err != nil || !resp.Header.Authoritative || resp.Header.RCode != dnsmessage.RCodeSuccess {
+			continue
+		}
+		var records []dnsmessage.Resource
+		for _, record := range resp.Answers {
+			if strings.EqualFold(strings.TrimSuffix(record.Header.Name.String(), "."), name) {
+				records = append(records, record)
+			}
+		}
+		return records
 	}
+	return nil
+}
 
-	// 获取TTL值（这里使用模拟值，实际需要通过DNS查询获取）
-	ttl := 300 // 默认TTL值
+// nameservers 从名称本身开始逐级向上查找NS记录，返回所在区域权威服务器的 ip:port 列表
+func (d *Detector) nameservers(ctx context.Context, name string) ([]string, error) {
+	pool := d.resolverPool()
+	for zone := name; strings.Contains(zone, "."); zone = zone[strings.IndexByte(zone, '.')+1:] {
+		hosts, err := pool.LookupNS(ctx, zone)
+		if err != nil || len(hosts) == 0 {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
 
-	return ipStrings, ttl, nil
+		var servers []string
+		for _, host := range hosts {
+			ips, err := utils.LookupIP(ctx, pool, host)
+			if err != nil {
+				continue
+			}
+			for _, ip := range ips {
+				servers = append(servers, net.JoinHostPort(ip.String(), d.nsPort))
+			}
+		}
+		if len(servers) == 0 {
+			return nil, fmt.Errorf("无法解析 %s 的权威服务器地址", zone)
+		}
+		return servers, nil
+	}
+	return nil, fmt.Errorf("未找到 %s 的权威服务器", name)
 }
 
 // analyzeResults 分析结果判断是否为CDN
@@ -125,10 +238,15 @@ func (d *Detector) analyzeResults(info *CDNInfo) bool {
 		return true
 	}
 
-	// 3. 检查TTL值是否较小（CDN通常使用较小的TTL值）
-	if info.TTL < 300 {
+	// 3. 检查地址和CNAME链的TTL值是否较小（CDN通常使用较小的TTL值）
+	if info.TTL < shortTTL {
 		return true
 	}
+	for _, record := range info.CNAMEChain {
+		if record.TTL < shortTTL {
+			return true
+		}
+	}
 
 	return false
 }
@@ -146,6 +264,27 @@ func (d *Detector) SetTimeout(timeout time.Duration) {
 	d.timeout = timeout
 }
 
+// SetResolverPool 设置共享的解析器池，查询出错时换另一个解析器重试
+func (d *Detector) SetResolverPool(pool *utils.ResolverPool) {
+	d.pool = pool
+}
+
+// resolverPool 返回设置的解析器池，未设置时使用 8.8.8.8
+func (d *Detector) resolverPool() *utils.ResolverPool {
+	if d.pool == nil {
+		d.pool = utils.NewResolverPool([]string{"8.8.8.8"}, d.queryTimeout())
+	}
+	return d.pool
+}
+
+// queryTimeout 返回单次查询的超时时间，默认5秒
+func (d *Detector) queryTimeout() time.Duration {
+	if d.timeout <= 0 {
+		return time.Second * 5
+	}
+	return d.timeout
+}
+
 // matchCDNPattern 检查CNAME是否匹配CDN模式
 func (d *Detector) matchCDNPattern(cname string) bool {
 	for _, keyword := range d.cdnCNAMEKeywords {
@@ -1,9 +1,106 @@
 package cdn
 
 import (
+	"context"
+	"net"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/Marryname/WebScanner/internal/dnstest"
+	"github.com/Marryname/WebScanner/pkg/utils"
+	"golang.org/x/net/dns/dnsmessage"
 )
 
+func TestDetectTTL(t *testing.T) {
+	// zone 测试区域，cached 为true时TTL为递归解析器缓存的剩余时间
+	zone := func(cached bool) *dnstest.Zone {
+		ttl := func(ttl uint32) uint32 {
+			if cached {
+				return ttl / 30
+			}
+			return ttl
+		}
+		return &dnstest.Zone{Records: []dnsmessage.Resource{
+			dnstest.NS("example.com", 86400, "ns1.example.com"),
+			dnstest.NS("example.net", 86400, "ns1.example.com"),
+			dnstest.NS("example.org", 86400, "ns1.example.com"),
+			dnstest.A("ns1.example.com", 86400, "127.0.0.1"),
+			dnstest.A("short.example.com", ttl(60), "192.0.2.1"),
+			dnstest.A("static.example.com", ttl(3600), "192.0.2.2"),
+			dnstest.A("static.example.com", ttl(3600), "192.0.2.3"),
+			dnstest.CNAME("www.example.com", ttl(3600), "www.example.net"),
+			dnstest.CNAME("www.example.net", ttl(30), "edge.example.org"),
+			dnstest.A("edge.example.org", ttl(3600), "192.0.2.4"),
+			dnstest.AAAA("v6.example.com", ttl(3600), "2001:db8::1"),
+		}}
+	}
+	pool := utils.NewResolverPool([]string{dnstest.Serve(t, zone(true))}, time.Second)
+
+	// 权威服务器不提供递归，只应答不带RD标志的查询
+	authoritative := zone(false)
+	server := dnstest.Serve(t, dnstest.HandlerFunc(func(query *dnsmessage.Message, tcp bool) []dnsmessage.Message {
+		if query.Header.RecursionDesired {
+			return []dnsmessage.Message{{
+				Header:    dnsmessage.Header{ID: query.Header.ID, Response: true, RCode: dnsmessage.RCodeRefused},
+				Questions: query.Questions,
+			}}
+		}
+		return authoritative.Answer(query, tcp)
+	}))
+	_, nsPort, _ := net.SplitHostPort(server)
+
+	tests := []struct {
+		target    string
+		wantCDN   bool
+		wantTTL   int
+		wantIPs   []string
+		wantChain []CNAMERecord
+		wantErr   bool
+	}{
+		{target: "short.example.com", wantCDN: true, wantTTL: 60, wantIPs: []string{"192.0.2.1"}},
+		{target: "static.example.com", wantCDN: false, wantTTL: 3600, wantIPs: []string{"192.0.2.2", "192.0.2.3"}},
+		{
+			// 地址的TTL较大，但CNAME链中有一跳的TTL较小
+			target:  "www.example.com",
+			wantCDN: true,
+			wantTTL: 3600,
+			wantIPs: []string{"192.0.2.4"},
+			wantChain: []CNAMERecord{
+				{Name: "www.example.com", Target: "www.example.net", TTL: 3600},
+				{Name: "www.example.net", Target: "edge.example.org", TTL: 30},
+			},
+		},
+		{target: "v6.example.com", wantErr: true},
+		{target: "missing.example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			detector := NewDetector(tt.target)
+			detector.SetResolverPool(pool)
+			detector.nsPort = nsPort
+
+			info, err := detector.Detect(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Detect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if info.IsCDN != tt.wantCDN || info.TTL != tt.wantTTL || !reflect.DeepEqual(info.IPs, tt.wantIPs) {
+				t.Errorf("Detect() = %+v", info)
+			}
+			if !reflect.DeepEqual(info.CNAMEChain, tt.wantChain) {
+				t.Errorf("CNAMEChain = %+v, want %+v", info.CNAMEChain, tt.wantChain)
+			}
+			if len(info.CNAMEs) != len(tt.wantChain) {
+				t.Errorf("CNAMEs = %v", info.CNAMEs)
+			}
+		})
+	}
+}
+
 func TestCDNDetector(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -42,7 +139,7 @@ func TestCDNDetector(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			detector := NewDetector(tt.target)
-			info, err := detector.Detect()
+			info, err := detector.Detect(context.Background())
 			if err != nil {
 				t.Errorf("CDN检测失败: %v", err)
 				return
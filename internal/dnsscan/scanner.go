@@ -0,0 +1,233 @@
+package dnsscan
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Marryname/WebScanner/pkg/common"
+	"github.com/Marryname/WebScanner/pkg/utils"
+)
+
+// DefaultTypes 默认查询的记录类型，PTR 表示对目标的地址做反向解析
+var DefaultTypes = []string{"A", "AAAA", "CNAME", "MX", "NS", "TXT", "SOA", "CAA", "PTR"}
+
+// DefaultServers 未指定DNS服务器时使用的公共DNS
+var DefaultServers = []string{"223.5.5.5", "8.8.8.8"}
+
+// ScanResult 目标的DNS记录，Records 为全部应答记录去重后的列表，
+// Responses 按查询顺序保存每次查询的完整响应
+type ScanResult struct {
+	Target    string               `json:"target"`
+	Records   []utils.DNSRecord    `json:"records"`
+	Responses []*utils.DNSResponse `json:"responses"`
+}
+
+// Values 返回指定类型记录的值
+func (r *ScanResult) Values(recordType string) []string {
+	var values []string
+	for _, record := range r.Records {
+		if record.Type == recordType {
+			values = append(values, record.Value)
+		}
+	}
+	return values
+}
+
+// Scanner DNS记录收集
+type Scanner struct {
+	target  string
+	servers []string
+	types   []string
+	client  *utils.DNSClient
+	limiter *utils.RateLimiter
+}
+
+// NewScanner 创建新的DNS记录扫描器
+func NewScanner(target string, timeout time.Duration) *Scanner {
+	return &Scanner{
+		target:  strings.ToLower(strings.TrimSuffix(target, ".")),
+		servers: DefaultServers,
+		types:   DefaultTypes,
+		client:  utils.NewDNSClient(timeout),
+	}
+}
+
+// SetServers 设置DNS服务器，地址可以是IP、host:port 或DoH/DoT地址，查询出错时依次换下一个
+func (s *Scanner) SetServers(servers []string) {
+	if len(servers) > 0 {
+		s.servers = servers
+	}
+}
+
+// SetTypes 设置查询的记录类型，不支持的类型返回错误
+func (s *Scanner) SetTypes(types []string) error {
+	if len(types) == 0 {
+		return nil
+	}
+	normalized := make([]string, 0, len(types))
+	for _, t := range types {
+		if _, err := utils.ParseDNSType(t); err != nil {
+			return err
+		}
+		normalized = append(normalized, strings.ToUpper(t))
+	}
+	s.types = normalized
+	return nil
+}
+
+// SetTCP 设置是否总是通过TCP查询
+func (s *Scanner) SetTCP(tcp bool) {
+	s.client.TCP = tcp
+}
+
+// SetRateLimiter 设置共享的速率限制器，按DNS服务器地址限速
+func (s *Scanner) SetRateLimiter(limiter *utils.RateLimiter) {
+	s.limiter = limiter
+}
+
+// Scan 并发查询目标的各类记录，目标为IP时只做反向解析。
+// 开启PTR时对查到的A/AAAA地址做反向解析。所有查询都失败时返回错误
+func (s *Scanner) Scan(ctx context.Context) (*ScanResult, error) {
+	result := &ScanResult{Target: s.target}
+
+	if net.ParseIP(s.target) != nil {
+		name, err := utils.ReverseName(s.target)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.lookupAll(ctx, result, []string{name}, "PTR"); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	if !common.IsValidDomain(s.target) {
+		return nil, fmt.Errorf("无效的域名: %s", s.target)
+	}
+
+	var forward []string
+	reverse := false
+	for _, t := range s.types {
+		if t == "PTR" {
+			reverse = true
+		} else {
+			forward = append(forward, t)
+		}
+	}
+	if err := s.lookupAll(ctx, result, []string{s.target}, forward...); err != nil {
+		return nil, err
+	}
+
+	if reverse {
+		var names []string
+		for _, ip := range append(result.Values("A"), result.Values("AAAA")...) {
+			if name, err := utils.ReverseName(ip); err == nil {
+				names = append(names, name)
+			}
+		}
+		// 反向解析失败不影响已得到的记录
+		if err := s.lookupAll(ctx, result, names, "PTR"); err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// lookupAll 并发查询每个名称的各个类型，响应按名称和类型的顺序追加到结果中。
+// 全部查询都失败时返回最后一个错误
+func (s *Scanner) lookupAll(ctx context.Context, result *ScanResult, names []string, types ...string) error {
+	type query struct {
+		name  string
+		qtype string
+	}
+	var queries []query
+	for _, name := range names {
+		for _, t := range types {
+			queries = append(queries, query{name, t})
+		}
+	}
+	if len(queries) == 0 {
+		return nil
+	}
+
+	var (
+		mu      sync.Mutex
+		lastErr error
+	)
+	responses := make([]*utils.DNSResponse, len(queries))
+	group := utils.NewTaskGroup(ctx, len(queries), false)
+	for i, q := range queries {
+		i, q := i, q
+		group.Go(func(ctx context.Context) error {
+			resp, err := s.lookup(ctx, q.name, q.qtype)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				lastErr = err
+				return nil
+			}
+			responses[i] = resp
+			return nil
+		})
+	}
+	group.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, resp := range responses {
+		if resp == nil {
+			failed++
+			continue
+		}
+		result.Responses = append(result.Responses, resp)
+		for _, record := range resp.Answers {
+			if !containsRecord(result.Records, record) {
+				result.Records = append(result.Records, record)
+			}
+		}
+	}
+	if failed == len(queries) {
+		return fmt.Errorf("DNS查询全部失败: %v", lastErr)
+	}
+	return nil
+}
+
+// lookup 依次使用各DNS服务器查询，服务器无响应时换下一个
+func (s *Scanner) lookup(ctx context.Context, name, qtype string) (*utils.DNSResponse, error) {
+	t, err := utils.ParseDNSType(qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, server := range s.servers {
+		if err := s.limiter.Wait(ctx, server); err != nil {
+			return nil, err
+		}
+		resp, err := s.client.Lookup(ctx, server, name, t)
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// containsRecord 判断列表中是否已有相同名称、类型和值的记录
+func containsRecord(records []utils.DNSRecord, record utils.DNSRecord) bool {
+	for _, r := range records {
+		if r.Name == record.Name && r.Type == record.Type && r.Value == record.Value {
+			return true
+		}
+	}
+	return false
+}
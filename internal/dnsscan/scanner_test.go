@@ -0,0 +1,105 @@
+package dnsscan
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Marryname/WebScanner/internal/dnstest"
+	"github.com/Marryname/WebScanner/pkg/utils"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestScanner(t *testing.T) {
+	server := dnstest.Serve(t, &dnstest.Zone{Records: []dnsmessage.Resource{
+		dnstest.A("example.com", 300, "192.0.2.1"),
+		dnstest.RR("example.com", 300, &dnsmessage.MXResource{Pref: 10, MX: dnsmessage.MustNewName("mail.example.com.")}),
+		dnstest.RR("example.com", 300, &dnsmessage.TXTResource{TXT: []string{"v=spf1 -all"}}),
+		dnstest.RR("1.2.0.192.in-addr.arpa", 300, &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("www.example.com.")}),
+	}})
+	// 无响应的服务器不影响结果，查询会换到下一个服务器
+	unused, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unused.Close()
+
+	tests := []struct {
+		name    string
+		target  string
+		types   []string
+		want    []utils.DNSRecord
+		wantErr bool
+	}{
+		{
+			name:   "测试域名",
+			target: "Example.com.",
+			types:  []string{"a", "MX", "TXT", "AAAA", "PTR"},
+			want: []utils.DNSRecord{
+				{Name: "example.com", Type: "A", TTL: 300, Value: "192.0.2.1"},
+				{Name: "example.com", Type: "MX", TTL: 300, Value: "10 mail.example.com"},
+				{Name: "example.com", Type: "TXT", TTL: 300, Value: "v=spf1 -all"},
+				{Name: "1.2.0.192.in-addr.arpa", Type: "PTR", TTL: 300, Value: "www.example.com"},
+			},
+		},
+		{
+			name:   "测试IP",
+			target: "192.0.2.1",
+			want: []utils.DNSRecord{
+				{Name: "1.2.0.192.in-addr.arpa", Type: "PTR", TTL: 300, Value: "www.example.com"},
+			},
+		},
+		{
+			name:    "测试无效域名",
+			target:  "invalid-domain",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := NewScanner(tt.target, 200*time.Millisecond)
+			scanner.SetServers([]string{unused.LocalAddr().String(), server})
+			if err := scanner.SetTypes(tt.types); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := scanner.Scan(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(result.Records, tt.want) {
+				t.Errorf("Records = %+v, want %+v", result.Records, tt.want)
+			}
+			for _, resp := range result.Responses {
+				if len(resp.Raw) == 0 || resp.Server != server {
+					t.Errorf("response = %+v, want 原始响应和服务器地址", resp)
+				}
+			}
+		})
+	}
+}
+
+func TestScannerTypes(t *testing.T) {
+	scanner := NewScanner("example.com", time.Second)
+	if err := scanner.SetTypes([]string{"A", "ANY"}); err == nil {
+		t.Error("SetTypes() 对不支持的类型应返回错误")
+	}
+}
+
+func TestScannerWithContext(t *testing.T) {
+	scanner := NewScanner("example.com", time.Second)
+
+	// 测试上下文取消
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := scanner.Scan(ctx); err == nil {
+		t.Error("Scan() 在上下文取消时应返回错误")
+	}
+}
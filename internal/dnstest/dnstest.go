@@ -0,0 +1,329 @@
+// Package dnstest 提供各模块测试共用的DNS服务器，按测试区域中的记录应答查询
+package dnstest
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// typeNSEC NSEC记录的类型值，dnsmessage 没有定义
+const typeNSEC dnsmessage.Type = 47
+
+// Handler 按查询构造响应报文，AXFR等查询可以返回多个报文，返回空时不响应
+type Handler interface {
+	Answer(query *dnsmessage.Message, tcp bool) []dnsmessage.Message
+}
+
+// HandlerFunc 把函数作为 Handler 使用
+type HandlerFunc func(query *dnsmessage.Message, tcp bool) []dnsmessage.Message
+
+// Answer 调用 f(query, tcp)
+func (f HandlerFunc) Answer(query *dnsmessage.Message, tcp bool) []dnsmessage.Message {
+	return f(query, tcp)
+}
+
+// Serve 启动测试DNS服务器，UDP和TCP监听同一端口，返回服务器地址。
+// h 为nil时服务器不响应任何查询，测试结束时自动关闭
+func Serve(t testing.TB, h Handler) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	conn, err := net.ListenPacket("udp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if h == nil {
+		return conn.LocalAddr().String()
+	}
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			for _, reply := range answer(h, buf[:n], false) {
+				conn.WriteTo(reply, addr)
+			}
+		}
+	}()
+	go ServeStream(ln, h)
+
+	return conn.LocalAddr().String()
+}
+
+// ServeStream 在TCP或TLS监听器上按TCP格式(两字节长度前缀)应答查询，一个连接可以发送多个查询，
+// 监听器关闭后返回
+func ServeStream(ln net.Listener, h Handler) {
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			for {
+				var length [2]byte
+				if _, err := io.ReadFull(c, length[:]); err != nil {
+					return
+				}
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(c, query); err != nil {
+					return
+				}
+				for _, reply := range answer(h, query, true) {
+					binary.BigEndian.PutUint16(length[:], uint16(len(reply)))
+					c.Write(append(length[:], reply...))
+				}
+			}
+		}()
+	}
+}
+
+// Reply 返回查询的第一个响应报文，用于DoH等一问一答的传输方式，查询无法解析时返回nil
+func Reply(h Handler, query []byte, tcp bool) []byte {
+	if replies := answer(h, query, tcp); len(replies) > 0 {
+		return replies[0]
+	}
+	return nil
+}
+
+// answer 解析查询并打包 h 返回的响应报文
+func answer(h Handler, query []byte, tcp bool) [][]byte {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil || len(msg.Questions) != 1 {
+		return nil
+	}
+	var replies [][]byte
+	for _, resp := range h.Answer(&msg, tcp) {
+		if packed, err := resp.Pack(); err == nil {
+			replies = append(replies, packed)
+		}
+	}
+	return replies
+}
+
+// Zone 测试区域，按名称和类型应答查询并跟随CNAME链。名称不存在时返回NXDOMAIN，
+// 名称存在但没有所查类型的记录时返回空应答
+type Zone struct {
+	// Records 区域中的记录，名称可以是 *.example.com 形式的泛解析记录，只匹配下一级名称
+	Records []dnsmessage.Resource
+	// Transfer 允许通过TCP进行AXFR区域传送的区域顶点，其他区域的传送请求返回REFUSED
+	Transfer []string
+	// TCPOnly 通过UDP查询这些类型时只返回TC标志，客户端需要改用TCP
+	TCPOnly []dnsmessage.Type
+	// RCode 不为成功时所有查询都返回该响应码
+	RCode dnsmessage.RCode
+}
+
+// RR 构造一条记录，name 可以不带末尾的点
+func RR(name string, ttl uint32, body dnsmessage.ResourceBody) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: fqdn(name), Type: bodyType(body), Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   body,
+	}
+}
+
+// A 构造A记录
+func A(name string, ttl uint32, ip string) dnsmessage.Resource {
+	var a [4]byte
+	copy(a[:], net.ParseIP(ip).To4())
+	return RR(name, ttl, &dnsmessage.AResource{A: a})
+}
+
+// AAAA 构造AAAA记录
+func AAAA(name string, ttl uint32, ip string) dnsmessage.Resource {
+	var aaaa [16]byte
+	copy(aaaa[:], net.ParseIP(ip).To16())
+	return RR(name, ttl, &dnsmessage.AAAAResource{AAAA: aaaa})
+}
+
+// CNAME 构造CNAME记录
+func CNAME(name string, ttl uint32, target string) dnsmessage.Resource {
+	return RR(name, ttl, &dnsmessage.CNAMEResource{CNAME: fqdn(target)})
+}
+
+// NS 构造NS记录
+func NS(name string, ttl uint32, host string) dnsmessage.Resource {
+	return RR(name, ttl, &dnsmessage.NSResource{NS: fqdn(host)})
+}
+
+// NSEC 构造NSEC记录，RDATA为未压缩的下一个名称和只包含A、RRSIG、NSEC的类型位图
+func NSEC(name string, ttl uint32, next string) dnsmessage.Resource {
+	var data []byte
+	for _, label := range strings.Split(strings.TrimSuffix(next, "."), ".") {
+		data = append(data, byte(len(label)))
+		data = append(data, label...)
+	}
+	data = append(data, 0, 0, 6, 0x40, 0, 0, 0, 0, 0x03)
+	return RR(name, ttl, &dnsmessage.UnknownResource{Type: typeNSEC, Data: data})
+}
+
+// Answer 实现 Handler
+func (z *Zone) Answer(query *dnsmessage.Message, tcp bool) []dnsmessage.Message {
+	q := query.Questions[0]
+	reply := func(rcode dnsmessage.RCode, answers []dnsmessage.Resource) dnsmessage.Message {
+		return dnsmessage.Message{
+			Header: dnsmessage.Header{
+				ID:                 query.Header.ID,
+				Response:           true,
+				Authoritative:      true,
+				RecursionDesired:   query.Header.RecursionDesired,
+				RecursionAvailable: true,
+				RCode:              rcode,
+			},
+			Questions: query.Questions,
+			Answers:   answers,
+		}
+	}
+
+	if z.RCode != dnsmessage.RCodeSuccess {
+		return []dnsmessage.Message{reply(z.RCode, nil)}
+	}
+	if q.Type == dnsmessage.TypeAXFR {
+		return z.transfer(q, tcp, reply)
+	}
+	for _, t := range z.TCPOnly {
+		if !tcp && q.Type == t {
+			resp := reply(dnsmessage.RCodeSuccess, nil)
+			resp.Header.Truncated = true
+			return []dnsmessage.Message{resp}
+		}
+	}
+
+	var answers []dnsmessage.Resource
+	name := q.Name
+	for depth := 0; depth < 8; depth++ {
+		records := z.lookup(name)
+		if len(records) == 0 {
+			if depth == 0 {
+				return []dnsmessage.Message{reply(dnsmessage.RCodeNameError, nil)}
+			}
+			break
+		}
+
+		var cname *dnsmessage.Resource
+		var matched []dnsmessage.Resource
+		for i, rr := range records {
+			switch rr.Header.Type {
+			case q.Type:
+				matched = append(matched, rr)
+			case dnsmessage.TypeCNAME:
+				cname = &records[i]
+			}
+		}
+		// 没有所查类型的记录时才跟随CNAME
+		if len(matched) == 0 && cname != nil {
+			answers = append(answers, *cname)
+			name = cname.Body.(*dnsmessage.CNAMEResource).CNAME
+			continue
+		}
+		answers = append(answers, matched...)
+		break
+	}
+	return []dnsmessage.Message{reply(dnsmessage.RCodeSuccess, answers)}
+}
+
+// lookup 返回名称的全部记录，名称没有记录时使用上一级的泛解析记录，记录名称替换为查询的名称
+func (z *Zone) lookup(name dnsmessage.Name) []dnsmessage.Resource {
+	key := strings.ToLower(name.String())
+	var records []dnsmessage.Resource
+	for _, rr := range z.Records {
+		if strings.ToLower(rr.Header.Name.String()) == key {
+			records = append(records, rr)
+		}
+	}
+	if len(records) > 0 {
+		return records
+	}
+
+	i := strings.IndexByte(key, '.')
+	if i < 0 || i == len(key)-1 {
+		return nil
+	}
+	wildcard := "*" + key[i:]
+	for _, rr := range z.Records {
+		if strings.ToLower(rr.Header.Name.String()) == wildcard {
+			rr.Header.Name = name
+			records = append(records, rr)
+		}
+	}
+	return records
+}
+
+// transfer 响应AXFR查询，区域内的记录按名称排序后分两个报文返回，首尾为SOA记录。
+// 只允许通过TCP传送 Transfer 中的区域
+func (z *Zone) transfer(q dnsmessage.Question, tcp bool,
+	reply func(dnsmessage.RCode, []dnsmessage.Resource) dnsmessage.Message) []dnsmessage.Message {
+	zone := strings.ToLower(strings.TrimSuffix(q.Name.String(), "."))
+	allowed := false
+	for _, apex := range z.Transfer {
+		allowed = allowed || strings.EqualFold(strings.TrimSuffix(apex, "."), zone)
+	}
+	if !tcp || !allowed {
+		return []dnsmessage.Message{reply(dnsmessage.RCodeRefused, nil)}
+	}
+
+	soa := RR(zone, 300, &dnsmessage.SOAResource{
+		NS: fqdn("ns1." + zone), MBox: fqdn("admin." + zone),
+		Serial: 1, Refresh: 3600, Retry: 600, Expire: 86400, MinTTL: 300,
+	})
+	var answers []dnsmessage.Resource
+	for _, rr := range z.Records {
+		name := strings.ToLower(rr.Header.Name.String())
+		if strings.HasSuffix(name, "."+zone+".") && !strings.HasPrefix(name, "*") {
+			answers = append(answers, rr)
+		}
+	}
+	sort.SliceStable(answers, func(i, j int) bool {
+		return strings.ToLower(answers[i].Header.Name.String()) < strings.ToLower(answers[j].Header.Name.String())
+	})
+
+	half := len(answers) / 2
+	return []dnsmessage.Message{
+		reply(dnsmessage.RCodeSuccess, append([]dnsmessage.Resource{soa}, answers[:half]...)),
+		reply(dnsmessage.RCodeSuccess, append(answers[half:], soa)),
+	}
+}
+
+// bodyType 返回记录数据对应的类型
+func bodyType(body dnsmessage.ResourceBody) dnsmessage.Type {
+	switch b := body.(type) {
+	case *dnsmessage.AResource:
+		return dnsmessage.TypeA
+	case *dnsmessage.AAAAResource:
+		return dnsmessage.TypeAAAA
+	case *dnsmessage.CNAMEResource:
+		return dnsmessage.TypeCNAME
+	case *dnsmessage.NSResource:
+		return dnsmessage.TypeNS
+	case *dnsmessage.MXResource:
+		return dnsmessage.TypeMX
+	case *dnsmessage.TXTResource:
+		return dnsmessage.TypeTXT
+	case *dnsmessage.SOAResource:
+		return dnsmessage.TypeSOA
+	case *dnsmessage.PTRResource:
+		return dnsmessage.TypePTR
+	case *dnsmessage.SRVResource:
+		return dnsmessage.TypeSRV
+	case *dnsmessage.UnknownResource:
+		return b.Type
+	}
+	return 0
+}
+
+// fqdn 把域名转换为带末尾点的 dnsmessage.Name
+func fqdn(name string) dnsmessage.Name {
+	return dnsmessage.MustNewName(strings.TrimSuffix(name, ".") + ".")
+}
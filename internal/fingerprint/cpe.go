@@ -0,0 +1,170 @@
+package fingerprint
+
+import (
+	"net/url"
+	"strings"
+)
+
+// CPE 2.3 格式化字符串的属性数量(part到other)
+const cpeAttributes = 11
+
+// ToCPE23 把CPE 2.2 URI(cpe:/a:vendor:product:version)转换为
+// CPE 2.3 格式化字符串，缺省属性以 * 填充；已是2.3格式时补齐属性。
+// 无法识别的输入返回空字符串
+func ToCPE23(cpe string) string {
+	var attrs []string
+	switch {
+	case strings.HasPrefix(cpe, "cpe:2.3:"):
+		attrs = splitCPE23(strings.TrimPrefix(cpe, "cpe:2.3:"))
+	case strings.HasPrefix(cpe, "cpe:/"):
+		attrs = parseCPEURI(strings.TrimPrefix(cpe, "cpe:/"))
+	default:
+		return ""
+	}
+
+	if len(attrs) == 0 || len(attrs) > cpeAttributes {
+		return ""
+	}
+	switch attrs[0] {
+	case "a", "o", "h":
+	default:
+		return ""
+	}
+
+	for len(attrs) < cpeAttributes {
+		attrs = append(attrs, "*")
+	}
+	return "cpe:2.3:" + strings.Join(attrs, ":")
+}
+
+// parseCPEURI 解析URI形式的属性，解码百分号编码并展开打包的edition字段
+func parseCPEURI(uri string) []string {
+	values := strings.Split(uri, ":")
+	if len(values) > 7 {
+		return nil
+	}
+
+	var packed []string
+	attrs := make([]string, 0, cpeAttributes)
+	for i, value := range values {
+		if decoded, err := url.PathUnescape(value); err == nil {
+			value = decoded
+		}
+
+		// edition以~开头时打包了edition、sw_edition、target_sw、target_hw和other
+		if i == 5 && strings.HasPrefix(value, "~") {
+			packed = strings.Split(value[1:], "~")
+			if len(packed) != 5 {
+				return nil
+			}
+			value = packed[0]
+		}
+		attrs = append(attrs, bindCPEValue(value))
+	}
+
+	if packed != nil {
+		for len(attrs) < 7 {
+			attrs = append(attrs, "*")
+		}
+		for _, value := range packed[1:] {
+			attrs = append(attrs, bindCPEValue(value))
+		}
+	}
+	return attrs
+}
+
+// splitCPE23 按未转义的冒号拆分2.3格式的属性
+func splitCPE23(s string) []string {
+	var (
+		attrs []string
+		cur   strings.Builder
+	)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			cur.WriteByte(s[i])
+			cur.WriteByte(s[i+1])
+			i++
+		case s[i] == ':':
+			attrs = append(attrs, emptyToAny(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(s[i])
+		}
+	}
+	return append(attrs, emptyToAny(cur.String()))
+}
+
+// bindCPEValue 按2.3格式规范化属性值：转为小写，空白替换为下划线，
+// 除字母、数字、-、.、_ 以外的字符加反斜杠转义；空值表示任意值
+func bindCPEValue(value string) string {
+	if value == "" || value == "*" {
+		return "*"
+	}
+	if value == "-" {
+		return "-"
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(value)) {
+		switch {
+		case r == ' ' || r == '\t':
+			b.WriteByte('_')
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.', r == '_', r > 0x7f:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// emptyToAny 空属性表示任意值
+func emptyToAny(value string) string {
+	if value == "" {
+		return "*"
+	}
+	return value
+}
+
+// cpeAttribute 返回2.3格式字符串中指定位置的属性，0为part，1为vendor，2为product，
+// 任意值和不适用值返回空字符串
+func cpeAttribute(cpe string, index int) string {
+	attrs := splitCPE23(strings.TrimPrefix(cpe, "cpe:2.3:"))
+	if index >= len(attrs) || attrs[index] == "*" || attrs[index] == "-" {
+		return ""
+	}
+	return strings.ReplaceAll(attrs[index], "\\", "")
+}
+
+// primaryCPE 选出结果使用的CPE，优先选择应用程序(part为a)，并转换为2.3格式
+func primaryCPE(cpes []string) string {
+	var first string
+	for _, cpe := range cpes {
+		converted := ToCPE23(cpe)
+		if converted == "" {
+			continue
+		}
+		if cpeAttribute(converted, 0) == "a" {
+			return converted
+		}
+		if first == "" {
+			first = converted
+		}
+	}
+	return first
+}
+
+// cpeWithVersion 为未指定版本的2.3格式CPE填入版本
+func cpeWithVersion(cpe, version string) string {
+	if cpe == "" || version == "" {
+		return cpe
+	}
+	attrs := splitCPE23(strings.TrimPrefix(cpe, "cpe:2.3:"))
+	if len(attrs) != cpeAttributes || attrs[3] != "*" {
+		return cpe
+	}
+	attrs[3] = bindCPEValue(version)
+	return "cpe:2.3:" + strings.Join(attrs, ":")
+}
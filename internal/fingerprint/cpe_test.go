@@ -0,0 +1,44 @@
+package fingerprint
+
+import "testing"
+
+func TestToCPE23(t *testing.T) {
+	tests := []struct {
+		name string
+		cpe  string
+		want string
+	}{
+		{"URI格式", "cpe:/a:openbsd:openssh:8.2p1", "cpe:2.3:a:openbsd:openssh:8.2p1:*:*:*:*:*:*:*"},
+		{"只有厂商", "cpe:/o:microsoft:windows", "cpe:2.3:o:microsoft:windows:*:*:*:*:*:*:*:*"},
+		{"空属性", "cpe:/a:apache:http_server::beta", "cpe:2.3:a:apache:http_server:*:beta:*:*:*:*:*:*"},
+		{"百分号编码和特殊字符", "cpe:/a:acme:my%20app:1.0%2b1", "cpe:2.3:a:acme:my_app:1.0\\+1:*:*:*:*:*:*:*"},
+		{"打包的edition", "cpe:/a:acme:app:1.0:sp1:~~pro~linux~x64~:en", "cpe:2.3:a:acme:app:1.0:sp1:*:en:pro:linux:x64:*"},
+		{"大写转小写", "cpe:/a:Elastic:Elasticsearch:7.10.2", "cpe:2.3:a:elastic:elasticsearch:7.10.2:*:*:*:*:*:*:*"},
+		{"补齐2.3格式", "cpe:2.3:a:f5:nginx:1.18.0", "cpe:2.3:a:f5:nginx:1.18.0:*:*:*:*:*:*:*"},
+		{"保留2.3转义", "cpe:2.3:a:acme:app\\:x:1.0:*:*:*:*:*:*:*", "cpe:2.3:a:acme:app\\:x:1.0:*:*:*:*:*:*:*"},
+		{"无效part", "cpe:/x:acme:app", ""},
+		{"非CPE", "openssh 8.2", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToCPE23(tt.cpe); got != tt.want {
+				t.Errorf("ToCPE23(%q) = %q, want %q", tt.cpe, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrimaryCPE(t *testing.T) {
+	got := primaryCPE([]string{"cpe:/o:microsoft:windows", "cpe:/a:microsoft:internet_information_services:10.0"})
+	want := "cpe:2.3:a:microsoft:internet_information_services:10.0:*:*:*:*:*:*:*"
+	if got != want {
+		t.Errorf("primaryCPE() = %q, want %q", got, want)
+	}
+	if vendor := cpeAttribute(got, 1); vendor != "microsoft" {
+		t.Errorf("cpeAttribute() = %q, want microsoft", vendor)
+	}
+	if got := primaryCPE(nil); got != "" {
+		t.Errorf("primaryCPE(nil) = %q", got)
+	}
+}
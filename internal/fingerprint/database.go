@@ -1,88 +1,282 @@
 package fingerprint
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Signature 服务签名，Pattern按字节匹配banner，
+// Product、Vendor、Version、OS和CPE为模板，可通过 $1、$P(1) 等引用捕获组
+type Signature struct {
+	Service string `json:"service" yaml:"service"`
+	Ports   []int  `json:"ports,omitempty" yaml:"ports,omitempty"`
+	Pattern string `json:"pattern" yaml:"pattern"`
+	Product string `json:"product,omitempty" yaml:"product,omitempty"`
+	Vendor  string `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	OS      string `json:"os,omitempty" yaml:"os,omitempty"`
+	CPE     string `json:"cpe,omitempty" yaml:"cpe,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// signatureFile 签名文件的结构
+type signatureFile struct {
+	Signatures []*Signature `json:"signatures" yaml:"signatures"`
+}
+
+// 模板中引用的捕获组编号
+var templateGroupPattern = regexp.MustCompile(`\$(?:P\(|SUBST\(|I\()?(\d+)`)
+
+// 识别依据
+const (
+	EvidenceBannerRegex = "banner-regex"
+	EvidencePortDefault = "port-default"
+	EvidenceTLS         = "probe:TLS"
+	// 探针匹配的依据为前缀加探针名称，如 probe:GetRequest
+	EvidenceProbePrefix = "probe:"
 )
 
+// 各类识别依据的置信度
+const (
+	ConfidenceProbe     = 0.9
+	ConfidenceBanner    = 0.7
+	ConfidenceSoftProbe = 0.5
+	ConfidencePort      = 0.2
+)
+
+// builtinSignatures 内置签名，带版本的签名在前。服务名称使用 nmap 的小写名称，与探针匹配的结果一致
+var builtinSignatures = []Signature{
+	{Service: "http", Pattern: `Apache/(\d+\.\d+\.\d+)`, Product: "Apache httpd", Version: "$1", CPE: "cpe:/a:apache:http_server:$1"},
+	{Service: "http", Pattern: `nginx/(\d+\.\d+\.\d+)`, Product: "nginx", Version: "$1", CPE: "cpe:/a:igor_sysoev:nginx:$1"},
+	{Service: "ssh", Pattern: `OpenSSH_(\d+\.\d+\w*)`, Product: "OpenSSH", Version: "$1", CPE: "cpe:/a:openbsd:openssh:$1"},
+	{Service: "http", Ports: []int{80, 443}, Pattern: `(?i)HTTP|Server:|Apache|nginx|IIS`},
+	{Service: "ssh", Ports: []int{22}, Pattern: `(?i)SSH|OpenSSH`},
+	{Service: "ftp", Ports: []int{21}, Pattern: `(?i)FTP|FileZilla|vsftpd`},
+	{Service: "smtp", Ports: []int{25}, Pattern: `(?i)SMTP|Postfix|Exchange`},
+	{Service: "mysql", Ports: []int{3306}, Pattern: `(?i)MySQL`},
+	{Service: "redis", Ports: []int{6379}, Pattern: `(?i)Redis`},
+	{Service: "mongodb", Ports: []int{27017}, Pattern: `(?i)MongoDB`},
+}
+
 // Database 服务识别数据库
 type Database struct {
-	servicePatterns map[string][]string
-	versionPatterns map[string]*regexp.Regexp
+	// 加载的签名优先于内置签名
+	custom     []*Signature
+	builtin    []*Signature
+	signatures []*Signature
 }
 
-// NewDatabase 创建新的服务识别数据库
+// NewDatabase 创建只包含内置签名的服务识别数据库
 func NewDatabase() *Database {
-	db := &Database{
-		servicePatterns: make(map[string][]string),
-		versionPatterns: make(map[string]*regexp.Regexp),
-	}
+	db := &Database{}
 
-	// 初始化服务识别模式
-	db.initServicePatterns()
-	db.initVersionPatterns()
+	for i := range builtinSignatures {
+		sig := builtinSignatures[i]
+		if err := sig.compile(); err != nil {
+			panic(fmt.Sprintf("内置签名 %s 无效: %v", sig.Service, err))
+		}
+		db.builtin = append(db.builtin, &sig)
+	}
+	db.merge()
 
 	return db
 }
 
-// initServicePatterns 初始化服务识别模式
-func (db *Database) initServicePatterns() {
-	db.servicePatterns = map[string][]string{
-		"HTTP":    {"HTTP", "Server:", "Apache", "nginx", "IIS"},
-		"SSH":     {"SSH", "OpenSSH"},
-		"FTP":     {"FTP", "FileZilla", "vsftpd"},
-		"SMTP":    {"SMTP", "Postfix", "Exchange"},
-		"MySQL":   {"MySQL"},
-		"Redis":   {"Redis"},
-		"MongoDB": {"MongoDB"},
+// merge 合并加载的签名和内置签名
+func (db *Database) merge() {
+	db.signatures = append(append([]*Signature{}, db.custom...), db.builtin...)
+}
+
+// LoadSignatures 加载目录(或单个文件)中的YAML/JSON签名文件，
+// 任一签名无效时返回错误且不加载任何签名
+func (db *Database) LoadSignatures(path string) error {
+	var loaded []*Signature
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		ext := strings.ToLower(filepath.Ext(file))
+		if info.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			return nil
+		}
+
+		sigs, err := readSignatureFile(file)
+		if err != nil {
+			return err
+		}
+		loaded = append(loaded, sigs...)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("加载签名失败: %v", err)
 	}
+
+	db.custom = append(db.custom, loaded...)
+	db.merge()
+	return nil
+}
+
+// Len 返回签名总数
+func (db *Database) Len() int {
+	return len(db.signatures)
 }
 
-// initVersionPatterns 初始化版本识别模式
-func (db *Database) initVersionPatterns() {
-	patterns := map[string]string{
-		"Apache": `Apache/(\d+\.\d+\.\d+)`,
-		"nginx":  `nginx/(\d+\.\d+\.\d+)`,
-		"SSH":    `OpenSSH_(\d+\.\d+\w*)`,
+// readSignatureFile 读取并校验单个签名文件，不允许未知字段
+func readSignatureFile(path string) ([]*Signature, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取签名文件失败: %v", err)
+	}
+
+	var file signatureFile
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&file)
+	}
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s: 解析失败: %v", path, err)
 	}
 
-	for name, pattern := range patterns {
-		db.versionPatterns[name] = regexp.MustCompile(pattern)
+	for i, sig := range file.Signatures {
+		if sig == nil {
+			return nil, fmt.Errorf("%s: 第%d条签名为空", path, i+1)
+		}
+		if err := sig.compile(); err != nil {
+			return nil, fmt.Errorf("%s: 第%d条签名(%s): %v", path, i+1, sig.Service, err)
+		}
 	}
+	return file.Signatures, nil
 }
 
-// IdentifyService 识别服务类型
-func (db *Database) IdentifyService(port int, banner string) string {
-	// 根据端口判断
-	switch port {
-	case 80, 443:
-		return "HTTP"
-	case 22:
-		return "SSH"
-	case 21:
-		return "FTP"
-	case 25:
-		return "SMTP"
-	case 3306:
-		return "MySQL"
-	case 6379:
-		return "Redis"
-	case 27017:
-		return "MongoDB"
-	}
-
-	// 根据banner判断
-	if banner != "" {
-		banner = strings.ToUpper(banner)
-		for service, patterns := range db.servicePatterns {
-			for _, pattern := range patterns {
-				if strings.Contains(banner, strings.ToUpper(pattern)) {
-					return service
-				}
+// compile 校验签名并编译正则，服务名称统一转换为小写
+func (sig *Signature) compile() error {
+	if sig.Service == "" {
+		return fmt.Errorf("缺少 service")
+	}
+	sig.Service = strings.ToLower(sig.Service)
+	if sig.Pattern == "" {
+		return fmt.Errorf("缺少 pattern")
+	}
+	for _, port := range sig.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("无效的端口: %d", port)
+		}
+	}
+
+	re, err := regexp.Compile(sig.Pattern)
+	if err != nil {
+		return fmt.Errorf("pattern 无效: %v", err)
+	}
+
+	templates := []struct{ field, tmpl string }{
+		{"product", sig.Product},
+		{"vendor", sig.Vendor},
+		{"version", sig.Version},
+		{"os", sig.OS},
+		{"cpe", sig.CPE},
+	}
+	for _, t := range templates {
+		for _, ref := range templateGroupPattern.FindAllStringSubmatch(t.tmpl, -1) {
+			if n, _ := strconv.Atoi(ref[1]); n < 1 || n > re.NumSubexp() {
+				return fmt.Errorf("%s 引用了不存在的捕获组 $%s", t.field, ref[1])
+			}
+		}
+	}
+	if sig.CPE != "" && !strings.HasPrefix(sig.CPE, "cpe:/") && !strings.HasPrefix(sig.CPE, "cpe:2.3:") {
+		return fmt.Errorf("cpe 必须以 cpe:/ 或 cpe:2.3: 开头: %s", sig.CPE)
+	}
+
+	sig.re = re
+	return nil
+}
+
+// hasPort 判断签名是否提示了该端口
+func (sig *Signature) hasPort(port int) bool {
+	for _, p := range sig.Ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// match 匹配banner并填充模板，不匹配时返回nil
+func (sig *Signature) match(banner string) *ServiceMatch {
+	groups := sig.re.FindStringSubmatch(latin1([]byte(banner)))
+	if groups == nil {
+		return nil
+	}
+
+	result := &ServiceMatch{
+		Service: sig.Service,
+		Product: expandTemplate(sig.Product, groups),
+		Vendor:  expandTemplate(sig.Vendor, groups),
+		Version: expandTemplate(sig.Version, groups),
+		OS:      expandTemplate(sig.OS, groups),
+	}
+	if cpe := expandTemplate(sig.CPE, groups); cpe != "" {
+		result.CPE = []string{cpe}
+	}
+	return result
+}
+
+// Match 返回第一个匹配banner的签名结果，没有匹配时返回nil
+func (db *Database) Match(banner string) *ServiceMatch {
+	if banner == "" {
+		return nil
+	}
+	for _, sig := range db.signatures {
+		if result := sig.match(banner); result != nil {
+			return result
+		}
+	}
+	return nil
+}
+
+// Identify 识别服务，banner匹配优先于端口推断，都无法识别时返回nil
+func (db *Database) Identify(port int, banner string) *ServiceMatch {
+	if result := db.Match(banner); result != nil {
+		if result.Version == "" {
+			result.Version = db.IdentifyVersion(banner)
+		}
+		result.Confidence = ConfidenceBanner
+		result.Evidence = EvidenceBannerRegex
+		return result
+	}
+
+	for _, sig := range db.signatures {
+		if sig.hasPort(port) {
+			return &ServiceMatch{
+				Service:    sig.Service,
+				Confidence: ConfidencePort,
+				Evidence:   EvidencePortDefault,
 			}
 		}
 	}
+	return nil
+}
 
+// IdentifyService 识别服务类型
+func (db *Database) IdentifyService(port int, banner string) string {
+	if result := db.Identify(port, banner); result != nil {
+		return result.Service
+	}
 	return "Unknown"
 }
 
@@ -92,9 +286,12 @@ func (db *Database) IdentifyVersion(banner string) string {
 		return ""
 	}
 
-	for _, pattern := range db.versionPatterns {
-		if matches := pattern.FindStringSubmatch(banner); len(matches) > 1 {
-			return matches[1]
+	for _, sig := range db.signatures {
+		if sig.Version == "" {
+			continue
+		}
+		if result := sig.match(banner); result != nil && result.Version != "" {
+			return result.Version
 		}
 	}
 
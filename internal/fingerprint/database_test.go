@@ -0,0 +1,174 @@
+package fingerprint
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeSignatures(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadSignatures(t *testing.T) {
+	dir := writeSignatures(t, map[string]string{
+		"custom.yaml": `
+signatures:
+  - service: HTTP
+    ports: [8080]
+    pattern: 'Server: Apache-Coyote/(\d+\.\d+)'
+    product: Apache Tomcat
+    version: $1
+    cpe: cpe:/a:apache:tomcat:$1
+  - service: SSH
+    pattern: '^SSH-[\d.]+-OpenSSH_([\w.]+) (Ubuntu)'
+    product: OpenSSH
+    version: $1
+    os: Linux ($2)
+`,
+		"more.json":  `{"signatures": [{"service": "Memcached", "ports": [11211], "pattern": "^STAT pid \\d+\\r\\nSTAT uptime"}]}`,
+		"readme.txt": "忽略非签名文件",
+	})
+
+	db := NewDatabase()
+	builtin := db.Len()
+	if err := db.LoadSignatures(dir); err != nil {
+		t.Fatalf("LoadSignatures() error = %v", err)
+	}
+	if db.Len() != builtin+3 {
+		t.Errorf("Len() = %d, want %d", db.Len(), builtin+3)
+	}
+
+	got := db.Match("HTTP/1.1 200 OK\r\nServer: Apache-Coyote/1.1\r\n")
+	want := &ServiceMatch{Service: "http", Product: "Apache Tomcat", Version: "1.1", CPE: []string{"cpe:/a:apache:tomcat:1.1"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Match() = %+v, want %+v", got, want)
+	}
+
+	// 加载的签名优先于内置的OpenSSH签名
+	got = db.Match("SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.1")
+	if got == nil || got.OS != "Linux (Ubuntu)" || got.Version != "8.9p1" {
+		t.Errorf("Match() = %+v, want OpenSSH 8.9p1 on Linux (Ubuntu)", got)
+	}
+
+	if got := db.IdentifyService(11211, ""); got != "memcached" {
+		t.Errorf("IdentifyService() = %s, want memcached", got)
+	}
+	if got := db.IdentifyService(22, ""); got != "ssh" {
+		t.Errorf("IdentifyService() = %s, want ssh", got)
+	}
+}
+
+func TestLoadSignaturesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "缺少service",
+			file:    "a.yaml",
+			content: "signatures:\n  - pattern: 'x'\n",
+			wantErr: "缺少 service",
+		},
+		{
+			name:    "无效正则",
+			file:    "a.yaml",
+			content: "signatures:\n  - service: X\n    pattern: '(x'\n",
+			wantErr: "pattern 无效",
+		},
+		{
+			name:    "引用不存在的捕获组",
+			file:    "a.yaml",
+			content: "signatures:\n  - service: X\n    pattern: 'x(\\d)'\n    version: $2\n",
+			wantErr: "version 引用了不存在的捕获组 $2",
+		},
+		{
+			name:    "无效CPE",
+			file:    "a.json",
+			content: `{"signatures": [{"service": "X", "pattern": "x", "cpe": "a:b:c"}]}`,
+			wantErr: "cpe 必须以",
+		},
+		{
+			name:    "未知字段",
+			file:    "a.yaml",
+			content: "signatures:\n  - service: X\n    pattern: x\n    banner: y\n",
+			wantErr: "field banner not found",
+		},
+		{
+			name:    "无效端口",
+			file:    "a.json",
+			content: `{"signatures": [{"service": "X", "pattern": "x", "ports": [70000]}]}`,
+			wantErr: "无效的端口",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeSignatures(t, map[string]string{tt.file: tt.content})
+
+			db := NewDatabase()
+			builtin := db.Len()
+			err := db.LoadSignatures(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("LoadSignatures() error = %v, want %q", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.file) {
+				t.Errorf("错误信息应包含文件名: %v", err)
+			}
+			if db.Len() != builtin {
+				t.Errorf("加载失败后不应保留任何签名")
+			}
+		})
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	db := NewDatabase()
+
+	tests := []struct {
+		name           string
+		port           int
+		banner         string
+		wantService    string
+		wantEvidence   string
+		wantConfidence float64
+	}{
+		{"banner优先于端口", 80, "SSH-2.0-OpenSSH_9.0", "ssh", EvidenceBannerRegex, ConfidenceBanner},
+		{"端口推断", 6379, "", "redis", EvidencePortDefault, ConfidencePort},
+		{"banner不匹配时按端口推断", 21, "\x00\x00", "ftp", EvidencePortDefault, ConfidencePort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := db.Identify(tt.port, tt.banner)
+			if got == nil {
+				t.Fatal("Identify() = nil")
+			}
+			if got.Service != tt.wantService || got.Evidence != tt.wantEvidence || got.Confidence != tt.wantConfidence {
+				t.Errorf("Identify() = %s %s %.2f, want %s %s %.2f", got.Service, got.Evidence, got.Confidence,
+					tt.wantService, tt.wantEvidence, tt.wantConfidence)
+			}
+		})
+	}
+
+	if got := db.Identify(12345, "unknown"); got != nil {
+		t.Errorf("Identify() = %+v, want nil", got)
+	}
+}
+
+func TestExampleSignatures(t *testing.T) {
+	db := NewDatabase()
+	if err := db.LoadSignatures("../../configs/signatures"); err != nil {
+		t.Fatalf("示例签名无效: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package fingerprint
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strconv"
+	"time"
+)
+
+// 单个探针最多读取的响应长度
+const maxResponseSize = 16 * 1024
+
+// 不发送数据的探针(NULL探针)等待banner的上限。nmap 的NULL探针等待6秒，
+// 但主动发送banner的服务通常在连接后立即发送，等待过久会耽误后续探针
+const maxBannerWait = 2 * time.Second
+
+// identify 依次发送探针识别端口上的服务，返回匹配结果和对应的响应；
+// 识别出TLS后会在TLS连接内重新探测，服务名称以 ssl/ 为前缀，依据为 probe:TLS/<探针>。
+// 端口无法连接或上下文被取消时返回错误
+func (s *Scanner) identify(ctx context.Context, ip string, port int) (*ServiceMatch, []byte, error) {
+	addr := net.JoinHostPort(ip, strconv.Itoa(port))
+
+	probes := s.probes.ForPort(port, s.intensity, false)
+	if s.probes.Excluded(port) && len(probes) > 0 {
+		// 被排除的端口只读取banner，不发送任何数据
+		probes = probes[:1]
+	}
+
+	match, response, err := s.runProbes(ctx, ip, addr, probes, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if match != nil && !match.Soft && match.Service == "ssl" {
+		match.Evidence = EvidenceTLS
+		inner, innerResponse, err := s.runProbes(ctx, ip, addr, s.probes.ForPort(port, s.intensity, true), true)
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		if err == nil && inner != nil {
+			inner.Service = "ssl/" + inner.Service
+			inner.Evidence = EvidenceTLS + "/" + inner.Probe
+			return inner, innerResponse, nil
+		}
+	}
+	return match, response, nil
+}
+
+// runProbes 按顺序发送探针直到得到确定匹配，只有软匹配时返回第一个软匹配，
+// 软匹配后只继续发送能识别该服务的探针。没有任何匹配时返回第一个非空响应。
+// 上下文被取消时返回其错误，未发送完的探针得到的结果不完整
+func (s *Scanner) runProbes(ctx context.Context, ip, addr string, probes []*Probe, overTLS bool) (*ServiceMatch, []byte, error) {
+	var (
+		soft         *ServiceMatch
+		softResponse []byte
+		banner       []byte
+	)
+
+	for i, probe := range probes {
+		if soft != nil && !probe.identifies(soft.Service) {
+			continue
+		}
+
+		response, match, err := s.sendProbe(ctx, ip, addr, probe, overTLS)
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		if err != nil {
+			if i == 0 {
+				return nil, nil, err
+			}
+			break
+		}
+
+		if banner == nil && len(response) > 0 {
+			banner = response
+		}
+		if match == nil {
+			continue
+		}
+		match.Evidence = EvidenceProbePrefix + match.Probe
+		if !match.Soft {
+			return match, response, nil
+		}
+		if soft == nil {
+			soft, softResponse = match, response
+		}
+	}
+
+	if soft != nil {
+		return soft, softResponse, nil
+	}
+	return nil, banner, nil
+}
+
+// sendProbe 建立新连接发送探针载荷并读取响应，
+// 得到确定匹配、连接关闭或等待超时后返回
+func (s *Scanner) sendProbe(ctx context.Context, ip, addr string, probe *Probe, overTLS bool) ([]byte, *ServiceMatch, error) {
+	if err := s.limiter.Wait(ctx, ip); err != nil {
+		return nil, nil, err
+	}
+
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	if overTLS {
+		tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+		tlsConn.SetDeadline(time.Now().Add(s.timeout))
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, nil, err
+		}
+		conn = tlsConn
+	}
+
+	wait := s.timeout
+	if probe.TotalWait > 0 && probe.TotalWait < wait {
+		wait = probe.TotalWait
+	}
+	if len(probe.Payload) == 0 && wait > maxBannerWait {
+		wait = maxBannerWait
+	}
+	conn.SetDeadline(time.Now().Add(wait))
+
+	// 上下文被取消时立即结束读写
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	if len(probe.Payload) > 0 {
+		if _, err := conn.Write(probe.Payload); err != nil {
+			return nil, nil, nil
+		}
+	}
+
+	var response []byte
+	buf := make([]byte, 4096)
+	for len(response) < maxResponseSize {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			response = append(response, buf[:n]...)
+			if match := probe.Match(response); match != nil && !match.Soft {
+				return response, match, nil
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return response, probe.Match(response), nil
+}
@@ -0,0 +1,649 @@
+package fingerprint
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Marryname/WebScanner/pkg/common"
+)
+
+// 内置探针，格式与 nmap-service-probes 相同
+//
+//go:embed nmap-service-probes
+var builtinProbes []byte
+
+// DefaultIntensity 默认探测强度，稀有度不超过该值的探针才会被发送
+const DefaultIntensity = 7
+
+// Probe 服务探针，对应 nmap-service-probes 中的一个 Probe 段
+type Probe struct {
+	Name      string
+	Protocol  string
+	Payload   []byte
+	Rarity    int
+	Ports     map[int]bool
+	SSLPorts  map[int]bool
+	TotalWait time.Duration
+	Fallback  []string
+	Matches   []*Match
+
+	// 本探针没有匹配时依次尝试的探针，由Fallback和NULL探针组成
+	fallbacks []*Probe
+}
+
+// Match 响应匹配规则，对应 match 和 softmatch 指令
+type Match struct {
+	Service string
+	Soft    bool
+	Pattern *regexp.Regexp
+
+	// 版本信息模板，可引用正则的捕获组
+	Product  string
+	Version  string
+	Info     string
+	Hostname string
+	OS       string
+	Device   string
+	CPE      []string
+}
+
+// ServiceMatch 探针响应或签名匹配得到的服务信息
+type ServiceMatch struct {
+	Service    string
+	Soft       bool
+	Probe      string
+	Confidence float64
+	Evidence   string
+	Product    string
+	Vendor     string
+	Version    string
+	Info       string
+	Hostname   string
+	OS         string
+	Device     string
+	CPE        []string
+}
+
+// ProbeSet 探针集合
+type ProbeSet struct {
+	Probes []*Probe
+
+	// Unsupported Go正则不支持而被忽略的匹配规则数量，如反向引用和环视
+	Unsupported int
+
+	exclude map[int]bool
+}
+
+var (
+	defaultProbesOnce sync.Once
+	defaultProbes     *ProbeSet
+)
+
+// DefaultProbes 返回内置探针，探针集合只读，可被多个扫描器共享
+func DefaultProbes() *ProbeSet {
+	defaultProbesOnce.Do(func() {
+		set, err := ParseProbes(bytes.NewReader(builtinProbes))
+		if err != nil {
+			panic(fmt.Sprintf("内置探针解析失败: %v", err))
+		}
+		defaultProbes = set
+	})
+	return defaultProbes
+}
+
+// LoadProbes 从 nmap-service-probes 格式的文件加载探针
+func LoadProbes(path string) (*ProbeSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开探针文件失败: %v", err)
+	}
+	defer f.Close()
+
+	set, err := ParseProbes(f)
+	if err != nil {
+		return nil, fmt.Errorf("解析探针文件 %s 失败: %v", path, err)
+	}
+	return set, nil
+}
+
+// ParseProbes 解析 nmap-service-probes 格式的探针定义，
+// 文件按字节处理，正则和响应均以latin-1解码后匹配
+func ParseProbes(r io.Reader) (*ProbeSet, error) {
+	set := &ProbeSet{
+		exclude: make(map[int]bool),
+	}
+
+	var probe *Probe
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(latin1(scanner.Bytes()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		directive, args, _ := strings.Cut(line, " ")
+		args = strings.TrimSpace(args)
+
+		var err error
+		switch directive {
+		case "Exclude":
+			err = set.parseExclude(args)
+		case "Probe":
+			probe, err = parseProbe(args)
+			if err == nil {
+				set.Probes = append(set.Probes, probe)
+			}
+		default:
+			if probe == nil {
+				err = fmt.Errorf("%s 指令必须位于 Probe 之后", directive)
+				break
+			}
+			err = set.parseDirective(probe, directive, args)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("第%d行: %v", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := set.linkFallbacks(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// parseExclude 解析 Exclude 指令，只记录TCP端口
+func (set *ProbeSet) parseExclude(args string) error {
+	for _, part := range strings.Split(args, ",") {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "U:") {
+			continue
+		}
+		ports, err := common.ParsePortRange(strings.TrimPrefix(part, "T:"))
+		if err != nil {
+			return fmt.Errorf("无效的排除端口 %q: %v", part, err)
+		}
+		for _, port := range ports {
+			set.exclude[port] = true
+		}
+	}
+	return nil
+}
+
+// parseProbe 解析 Probe 指令，格式为 <协议> <名称> q|<载荷>|
+func parseProbe(args string) (*Probe, error) {
+	fields := strings.SplitN(args, " ", 3)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("无效的 Probe 指令: %s", args)
+	}
+	if fields[0] != "TCP" && fields[0] != "UDP" {
+		return nil, fmt.Errorf("不支持的探针协议: %s", fields[0])
+	}
+
+	spec := strings.TrimSpace(fields[2])
+	if len(spec) < 3 || spec[0] != 'q' {
+		return nil, fmt.Errorf("无效的探针载荷: %s", spec)
+	}
+	payload, _, err := cutDelimited(spec[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Probe{
+		Name:     fields[1],
+		Protocol: fields[0],
+		Payload:  unescape(payload),
+		Rarity:   1,
+		Ports:    make(map[int]bool),
+		SSLPorts: make(map[int]bool),
+	}, nil
+}
+
+// parseDirective 解析 Probe 段内的指令
+func (set *ProbeSet) parseDirective(probe *Probe, directive, args string) error {
+	switch directive {
+	case "match", "softmatch":
+		m, err := parseMatch(args, directive == "softmatch")
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			set.Unsupported++
+			return nil
+		}
+		probe.Matches = append(probe.Matches, m)
+	case "ports", "sslports":
+		ports, err := common.ParsePortRange(args)
+		if err != nil {
+			return fmt.Errorf("无效的端口列表 %q: %v", args, err)
+		}
+		target := probe.Ports
+		if directive == "sslports" {
+			target = probe.SSLPorts
+		}
+		for _, port := range ports {
+			target[port] = true
+		}
+	case "rarity":
+		rarity, err := strconv.Atoi(args)
+		if err != nil {
+			return fmt.Errorf("无效的稀有度: %s", args)
+		}
+		probe.Rarity = rarity
+	case "totalwaitms":
+		ms, err := strconv.Atoi(args)
+		if err != nil {
+			return fmt.Errorf("无效的等待时间: %s", args)
+		}
+		probe.TotalWait = time.Duration(ms) * time.Millisecond
+	case "fallback":
+		probe.Fallback = append(probe.Fallback, strings.Split(args, ",")...)
+	case "tcpwrappedms":
+		// 不区分tcpwrapped，忽略
+	default:
+		return fmt.Errorf("未知指令: %s", directive)
+	}
+	return nil
+}
+
+// parseMatch 解析 match/softmatch 指令，格式为 <服务> m|<正则>|[选项] [版本信息]，
+// Go不支持的正则返回nil
+func parseMatch(args string, soft bool) (*Match, error) {
+	service, rest, ok := strings.Cut(args, " ")
+	rest = strings.TrimSpace(rest)
+	if !ok || len(rest) < 3 || rest[0] != 'm' {
+		return nil, fmt.Errorf("无效的匹配规则: %s", args)
+	}
+
+	pattern, rest, err := cutDelimited(rest[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	flags := ""
+	for len(rest) > 0 && (rest[0] == 'i' || rest[0] == 's') {
+		flags += rest[:1]
+		rest = rest[1:]
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+
+	m := &Match{
+		Service: service,
+		Soft:    soft,
+	}
+	if err := m.parseVersionInfo(rest); err != nil {
+		return nil, err
+	}
+
+	if m.Pattern, err = regexp.Compile(pattern); err != nil {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// parseVersionInfo 解析版本信息字段，如 p/OpenSSH/ v/$1/ cpe:/a:openbsd:openssh:$1/
+func (m *Match) parseVersionInfo(s string) error {
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return nil
+		}
+
+		key, spec := s[:1], s[1:]
+		if strings.HasPrefix(s, "cpe:") {
+			key, spec = "cpe", s[4:]
+		}
+		value, rest, err := cutDelimited(spec)
+		if err != nil {
+			return fmt.Errorf("无效的版本信息 %q: %v", s, err)
+		}
+		s = rest
+
+		switch key {
+		case "p":
+			m.Product = value
+		case "v":
+			m.Version = value
+		case "i":
+			m.Info = value
+		case "h":
+			m.Hostname = value
+		case "o":
+			m.OS = value
+		case "d":
+			m.Device = value
+		case "cpe":
+			m.CPE = append(m.CPE, "cpe:/"+value)
+			// 去掉表示近似匹配的a选项
+			s = strings.TrimPrefix(s, "a")
+		default:
+			return fmt.Errorf("未知的版本信息字段: %s", key)
+		}
+	}
+}
+
+// linkFallbacks 根据fallback指令关联探针，TCP探针最后总会尝试NULL探针的规则
+func (set *ProbeSet) linkFallbacks() error {
+	byName := make(map[string]*Probe)
+	for _, probe := range set.Probes {
+		byName[probe.Protocol+"/"+probe.Name] = probe
+	}
+	null := byName["TCP/NULL"]
+
+	for _, probe := range set.Probes {
+		for _, name := range probe.Fallback {
+			fallback, ok := byName[probe.Protocol+"/"+strings.TrimSpace(name)]
+			if !ok {
+				return fmt.Errorf("探针 %s 的fallback %s 不存在", probe.Name, name)
+			}
+			probe.fallbacks = append(probe.fallbacks, fallback)
+		}
+		if probe.Protocol == "TCP" && null != nil && probe != null {
+			probe.fallbacks = append(probe.fallbacks, null)
+		}
+	}
+	return nil
+}
+
+// Excluded 判断TCP端口是否被 Exclude 指令排除
+func (set *ProbeSet) Excluded(port int) bool {
+	return set.exclude[port]
+}
+
+// ForPort 返回对端口依次发送的TCP探针，overTLS表示在TLS连接内发送。
+// 明文探测时NULL探针最先发送，其次是ports包含该端口的探针；
+// TLS内探测时sslports包含该端口的探针最先发送，且跳过用于识别TLS本身的探针。
+// 其余探针只有稀有度不超过intensity时才会发送，同组内按稀有度排序
+func (set *ProbeSet) ForPort(port, intensity int, overTLS bool) []*Probe {
+	type ranked struct {
+		probe *Probe
+		rank  int
+	}
+
+	var list []ranked
+	for _, probe := range set.Probes {
+		if probe.Protocol != "TCP" {
+			continue
+		}
+
+		isNull := len(probe.Payload) == 0
+		listed := probe.Ports[port]
+		if overTLS {
+			if probe.identifies("ssl") {
+				continue
+			}
+			listed = probe.SSLPorts[port]
+		}
+
+		rank := 2
+		switch {
+		case isNull && !overTLS, listed && overTLS:
+			rank = 0
+		case listed, isNull:
+			rank = 1
+		case probe.Rarity > intensity:
+			continue
+		}
+		list = append(list, ranked{probe, rank})
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].rank != list[j].rank {
+			return list[i].rank < list[j].rank
+		}
+		return list[i].probe.Rarity < list[j].probe.Rarity
+	})
+
+	probes := make([]*Probe, len(list))
+	for i, r := range list {
+		probes[i] = r.probe
+	}
+	return probes
+}
+
+// identifies 判断探针是否包含识别指定服务的规则
+func (p *Probe) identifies(service string) bool {
+	for _, m := range p.Matches {
+		if m.Service == service {
+			return true
+		}
+	}
+	return false
+}
+
+// Match 用探针及其fallback的规则匹配响应，优先返回确定匹配，
+// 没有确定匹配时返回第一个软匹配
+func (p *Probe) Match(response []byte) *ServiceMatch {
+	if len(response) == 0 {
+		return nil
+	}
+	text := latin1(response)
+
+	var soft *ServiceMatch
+	for _, probe := range append([]*Probe{p}, p.fallbacks...) {
+		for _, m := range probe.Matches {
+			groups := m.Pattern.FindStringSubmatch(text)
+			if groups == nil {
+				continue
+			}
+			result := m.expand(groups)
+			result.Probe = p.Name
+			if !m.Soft {
+				return result
+			}
+			if soft == nil {
+				soft = result
+			}
+		}
+	}
+	return soft
+}
+
+// expand 用捕获组填充版本信息模板
+func (m *Match) expand(groups []string) *ServiceMatch {
+	result := &ServiceMatch{
+		Service:  m.Service,
+		Soft:     m.Soft,
+		Product:  expandTemplate(m.Product, groups),
+		Version:  expandTemplate(m.Version, groups),
+		Info:     expandTemplate(m.Info, groups),
+		Hostname: expandTemplate(m.Hostname, groups),
+		OS:       expandTemplate(m.OS, groups),
+		Device:   expandTemplate(m.Device, groups),
+	}
+	for _, cpe := range m.CPE {
+		result.CPE = append(result.CPE, expandTemplate(cpe, groups))
+	}
+	return result
+}
+
+// expandTemplate 替换模板中的 $1、$P(1)、$SUBST(1,"a","b") 和 $I(1,">")，
+// 结果从latin-1还原为原始字节
+func expandTemplate(tmpl string, groups []string) string {
+	if tmpl == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] != '$' {
+			b.WriteByte(tmpl[i])
+			continue
+		}
+
+		rest := tmpl[i+1:]
+		if len(rest) > 0 && rest[0] >= '1' && rest[0] <= '9' {
+			b.WriteString(group(groups, rest[:1]))
+			i++
+			continue
+		}
+
+		name, call, ok := strings.Cut(rest, "(")
+		end := strings.IndexByte(call, ')')
+		if !ok || end < 0 || (name != "P" && name != "SUBST" && name != "I") {
+			b.WriteByte('$')
+			continue
+		}
+		args := splitArgs(call[:end])
+		i += len(name) + 1 + end + 1
+
+		value := group(groups, args[0])
+		switch {
+		case name == "P":
+			b.WriteString(printable(value))
+		case name == "SUBST" && len(args) == 3:
+			b.WriteString(strings.ReplaceAll(value, args[1], args[2]))
+		case name == "I" && len(args) == 2:
+			b.WriteString(unpackInt(value, args[1] == "<"))
+		}
+	}
+	return fromLatin1(b.String())
+}
+
+// group 返回编号对应的捕获组，不存在时返回空字符串
+func group(groups []string, n string) string {
+	i, err := strconv.Atoi(n)
+	if err != nil || i <= 0 || i >= len(groups) {
+		return ""
+	}
+	return groups[i]
+}
+
+// splitArgs 拆分模板函数的参数并去掉引号
+func splitArgs(s string) []string {
+	var (
+		args   []string
+		cur    strings.Builder
+		quoted bool
+	)
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			args = append(args, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	return append(args, cur.String())
+}
+
+// printable 只保留可打印的ASCII字符
+func printable(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= 0x20 && r < 0x7f {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// unpackInt 把字节串按无符号整数解析，little为true时按小端序
+func unpackInt(s string, little bool) string {
+	raw := []byte(fromLatin1(s))
+	if len(raw) > 8 {
+		return ""
+	}
+	var n uint64
+	for i := range raw {
+		c := raw[i]
+		if little {
+			c = raw[len(raw)-1-i]
+		}
+		n = n<<8 | uint64(c)
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+// cutDelimited 读取以首字符为分隔符的字段，返回字段内容和剩余部分
+func cutDelimited(s string) (string, string, error) {
+	if s == "" {
+		return "", "", fmt.Errorf("缺少分隔符")
+	}
+	delim := s[0]
+	end := strings.IndexByte(s[1:], delim)
+	if end < 0 {
+		return "", "", fmt.Errorf("缺少结束分隔符 %c", delim)
+	}
+	return s[1 : 1+end], s[2+end:], nil
+}
+
+// unescape 解析探针载荷中的转义字符
+func unescape(s string) []byte {
+	var out []byte
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '\\' || i+1 == len(runes) {
+			out = append(out, byte(runes[i]))
+			continue
+		}
+
+		i++
+		switch runes[i] {
+		case '0':
+			out = append(out, 0)
+		case 'a':
+			out = append(out, '\a')
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'v':
+			out = append(out, '\v')
+		case 'x':
+			if i+2 < len(runes) {
+				if v, err := strconv.ParseUint(string(runes[i+1:i+3]), 16, 8); err == nil {
+					out = append(out, byte(v))
+					i += 2
+					continue
+				}
+			}
+			out = append(out, 'x')
+		default:
+			out = append(out, byte(runes[i]))
+		}
+	}
+	return out
+}
+
+// latin1 把字节按latin-1解码，使每个字节对应一个字符，便于正则按字节匹配
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// fromLatin1 把latin-1解码的字符串还原为原始字节
+func fromLatin1(s string) string {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		out = append(out, byte(r))
+	}
+	return string(out)
+}
@@ -0,0 +1,197 @@
+package fingerprint
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleProbes = `# 测试探针
+Exclude T:9100-9102,U:53
+
+Probe TCP NULL q||
+totalwaitms 3000
+match ssh m|^SSH-([\d.]+)-OpenSSH_([\w.]+)| p/OpenSSH/ v/$2/ i/protocol $1/ cpe:/a:openbsd:openssh:$2/
+softmatch ftp m|^220 |
+match backref m|^(a)\1|
+
+Probe TCP GetRequest q|GET / HTTP/1.0\r\n\r\n|
+rarity 1
+ports 80,8000-8002
+sslports 443
+match http m=^HTTP/1\.[01] \d+ .*\r\nServer: ([^\r\n|]+)=s p/$P(1)/ cpe:/a:x:$SUBST(1," ","_")/a
+
+Probe TCP Binary q|\x00\x01\0ab|
+rarity 8
+ports 7000
+match bin m|^\x00\x01(..)|s i/len $I(1,">")/ d/$I(1,"<")/
+
+Probe TCP Options q|OPTIONS / HTTP/1.0\r\n\r\n|
+rarity 3
+fallback GetRequest
+`
+
+func TestParseProbes(t *testing.T) {
+	set, err := ParseProbes(strings.NewReader(sampleProbes))
+	if err != nil {
+		t.Fatalf("ParseProbes() error = %v", err)
+	}
+
+	if len(set.Probes) != 4 {
+		t.Fatalf("ParseProbes() got %d probes, want 4", len(set.Probes))
+	}
+	if set.Unsupported != 1 {
+		t.Errorf("Unsupported = %d, want 1", set.Unsupported)
+	}
+	if !set.Excluded(9101) || set.Excluded(53) {
+		t.Error("Exclude 指令解析错误")
+	}
+
+	null := set.Probes[0]
+	if null.TotalWait != 3*time.Second || len(null.Matches) != 2 {
+		t.Errorf("NULL probe = %+v", null)
+	}
+
+	get := set.Probes[1]
+	if string(get.Payload) != "GET / HTTP/1.0\r\n\r\n" {
+		t.Errorf("Payload = %q", get.Payload)
+	}
+	if !get.Ports[8001] || !get.SSLPorts[443] {
+		t.Errorf("ports = %v, sslports = %v", get.Ports, get.SSLPorts)
+	}
+
+	if got := set.Probes[2].Payload; !reflect.DeepEqual(got, []byte{0, 1, 0, 'a', 'b'}) {
+		t.Errorf("Payload = %v", got)
+	}
+}
+
+func TestParseProbesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"指令在Probe之前", "match ssh m|^SSH|\n"},
+		{"缺少结束分隔符", "Probe TCP NULL q||\nmatch ssh m|^SSH\n"},
+		{"未知版本字段", "Probe TCP NULL q||\nmatch ssh m|^SSH| x/y/\n"},
+		{"未知fallback", "Probe TCP A q|a|\nfallback B\n"},
+		{"未知协议", "Probe SCTP A q|a|\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseProbes(strings.NewReader(tt.input)); err == nil {
+				t.Error("ParseProbes() expected error")
+			}
+		})
+	}
+}
+
+func TestProbeMatch(t *testing.T) {
+	set, err := ParseProbes(strings.NewReader(sampleProbes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	null, get, binary, options := set.Probes[0], set.Probes[1], set.Probes[2], set.Probes[3]
+
+	tests := []struct {
+		name     string
+		probe    *Probe
+		response string
+		want     *ServiceMatch
+	}{
+		{
+			name:     "版本模板",
+			probe:    null,
+			response: "SSH-2.0-OpenSSH_8.9\r\n",
+			want: &ServiceMatch{Service: "ssh", Probe: "NULL", Product: "OpenSSH", Version: "8.9",
+				Info: "protocol 2.0", CPE: []string{"cpe:/a:openbsd:openssh:8.9"}},
+		},
+		{
+			name:     "软匹配",
+			probe:    null,
+			response: "220 ready\r\n",
+			want:     &ServiceMatch{Service: "ftp", Soft: true, Probe: "NULL"},
+		},
+		{
+			name:     "P和SUBST",
+			probe:    get,
+			response: "HTTP/1.1 200 OK\r\nServer: My Server\x01\r\n\r\n",
+			want: &ServiceMatch{Service: "http", Probe: "GetRequest", Product: "My Server",
+				CPE: []string{"cpe:/a:x:My_Server\x01"}},
+		},
+		{
+			name:     "二进制响应",
+			probe:    binary,
+			response: "\x00\x01\x01\x02",
+			want:     &ServiceMatch{Service: "bin", Probe: "Binary", Info: "len 258", Device: "513"},
+		},
+		{
+			name:     "fallback",
+			probe:    options,
+			response: "HTTP/1.0 200 OK\r\nServer: x\r\n\r\n",
+			want:     &ServiceMatch{Service: "http", Probe: "Options", Product: "x", CPE: []string{"cpe:/a:x:x"}},
+		},
+		{
+			name:     "fallback到NULL",
+			probe:    get,
+			response: "SSH-2.0-OpenSSH_7.4\r\n",
+			want: &ServiceMatch{Service: "ssh", Probe: "GetRequest", Product: "OpenSSH", Version: "7.4",
+				Info: "protocol 2.0", CPE: []string{"cpe:/a:openbsd:openssh:7.4"}},
+		},
+		{
+			name:     "无匹配",
+			probe:    binary,
+			response: "nothing",
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.probe.Match([]byte(tt.response)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Match() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForPort(t *testing.T) {
+	set, err := ParseProbes(strings.NewReader(sampleProbes))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		port    int
+		overTLS bool
+		want    []string
+	}{
+		{"普通端口", 22, false, []string{"NULL", "GetRequest", "Options"}},
+		{"ports优先", 7000, false, []string{"NULL", "Binary", "GetRequest", "Options"}},
+		{"TLS内sslports优先", 443, true, []string{"GetRequest", "NULL", "Options"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, probe := range set.ForPort(tt.port, DefaultIntensity, tt.overTLS) {
+				got = append(got, probe.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ForPort() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultProbes(t *testing.T) {
+	set := DefaultProbes()
+	if set.Unsupported != 0 {
+		t.Errorf("内置探针有 %d 条规则无法编译", set.Unsupported)
+	}
+	if len(set.Probes) == 0 || set.Probes[0].Name != "NULL" {
+		t.Error("内置探针应以NULL探针开头")
+	}
+}
@@ -4,86 +4,252 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sort"
+	"sync"
 	"time"
+
+	"github.com/Marryname/WebScanner/pkg/common"
+	"github.com/Marryname/WebScanner/pkg/utils"
 )
 
+// DefaultPorts 未提供端口列表时探测的常用端口
+var DefaultPorts = []int{80, 443, 22, 21, 25, 3306, 6379, 27017}
+
 // ScanResult 服务识别结果
 type ScanResult struct {
-	Port        int    `json:"port"`
-	ServiceName string `json:"service_name"`
-	Version     string `json:"version,omitempty"`
-	Banner      string `json:"banner,omitempty"`
+	IP          string  `json:"ip"`
+	Port        int     `json:"port"`
+	ServiceName string  `json:"service_name"`
+	Product     string  `json:"product,omitempty"`
+	Vendor      string  `json:"vendor,omitempty"`
+	Version     string  `json:"version,omitempty"`
+	CPE         string  `json:"cpe,omitempty"`
+	Banner      string  `json:"banner,omitempty"`
+	Confidence  float64 `json:"confidence"`
+	Evidence    string  `json:"evidence,omitempty"`
+	// Web HTTP服务的Web指纹，其他服务为nil
+	Web *WebResult `json:"web,omitempty"`
 }
 
 // Scanner 服务识别扫描器
 type Scanner struct {
-	target  string
-	timeout time.Duration
-	db      *Database
+	target     string
+	timeout    time.Duration
+	concurrent int
+	intensity  int
+	db         *Database
+	probes     *ProbeSet
+	techs      *TechnologySet
+	web        bool
+	limiter    *utils.RateLimiter
+	pool       *utils.ResolverPool
+	exclude    *common.ExcludeList
+
+	mu       sync.Mutex
+	resolved map[string][]string
 }
 
 // NewScanner 创建新的服务识别扫描器
 func NewScanner(target string, timeout time.Duration) *Scanner {
 	return &Scanner{
-		target:  target,
-		timeout: timeout,
-		db:      NewDatabase(),
+		target:     target,
+		timeout:    timeout,
+		concurrent: 10,
+		intensity:  DefaultIntensity,
+		db:         NewDatabase(),
+		probes:     DefaultProbes(),
+		techs:      DefaultTechnologies(),
+		web:        true,
+		resolved:   make(map[string][]string),
+	}
+}
+
+// SetRateLimiter 设置共享的速率限制器
+func (s *Scanner) SetRateLimiter(limiter *utils.RateLimiter) {
+	s.limiter = limiter
+}
+
+// SetResolverPool 设置解析目标使用的解析器池，为空时使用系统解析器
+func (s *Scanner) SetResolverPool(pool *utils.ResolverPool) {
+	s.pool = pool
+}
+
+// SetExcludeList 设置排除列表，解析出的地址在列表中时不连接
+func (s *Scanner) SetExcludeList(exclude *common.ExcludeList) {
+	s.exclude = exclude
+}
+
+// SetConcurrent 设置同时探测的端口数量
+func (s *Scanner) SetConcurrent(concurrent int) {
+	if concurrent > 0 {
+		s.concurrent = concurrent
 	}
 }
 
-// Scan 执行服务识别扫描
+// SetDatabase 设置探针无法识别时使用的签名数据库
+func (s *Scanner) SetDatabase(db *Database) {
+	if db != nil {
+		s.db = db
+	}
+}
+
+// SetProbes 设置使用的探针集合，替换内置探针
+func (s *Scanner) SetProbes(probes *ProbeSet) {
+	if probes != nil {
+		s.probes = probes
+	}
+}
+
+// SetTechnologies 设置Web技术识别规则，替换内置规则
+func (s *Scanner) SetTechnologies(techs *TechnologySet) {
+	if techs != nil {
+		s.techs = techs
+	}
+}
+
+// SetWeb 设置是否对HTTP服务进行Web指纹识别，默认开启
+func (s *Scanner) SetWeb(enabled bool) {
+	s.web = enabled
+}
+
+// SetIntensity 设置探测强度(1-9)，越大发送的探针越多
+func (s *Scanner) SetIntensity(intensity int) {
+	if intensity >= 1 && intensity <= 9 {
+		s.intensity = intensity
+	}
+}
+
+// Scan 对目标的常用端口执行服务识别
 func (s *Scanner) Scan(ctx context.Context) ([]ScanResult, error) {
-	var results []ScanResult
+	return s.ScanPorts(ctx, s.target, DefaultPorts)
+}
 
-	// 获取目标IP
-	ips, err := net.LookupIP(s.target)
+// ScanPorts 对主机解析出的每个IP地址上的指定端口执行服务识别，
+// 无法连接的端口不会出现在结果中，结果按IP和端口排序
+func (s *Scanner) ScanPorts(ctx context.Context, host string, ports []int) ([]ScanResult, error) {
+	ips, err := s.resolve(ctx, host)
 	if err != nil {
-		return nil, fmt.Errorf("解析目标失败: %v", err)
+		return nil, err
 	}
 
-	// 使用第一个IP地址
-	ip := ips[0].String()
+	var (
+		results []ScanResult
+		mu      sync.Mutex
+	)
 
-	// 扫描常用端口
-	commonPorts := []int{80, 443, 22, 21, 25, 3306, 6379, 27017}
-	for _, port := range commonPorts {
-		select {
-		case <-ctx.Done():
-			return results, ctx.Err()
-		default:
-			if result := s.scanPort(ip, port); result != nil {
-				results = append(results, *result)
+	group := utils.NewTaskGroup(ctx, s.concurrent, false)
+	for _, ip := range ips {
+		for _, port := range ports {
+			ip, port := ip, port
+			if err := group.Go(func(ctx context.Context) error {
+				if result := s.scanPort(ctx, host, ip, port); result != nil {
+					mu.Lock()
+					results = append(results, *result)
+					mu.Unlock()
+				}
+				return nil
+			}); err != nil {
+				break
 			}
 		}
 	}
+	group.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].IP != results[j].IP {
+			return results[i].IP < results[j].IP
+		}
+		return results[i].Port < results[j].Port
+	})
+
+	return results, ctx.Err()
+}
+
+// resolve 解析主机的全部IP地址，结果会被缓存
+func (s *Scanner) resolve(ctx context.Context, host string) ([]string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{ip.String()}, nil
+	}
+
+	s.mu.Lock()
+	ips, ok := s.resolved[host]
+	s.mu.Unlock()
+	if ok {
+		return ips, nil
+	}
+
+	addrs, err := utils.LookupIP(ctx, s.pool, host)
+	if err != nil {
+		return nil, fmt.Errorf("解析目标失败: %v", err)
+	}
+	if addrs, err = s.exclude.FilterIPs(host, addrs); err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		ips = append(ips, addr.String())
+	}
 
-	return results, nil
+	s.mu.Lock()
+	s.resolved[host] = ips
+	s.mu.Unlock()
+
+	return ips, nil
 }
 
-// scanPort 扫描单个端口
-func (s *Scanner) scanPort(ip string, port int) *ScanResult {
-	addr := fmt.Sprintf("%s:%d", ip, port)
-	conn, err := net.DialTimeout("tcp", addr, s.timeout)
+// scanPort 扫描单个端口，识别依据的优先级为：
+// 探针确定匹配、banner签名匹配、探针软匹配、端口默认服务。
+// 识别为HTTP的服务会继续访问根页面获取Web指纹
+func (s *Scanner) scanPort(ctx context.Context, host, ip string, port int) *ScanResult {
+	match, response, err := s.identify(ctx, ip, port)
 	if err != nil {
 		return nil
 	}
-	defer conn.Close()
 
 	result := &ScanResult{
-		Port: port,
+		IP:          ip,
+		Port:        port,
+		Banner:      string(response),
+		ServiceName: "Unknown",
 	}
 
-	// 尝试获取banner
-	banner := make([]byte, 1024)
-	conn.SetReadDeadline(time.Now().Add(s.timeout))
-	n, err := conn.Read(banner)
-	if err == nil && n > 0 {
-		result.Banner = string(banner[:n])
+	if match != nil {
+		match.Confidence = ConfidenceProbe
+		if match.Soft {
+			match.Confidence = ConfidenceSoftProbe
+		}
 	}
+	if match == nil || match.Soft {
+		if sig := s.db.Identify(port, result.Banner); sig != nil &&
+			(match == nil || sig.Evidence == EvidenceBannerRegex) {
+			match = sig
+		}
+	}
+
+	if match != nil {
+		result.ServiceName = match.Service
+		result.Product = match.Product
+		result.Version = match.Version
+		result.Confidence = match.Confidence
+		result.Evidence = match.Evidence
 
-	// 识别服务
-	result.ServiceName = s.db.IdentifyService(port, result.Banner)
-	result.Version = s.db.IdentifyVersion(result.Banner)
+		// CPE统一为2.3格式，厂商未在签名中给出时取CPE的vendor属性
+		result.CPE = primaryCPE(match.CPE)
+		result.Vendor = match.Vendor
+		if result.Vendor == "" {
+			result.Vendor = cpeAttribute(result.CPE, 1)
+		}
+	}
+	if result.Version == "" {
+		result.Version = s.db.IdentifyVersion(result.Banner)
+	}
+	// 版本由其他签名补全时，CPE中的版本仍是通配符
+	result.CPE = cpeWithVersion(result.CPE, result.Version)
+
+	if web, overTLS := isWebService(result.ServiceName); web && s.web {
+		if page, err := s.scanWeb(ctx, host, ip, port, overTLS); err == nil {
+			result.Web = page
+		}
+	}
 
 	return result
 }
@@ -2,6 +2,9 @@ package fingerprint
 
 import (
 	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -44,6 +47,312 @@ func TestScanner(t *testing.T) {
 	}
 }
 
+func TestScanPorts(t *testing.T) {
+	// 非标准端口上的SSH服务
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-OpenSSH_8.2p1 Ubuntu-4ubuntu0.2\r\n"))
+			conn.Close()
+		}
+	}()
+
+	// 已关闭的端口
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	scanner := NewScanner("127.0.0.1", time.Second)
+	results, err := scanner.ScanPorts(context.Background(), "localhost", []int{port, closedPort})
+	if err != nil {
+		t.Fatalf("ScanPorts() error = %v", err)
+	}
+
+	var found bool
+	for _, result := range results {
+		if result.Port == closedPort {
+			t.Errorf("ScanPorts() returned closed port %d", closedPort)
+		}
+		if result.Port == port && result.IP == "127.0.0.1" {
+			found = true
+			if result.ServiceName != "ssh" || result.Version != "8.2p1" {
+				t.Errorf("ScanPorts() = %s %s, want ssh 8.2p1", result.ServiceName, result.Version)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("ScanPorts() did not identify port %d on 127.0.0.1: %+v", port, results)
+	}
+}
+
+// serveSilentHTTP 启动连接后不发送banner的HTTP服务，收到请求后才响应，
+// 没有请求的连接一直保持到客户端关闭
+func serveSilentHTTP(t *testing.T) int {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 1024)
+				if n, err := conn.Read(buf); err != nil || n == 0 {
+					return
+				}
+				conn.Write([]byte("HTTP/1.1 200 OK\r\nServer: nginx/1.18.0\r\nContent-Length: 2\r\n\r\nok"))
+			}()
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestScanPortsBannerWait(t *testing.T) {
+	port := serveSilentHTTP(t)
+
+	// 超时较长时NULL探针也只等待 maxBannerWait，随后发送 GetRequest
+	scanner := NewScanner("127.0.0.1", 10*time.Second)
+	scanner.SetWeb(false)
+	start := time.Now()
+	results, err := scanner.ScanPorts(context.Background(), "127.0.0.1", []int{port})
+	if err != nil {
+		t.Fatalf("ScanPorts() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > maxBannerWait+time.Second {
+		t.Errorf("ScanPorts() 耗时 %v, want 不超过 %v", elapsed, maxBannerWait+time.Second)
+	}
+	if len(results) != 1 || results[0].ServiceName != "http" || results[0].Evidence != "probe:GetRequest" {
+		t.Errorf("ScanPorts() = %+v, want http via probe:GetRequest", results)
+	}
+}
+
+func TestScanPortsCancelled(t *testing.T) {
+	port := serveSilentHTTP(t)
+
+	// NULL探针等待banner时上下文被取消，不应返回不完整的识别结果
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	scanner := NewScanner("127.0.0.1", 10*time.Second)
+	start := time.Now()
+	results, err := scanner.ScanPorts(ctx, "127.0.0.1", []int{port})
+	if err != context.DeadlineExceeded {
+		t.Errorf("ScanPorts() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if len(results) != 0 {
+		t.Errorf("ScanPorts() = %+v, want 没有结果", results)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ScanPorts() 耗时 %v, 上下文取消后应立即返回", elapsed)
+	}
+}
+
+func TestScanPortsBannerSignature(t *testing.T) {
+	// 没有探针能匹配的banner，由签名识别
+	port := serve(t, func(string) string { return "" })
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("Welcome to Apache/2.4.41 admin console\r\n"))
+			conn.Close()
+		}
+	}()
+	bannerPort := ln.Addr().(*net.TCPAddr).Port
+
+	scanner := NewScanner("127.0.0.1", 200*time.Millisecond)
+	results, err := scanner.ScanPorts(context.Background(), "127.0.0.1", []int{bannerPort, port})
+	if err != nil {
+		t.Fatalf("ScanPorts() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("ScanPorts() got %d results, want 2", len(results))
+	}
+
+	for _, got := range results {
+		if got.Port == bannerPort {
+			if got.ServiceName != "http" || got.Version != "2.4.41" || got.Evidence != EvidenceBannerRegex {
+				t.Errorf("ScanPorts() = %+v, want http 2.4.41 via %s", got, EvidenceBannerRegex)
+			}
+			if got.Product != "Apache httpd" || got.Vendor != "apache" ||
+				got.CPE != "cpe:2.3:a:apache:http_server:2.4.41:*:*:*:*:*:*:*" {
+				t.Errorf("ScanPorts() product = %q, vendor = %q, cpe = %q", got.Product, got.Vendor, got.CPE)
+			}
+		} else if got.ServiceName != "Unknown" || got.Confidence != 0 || got.Evidence != "" {
+			t.Errorf("ScanPorts() = %+v, want Unknown", got)
+		}
+	}
+}
+
+func TestScanPortsCPEVersion(t *testing.T) {
+	// 匹配的签名没有版本，版本由另一条签名补全后写入CPE
+	dir := writeSignatures(t, map[string]string{
+		"custom.yaml": `
+signatures:
+  - service: http
+    pattern: '^ExampleWare console'
+    product: ExampleWare
+    cpe: cpe:/a:example:exampleware
+  - service: http
+    pattern: 'ExampleWare/(\d+\.\d+)'
+    version: $1
+`,
+	})
+	db := NewDatabase()
+	if err := db.LoadSignatures(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("ExampleWare console ready\r\nServer: ExampleWare/3.1\r\n"))
+			conn.Close()
+		}
+	}()
+
+	scanner := NewScanner("127.0.0.1", 200*time.Millisecond)
+	scanner.SetDatabase(db)
+	results, err := scanner.ScanPorts(context.Background(), "127.0.0.1", []int{ln.Addr().(*net.TCPAddr).Port})
+	if err != nil {
+		t.Fatalf("ScanPorts() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("ScanPorts() got %d results, want 1", len(results))
+	}
+	if got := results[0]; got.Version != "3.1" || got.CPE != "cpe:2.3:a:example:exampleware:3.1:*:*:*:*:*:*:*" {
+		t.Errorf("ScanPorts() version = %q, cpe = %q", got.Version, got.CPE)
+	}
+}
+
+// serve 在本地端口上运行简单的请求/响应服务
+func serve(t *testing.T, handle func(request string) string) int {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 1024)
+				conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+				n, err := conn.Read(buf)
+				if err != nil {
+					return
+				}
+				if reply := handle(string(buf[:n])); reply != "" {
+					conn.Write([]byte(reply))
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestActiveProbes(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.18.0")
+		w.Write([]byte("ok"))
+	})
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+	tlsServer := httptest.NewTLSServer(handler)
+	defer tlsServer.Close()
+
+	redisPort := serve(t, func(request string) string {
+		if request == "*1\r\n$4\r\nPING\r\n" {
+			return "+PONG\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	})
+
+	tests := []struct {
+		name         string
+		port         int
+		wantService  string
+		wantVersion  string
+		wantEvidence string
+		wantCPE      string
+		wantWeb      bool
+	}{
+		{"HTTP", httpServer.Listener.Addr().(*net.TCPAddr).Port, "http", "1.18.0", "probe:GetRequest",
+			"cpe:2.3:a:igor_sysoev:nginx:1.18.0:*:*:*:*:*:*:*", true},
+		{"HTTPS", tlsServer.Listener.Addr().(*net.TCPAddr).Port, "ssl/http", "1.18.0", "probe:TLS/GetRequest",
+			"cpe:2.3:a:igor_sysoev:nginx:1.18.0:*:*:*:*:*:*:*", true},
+		{"Redis", redisPort, "redis", "", "probe:RedisPing", "cpe:2.3:a:redislabs:redis:*:*:*:*:*:*:*:*", false},
+	}
+
+	scanner := NewScanner("127.0.0.1", 300*time.Millisecond)
+	scanner.SetIntensity(9)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := scanner.ScanPorts(context.Background(), "127.0.0.1", []int{tt.port})
+			if err != nil {
+				t.Fatalf("ScanPorts() error = %v", err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("ScanPorts() got %d results, want 1", len(results))
+			}
+			if got := results[0]; got.ServiceName != tt.wantService || got.Version != tt.wantVersion {
+				t.Errorf("ScanPorts() = %s %s, want %s %s", got.ServiceName, got.Version, tt.wantService, tt.wantVersion)
+			}
+			if got := results[0]; tt.wantCPE != got.CPE {
+				t.Errorf("ScanPorts() cpe = %q, want %q", got.CPE, tt.wantCPE)
+			}
+			if got := results[0]; got.Evidence != tt.wantEvidence || got.Confidence != ConfidenceProbe {
+				t.Errorf("ScanPorts() evidence = %s (%.2f), want %s (%.2f)",
+					got.Evidence, got.Confidence, tt.wantEvidence, ConfidenceProbe)
+			}
+			if got := results[0].Web; (got != nil) != tt.wantWeb {
+				t.Errorf("ScanPorts() web = %+v, want web fingerprint %v", got, tt.wantWeb)
+			} else if got != nil && (got.StatusCode != http.StatusOK || got.Server != "nginx/1.18.0") {
+				t.Errorf("ScanPorts() web = %d %s, want 200 nginx/1.18.0", got.StatusCode, got.Server)
+			}
+		})
+	}
+}
+
 func TestDatabase(t *testing.T) {
 	db := NewDatabase()
 
@@ -58,16 +367,35 @@ func TestDatabase(t *testing.T) {
 			name:    "HTTP服务识别",
 			port:    80,
 			banner:  "Apache/2.4.41 (Ubuntu)",
-			want:    "HTTP",
+			want:    "http",
 			wantVer: "2.4.41",
 		},
 		{
 			name:    "SSH服务识别",
 			port:    22,
 			banner:  "OpenSSH_8.2p1 Ubuntu-4ubuntu0.2",
-			want:    "SSH",
+			want:    "ssh",
 			wantVer: "8.2p1",
 		},
+		{
+			name:    "80端口上的SSH服务",
+			port:    80,
+			banner:  "SSH-2.0-OpenSSH_8.2p1",
+			want:    "ssh",
+			wantVer: "8.2p1",
+		},
+		{
+			name:   "无banner时按端口推断",
+			port:   3306,
+			banner: "",
+			want:   "mysql",
+		},
+		{
+			name:   "无法识别",
+			port:   12345,
+			banner: "\x00\x01",
+			want:   "Unknown",
+		},
 	}
 
 	for _, tt := range tests {
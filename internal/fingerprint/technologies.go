@@ -0,0 +1,400 @@
+package fingerprint
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 内置Web技术识别规则，格式与 Wappalyzer 相同
+//
+//go:embed technologies.json
+var builtinTechnologies []byte
+
+// Technology 识别出的Web技术
+type Technology struct {
+	Name       string   `json:"name"`
+	Version    string   `json:"version,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	CPE        string   `json:"cpe,omitempty"`
+	Confidence int      `json:"confidence"`
+}
+
+// TechnologySet Web技术识别规则集合
+type TechnologySet struct {
+	// Unsupported Go正则不支持而被忽略的规则数量，如环视
+	Unsupported int
+
+	techs  []*techRule
+	byName map[string]*techRule
+}
+
+// techRule 单个技术的识别规则，headers、cookies和meta的键为小写
+type techRule struct {
+	name       string
+	categories []string
+	cpe        string
+	headers    map[string][]*techPattern
+	cookies    map[string][]*techPattern
+	meta       map[string][]*techPattern
+	html       []*techPattern
+	scriptSrc  []*techPattern
+	implies    []*techPattern
+}
+
+// techPattern 带版本模板和置信度的正则，如 nginx(?:/([\d.]+))?\;version:\1
+type techPattern struct {
+	re         *regexp.Regexp
+	name       string
+	version    string
+	confidence int
+}
+
+// stringList 兼容字符串和字符串数组两种写法
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = stringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// technologyFile 规则文件的结构，未使用的字段(如js、dom、website)会被忽略
+type technologyFile struct {
+	Categories map[string]struct {
+		Name string `json:"name"`
+	} `json:"categories"`
+	Technologies map[string]struct {
+		Cats      []int                 `json:"cats"`
+		CPE       string                `json:"cpe"`
+		Headers   map[string]stringList `json:"headers"`
+		Cookies   map[string]stringList `json:"cookies"`
+		Meta      map[string]stringList `json:"meta"`
+		HTML      stringList            `json:"html"`
+		ScriptSrc stringList            `json:"scriptSrc"`
+		Implies   stringList            `json:"implies"`
+	} `json:"technologies"`
+}
+
+var (
+	defaultTechnologiesOnce sync.Once
+	defaultTechnologies     *TechnologySet
+)
+
+// DefaultTechnologies 返回内置的Web技术识别规则，规则集合只读，可被多个扫描器共享
+func DefaultTechnologies() *TechnologySet {
+	defaultTechnologiesOnce.Do(func() {
+		set, err := ParseTechnologies(builtinTechnologies)
+		if err != nil {
+			panic(fmt.Sprintf("内置Web技术规则解析失败: %v", err))
+		}
+		defaultTechnologies = set
+	})
+	return defaultTechnologies
+}
+
+// LoadTechnologies 从 Wappalyzer 格式的JSON文件加载Web技术识别规则，
+// 文件需同时包含 categories 和 technologies
+func LoadTechnologies(path string) (*TechnologySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取Web技术规则失败: %v", err)
+	}
+
+	set, err := ParseTechnologies(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析Web技术规则 %s 失败: %v", path, err)
+	}
+	return set, nil
+}
+
+// ParseTechnologies 解析 Wappalyzer 格式的规则，支持 headers、cookies、meta、
+// html、scriptSrc 和 implies，正则不区分大小写
+func ParseTechnologies(data []byte) (*TechnologySet, error) {
+	var file technologyFile
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&file); err != nil {
+		return nil, err
+	}
+
+	set := &TechnologySet{
+		byName: make(map[string]*techRule),
+	}
+	for name, entry := range file.Technologies {
+		tech := &techRule{
+			name:    name,
+			cpe:     entry.CPE,
+			headers: set.compileMap(entry.Headers),
+			cookies: set.compileMap(entry.Cookies),
+			meta:    set.compileMap(entry.Meta),
+		}
+		for _, id := range entry.Cats {
+			category, ok := file.Categories[strconv.Itoa(id)]
+			if !ok {
+				return nil, fmt.Errorf("%s: 未定义的分类 %d", name, id)
+			}
+			tech.categories = append(tech.categories, category.Name)
+		}
+		tech.html = set.compileList(entry.HTML)
+		tech.scriptSrc = set.compileList(entry.ScriptSrc)
+
+		// implies 的值是技术名称而不是正则
+		for _, implied := range entry.Implies {
+			pattern := parseTechPattern(implied)
+			pattern.name = strings.TrimSpace(strings.SplitN(implied, `\;`, 2)[0])
+			tech.implies = append(tech.implies, pattern)
+		}
+
+		set.techs = append(set.techs, tech)
+		set.byName[name] = tech
+	}
+
+	for _, tech := range set.techs {
+		for _, implied := range tech.implies {
+			if _, ok := set.byName[implied.name]; !ok {
+				return nil, fmt.Errorf("%s: implies 引用了未定义的技术 %s", tech.name, implied.name)
+			}
+		}
+	}
+
+	sort.Slice(set.techs, func(i, j int) bool {
+		return set.techs[i].name < set.techs[j].name
+	})
+	return set, nil
+}
+
+// Len 返回技术数量
+func (set *TechnologySet) Len() int {
+	return len(set.techs)
+}
+
+// compileMap 编译以名称为键的规则，名称转为小写
+func (set *TechnologySet) compileMap(m map[string]stringList) map[string][]*techPattern {
+	if len(m) == 0 {
+		return nil
+	}
+	compiled := make(map[string][]*techPattern, len(m))
+	for key, values := range m {
+		if patterns := set.compileList(values); len(patterns) > 0 {
+			compiled[strings.ToLower(key)] = append(compiled[strings.ToLower(key)], patterns...)
+		}
+	}
+	return compiled
+}
+
+// compileList 编译规则列表，Go正则不支持的规则计入Unsupported后忽略
+func (set *TechnologySet) compileList(values []string) []*techPattern {
+	var patterns []*techPattern
+	for _, value := range values {
+		pattern := parseTechPattern(value)
+		re, err := regexp.Compile("(?i)" + strings.SplitN(value, `\;`, 2)[0])
+		if err != nil {
+			set.Unsupported++
+			continue
+		}
+		pattern.re = re
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// parseTechPattern 解析 \; 之后的 version 和 confidence 附加字段
+func parseTechPattern(value string) *techPattern {
+	pattern := &techPattern{confidence: 100}
+	fields := strings.Split(value, `\;`)
+	for _, field := range fields[1:] {
+		key, val, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "version":
+			pattern.version = val
+		case "confidence":
+			if n, err := strconv.Atoi(val); err == nil && n >= 0 && n <= 100 {
+				pattern.confidence = n
+			}
+		}
+	}
+	return pattern
+}
+
+// 版本模板中的三元表达式，如 \1?next:legacy
+var versionTernaryPattern = regexp.MustCompile(`^\\(\d)\?([^:]*):(.*)$`)
+
+// 版本模板中的捕获组引用，如 \1
+var versionGroupPattern = regexp.MustCompile(`\\(\d)`)
+
+// matchVersion 匹配内容并填充版本模板，不匹配时ok为false
+func (p *techPattern) matchVersion(content string) (version string, ok bool) {
+	groups := p.re.FindStringSubmatch(content)
+	if groups == nil {
+		return "", false
+	}
+
+	group := func(ref string) string {
+		n, _ := strconv.Atoi(ref)
+		if n < len(groups) {
+			return groups[n]
+		}
+		return ""
+	}
+
+	tmpl := p.version
+	if m := versionTernaryPattern.FindStringSubmatch(tmpl); m != nil {
+		if group(m[1]) != "" {
+			tmpl = m[2]
+		} else {
+			tmpl = m[3]
+		}
+	}
+	version = versionGroupPattern.ReplaceAllStringFunc(tmpl, func(ref string) string {
+		return group(ref[1:])
+	})
+	return strings.TrimSpace(version), true
+}
+
+// 页面中需要提取的标签和属性
+var (
+	tagPattern  = regexp.MustCompile(`(?is)<(meta|script|link)\b([^>]*)>`)
+	attrPattern = regexp.MustCompile(`(?s)([\w:.-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// htmlTag 页面中的一个标签
+type htmlTag struct {
+	name  string
+	attrs map[string]string
+}
+
+// parseTags 提取页面中的 meta、script 和 link 标签，属性名转为小写
+func parseTags(body string) []htmlTag {
+	var tags []htmlTag
+	for _, m := range tagPattern.FindAllStringSubmatch(body, -1) {
+		tag := htmlTag{
+			name:  strings.ToLower(m[1]),
+			attrs: make(map[string]string),
+		}
+		for _, attr := range attrPattern.FindAllStringSubmatch(m[2], -1) {
+			tag.attrs[strings.ToLower(attr[1])] = attr[2] + attr[3] + attr[4]
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// Analyze 根据响应头和页面内容识别Web技术，结果包括被其他技术隐含的技术，按名称排序
+func (set *TechnologySet) Analyze(header http.Header, body string) []Technology {
+	cookies := make(map[string]string)
+	for _, cookie := range (&http.Response{Header: header}).Cookies() {
+		cookies[strings.ToLower(cookie.Name)] = cookie.Value
+	}
+
+	meta := make(map[string][]string)
+	var scripts []string
+	for _, tag := range parseTags(body) {
+		switch tag.name {
+		case "meta":
+			name := tag.attrs["name"]
+			if name == "" {
+				name = tag.attrs["property"]
+			}
+			if name != "" {
+				meta[strings.ToLower(name)] = append(meta[strings.ToLower(name)], tag.attrs["content"])
+			}
+		case "script":
+			if src := tag.attrs["src"]; src != "" {
+				scripts = append(scripts, src)
+			}
+		}
+	}
+
+	detected := make(map[string]*Technology)
+	for _, tech := range set.techs {
+		result := &Technology{Name: tech.name}
+		matchAll := func(patterns []*techPattern, values []string) {
+			for _, p := range patterns {
+				for _, value := range values {
+					if version, ok := p.matchVersion(value); ok {
+						result.Confidence += p.confidence
+						if len(version) > len(result.Version) {
+							result.Version = version
+						}
+						break
+					}
+				}
+			}
+		}
+
+		for name, patterns := range tech.headers {
+			if values := header.Values(name); len(values) > 0 {
+				matchAll(patterns, []string{strings.Join(values, ", ")})
+			}
+		}
+		for name, patterns := range tech.cookies {
+			if value, ok := cookies[name]; ok {
+				matchAll(patterns, []string{value})
+			}
+		}
+		for name, patterns := range tech.meta {
+			matchAll(patterns, meta[name])
+		}
+		matchAll(tech.html, []string{body})
+		matchAll(tech.scriptSrc, scripts)
+
+		if result.Confidence > 0 {
+			detected[tech.name] = result
+		}
+	}
+
+	// 隐含的技术继承隐含它的技术的置信度，重复直到没有新增
+	for changed := true; changed; {
+		changed = false
+		for _, tech := range set.techs {
+			source, ok := detected[tech.name]
+			if !ok {
+				continue
+			}
+			for _, implied := range tech.implies {
+				if _, ok := detected[implied.name]; ok {
+					continue
+				}
+				confidence := source.Confidence
+				if implied.confidence < confidence {
+					confidence = implied.confidence
+				}
+				detected[implied.name] = &Technology{Name: implied.name, Confidence: confidence}
+				changed = true
+			}
+		}
+	}
+
+	var results []Technology
+	for _, tech := range set.techs {
+		result, ok := detected[tech.name]
+		if !ok {
+			continue
+		}
+		if result.Confidence > 100 {
+			result.Confidence = 100
+		}
+		result.Categories = tech.categories
+		result.CPE = cpeWithVersion(ToCPE23(tech.cpe), result.Version)
+		results = append(results, *result)
+	}
+	return results
+}
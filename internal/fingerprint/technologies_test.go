@@ -0,0 +1,120 @@
+package fingerprint
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTechnologies(t *testing.T) {
+	set, err := ParseTechnologies([]byte(`{
+  "categories": {"1": {"name": "CMS"}, "27": {"name": "Programming languages"}},
+  "technologies": {
+    "Acme CMS": {
+      "cats": [1],
+      "cpe": "cpe:2.3:a:acme:cms:*:*:*:*:*:*:*:*",
+      "headers": {"X-Generator": "AcmeCMS(?:/([\\d.]+))?\\;version:\\1"},
+      "meta": {"generator": ["^Acme (\\d+)\\;version:\\1", "(?!lookahead)"]},
+      "html": "data-acme\\;confidence:40",
+      "implies": "PHP\\;confidence:50",
+      "website": "https://acme.example"
+    },
+    "Acme Edition": {
+      "cats": [1],
+      "cookies": {"acme_edition": "(pro)?\\;version:\\1?Pro:Community"}
+    },
+    "PHP": {"cats": [27]}
+  }
+}`))
+	if err != nil {
+		t.Fatalf("ParseTechnologies() error = %v", err)
+	}
+	if set.Len() != 3 || set.Unsupported != 1 {
+		t.Fatalf("Len() = %d, Unsupported = %d, want 3 and 1", set.Len(), set.Unsupported)
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+		want   []Technology
+	}{
+		{
+			name: "版本和隐含技术",
+			header: http.Header{
+				"X-Generator": {"AcmeCMS/2.1.0"},
+				"Set-Cookie":  {"acme_edition=pro; Path=/"},
+			},
+			body: `<meta content="Acme 2" name="Generator"><div data-acme></div>`,
+			want: []Technology{
+				{Name: "Acme CMS", Version: "2.1.0", Categories: []string{"CMS"}, CPE: "cpe:2.3:a:acme:cms:2.1.0:*:*:*:*:*:*:*", Confidence: 100},
+				{Name: "Acme Edition", Version: "Pro", Categories: []string{"CMS"}, Confidence: 100},
+				{Name: "PHP", Categories: []string{"Programming languages"}, Confidence: 50},
+			},
+		},
+		{
+			name:   "低置信度规则",
+			header: http.Header{"Set-Cookie": {"acme_edition=basic"}},
+			body:   `<div data-acme></div>`,
+			want: []Technology{
+				{Name: "Acme CMS", Categories: []string{"CMS"}, CPE: "cpe:2.3:a:acme:cms:*:*:*:*:*:*:*:*", Confidence: 40},
+				{Name: "Acme Edition", Version: "Community", Categories: []string{"CMS"}, Confidence: 100},
+				{Name: "PHP", Categories: []string{"Programming languages"}, Confidence: 40},
+			},
+		},
+		{
+			name:   "没有匹配",
+			header: http.Header{"Server": {"nginx"}},
+			body:   "<html></html>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := set.Analyze(tt.header, tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTechnologiesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"未定义的分类", `{"categories": {}, "technologies": {"X": {"cats": [1]}}}`, "未定义的分类 1"},
+		{"未定义的隐含技术", `{"technologies": {"X": {"implies": "Y"}}}`, "未定义的技术 Y"},
+		{"格式错误", `{"technologies": {"X": {"html": 1}}}`, "cannot unmarshal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTechnologies([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseTechnologies() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultTechnologies(t *testing.T) {
+	set := DefaultTechnologies()
+	if set.Unsupported != 0 {
+		t.Errorf("内置规则包含 %d 条不支持的正则", set.Unsupported)
+	}
+
+	got := set.Analyze(http.Header{
+		"Server":     {"Apache-Coyote/1.1"},
+		"Set-Cookie": {"rememberMe=deleteMe; Path=/"},
+	}, "")
+	var names []string
+	for _, tech := range got {
+		names = append(names, tech.Name)
+	}
+	if want := []string{"Apache Shiro", "Apache Tomcat", "Java"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Analyze() = %v, want %v", names, want)
+	}
+}
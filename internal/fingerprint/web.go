@@ -0,0 +1,202 @@
+package fingerprint
+
+import (
+	"context"
+	"crypto/tls"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Marryname/WebScanner/pkg/utils"
+)
+
+// 页面和favicon最多读取的长度
+const maxBodySize = 1024 * 1024
+
+// 最多跟随的重定向次数
+const maxRedirects = 10
+
+const webUserAgent = "Mozilla/5.0 (compatible; WebScanner)"
+
+// WebResult HTTP服务的Web指纹
+type WebResult struct {
+	// URL 跟随重定向后最终页面的地址
+	URL           string `json:"url"`
+	StatusCode    int    `json:"status_code"`
+	Title         string `json:"title,omitempty"`
+	Server        string `json:"server,omitempty"`
+	ContentLength int64  `json:"content_length"`
+	// RedirectChain 发生重定向时经过的全部地址，第一个为请求的地址，最后一个为最终页面
+	RedirectChain []string     `json:"redirect_chain,omitempty"`
+	FaviconURL    string       `json:"favicon_url,omitempty"`
+	FaviconHash   int32        `json:"favicon_hash,omitempty"`
+	Technologies  []Technology `json:"technologies,omitempty"`
+}
+
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// isWebService 判断服务是否为HTTP，overTLS表示是否需要通过TLS访问
+func isWebService(service string) (web, overTLS bool) {
+	name := strings.ToLower(service)
+	overTLS = strings.HasPrefix(name, "ssl/") || name == "https"
+	name = strings.TrimPrefix(name, "ssl/")
+	return strings.HasPrefix(name, "http"), overTLS
+}
+
+// scanWeb 访问HTTP服务的根页面，按识别结果选择的协议失败时改用另一协议
+func (s *Scanner) scanWeb(ctx context.Context, host, ip string, port int, overTLS bool) (*WebResult, error) {
+	result, err := s.fetchWeb(ctx, host, ip, port, overTLS)
+	if err != nil && ctx.Err() == nil {
+		result, err = s.fetchWeb(ctx, host, ip, port, !overTLS)
+	}
+	return result, err
+}
+
+// fetchWeb 请求根页面并跟随重定向，记录页面信息、favicon哈希和识别出的Web技术。
+// 请求使用主机名以便虚拟主机和SNI生效，但连接固定到正在识别的IP
+func (s *Scanner) fetchWeb(ctx context.Context, host, ip string, port int, overTLS bool) (*WebResult, error) {
+	scheme := "http"
+	if overTLS {
+		scheme = "https"
+	}
+	origin := net.JoinHostPort(host, strconv.Itoa(port))
+
+	dialer := &net.Dialer{Timeout: s.timeout}
+	redirect := &utils.HostDialer{Pool: s.pool, Limiter: s.limiter, Exclude: s.exclude, Timeout: s.timeout}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			// 重定向到其他主机时通过解析器池解析，按解析出的IP限速，不连接被排除的地址
+			if addr != origin {
+				return redirect.DialContext(ctx, network, addr)
+			}
+			if err := s.limiter.Wait(ctx, ip); err != nil {
+				return nil, err
+			}
+			return dialer.DialContext(ctx, network, net.JoinHostPort(ip, strconv.Itoa(port)))
+		},
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+		TLSHandshakeTimeout: s.timeout,
+	}
+	defer transport.CloseIdleConnections()
+
+	var chain []string
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   s.timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return http.ErrUseLastResponse
+			}
+			chain = append(chain, via[len(via)-1].URL.String())
+			return nil
+		},
+	}
+
+	resp, body, err := webGet(ctx, client, webURL(scheme, host, port))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &WebResult{
+		URL:           resp.Request.URL.String(),
+		StatusCode:    resp.StatusCode,
+		Title:         extractTitle(body),
+		Server:        resp.Header.Get("Server"),
+		ContentLength: resp.ContentLength,
+		Technologies:  s.techs.Analyze(resp.Header, body),
+	}
+	if result.ContentLength < 0 {
+		result.ContentLength = int64(len(body))
+	}
+	if len(chain) > 0 {
+		result.RedirectChain = append(chain, result.URL)
+	}
+
+	for _, icon := range faviconURLs(resp.Request.URL, body) {
+		iconResp, data, err := webGet(ctx, client, icon)
+		if err != nil || iconResp.StatusCode != http.StatusOK || len(data) == 0 {
+			continue
+		}
+		result.FaviconURL = iconResp.Request.URL.String()
+		result.FaviconHash = FaviconHash([]byte(data))
+		break
+	}
+
+	return result, nil
+}
+
+// webGet 发送GET请求并读取响应内容，内容超过maxBodySize时截断
+func webGet(ctx context.Context, client *http.Client, rawURL string) (*http.Response, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", webUserAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil && len(body) == 0 {
+		return nil, "", err
+	}
+	return resp, string(body), nil
+}
+
+// webURL 构造根页面地址，省略协议的默认端口
+func webURL(scheme, host string, port int) string {
+	if (scheme == "http" && port == 80) || (scheme == "https" && port == 443) {
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		return scheme + "://" + host + "/"
+	}
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/"
+}
+
+// extractTitle 提取页面标题，解码HTML实体并合并空白
+func extractTitle(body string) string {
+	m := titlePattern.FindStringSubmatch(body)
+	if m == nil {
+		return ""
+	}
+	title := strings.Join(strings.Fields(html.UnescapeString(m[1])), " ")
+	return strings.ToValidUTF8(title, "")
+}
+
+// faviconURLs 返回favicon的候选地址，页面中声明的图标优先，其次为 /favicon.ico
+func faviconURLs(base *url.URL, body string) []string {
+	var urls []string
+	for _, tag := range parseTags(body) {
+		if tag.name != "link" || tag.attrs["href"] == "" || !hasToken(tag.attrs["rel"], "icon") {
+			continue
+		}
+		if u, err := base.Parse(tag.attrs["href"]); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			urls = append(urls, u.String())
+			break
+		}
+	}
+
+	if u, err := base.Parse("/favicon.ico"); err == nil && (len(urls) == 0 || urls[0] != u.String()) {
+		urls = append(urls, u.String())
+	}
+	return urls
+}
+
+// hasToken 判断以空白分隔的属性值是否包含指定项，不区分大小写
+func hasToken(value, token string) bool {
+	for _, field := range strings.Fields(value) {
+		if strings.EqualFold(field, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package fingerprint
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMurmur3(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int32
+	}{
+		{"", 0},
+		{"foo", -156908512},
+		{"hello", 613153351},
+		{"The quick brown fox jumps over the lazy dog", 776992547},
+	}
+
+	for _, tt := range tests {
+		if got := int32(murmur3([]byte(tt.input), 0)); got != tt.want {
+			t.Errorf("murmur3(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestFaviconHash(t *testing.T) {
+	// 期望值与Shodan的 http.favicon.hash 计算方式一致：mmh3.hash(base64.encodebytes(data))
+	seq := make([]byte, 200)
+	for i := range seq {
+		seq[i] = byte(i)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want int32
+	}{
+		{"单行", []byte("\x89PNG\r\n\x1a\nfavicon"), 1698276212},
+		{"恰好76字符", seq[:57], 459585070},
+		{"超过76字符换行", seq[:58], -280317500},
+		{"多行", seq, -1874651529},
+	}
+
+	for _, tt := range tests {
+		if got := FaviconHash(tt.data); got != tt.want {
+			t.Errorf("FaviconHash(%s) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsWebService(t *testing.T) {
+	tests := []struct {
+		service     string
+		wantWeb     bool
+		wantOverTLS bool
+	}{
+		{"HTTP", true, false},
+		{"http-proxy", true, false},
+		{"ssl/http", true, true},
+		{"https", true, true},
+		{"ssl/imap", false, true},
+		{"SSH", false, false},
+	}
+
+	for _, tt := range tests {
+		web, overTLS := isWebService(tt.service)
+		if web != tt.wantWeb || overTLS != tt.wantOverTLS {
+			t.Errorf("isWebService(%q) = %v %v, want %v %v", tt.service, web, overTLS, tt.wantWeb, tt.wantOverTLS)
+		}
+	}
+}
+
+func TestFetchWeb(t *testing.T) {
+	// 超过57字节，base64编码按76字符换行
+	icon := []byte("\x89PNG\r\n\x1a\n" + strings.Repeat("favicon", 12))
+	const iconHash int32 = 711792850
+
+	var hosts []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+		http.Redirect(w, r, "/home", http.StatusFound)
+	})
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.24.0")
+		w.Header().Set("X-Powered-By", "PHP/8.1.2")
+		http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: "abc"})
+		w.Write([]byte(`<html><head>
+<title>
+  Admin &amp; Login </title>
+<meta name="generator" content="WordPress 6.4.2">
+<link rel="shortcut icon" href="/static/icon.png">
+<script src='/wp-includes/js/jquery/jquery-3.6.0.min.js'></script>
+</head><body></body></html>`))
+	})
+	mux.HandleFunc("/static/icon.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(icon)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+	base := "http://web.test:" + strconv.Itoa(port)
+
+	// 主机名无法解析，连接应固定到指定的IP
+	scanner := NewScanner("web.test", time.Second)
+	got, err := scanner.scanWeb(context.Background(), "web.test", "127.0.0.1", port, false)
+	if err != nil {
+		t.Fatalf("scanWeb() error = %v", err)
+	}
+
+	if len(hosts) != 1 || hosts[0] != "web.test:"+strconv.Itoa(port) {
+		t.Errorf("Host = %v, want web.test:%d", hosts, port)
+	}
+	if got.URL != base+"/home" || got.StatusCode != http.StatusOK {
+		t.Errorf("scanWeb() = %s %d, want %s/home 200", got.URL, got.StatusCode, base)
+	}
+	if want := []string{base + "/", base + "/home"}; !reflect.DeepEqual(got.RedirectChain, want) {
+		t.Errorf("RedirectChain = %v, want %v", got.RedirectChain, want)
+	}
+	if got.Title != "Admin & Login" || got.Server != "nginx/1.24.0" || got.ContentLength == 0 {
+		t.Errorf("scanWeb() = %q %q %d, want title, server and length", got.Title, got.Server, got.ContentLength)
+	}
+	if got.FaviconURL != base+"/static/icon.png" || got.FaviconHash != iconHash {
+		t.Errorf("favicon = %s %d, want %s/static/icon.png %d", got.FaviconURL, got.FaviconHash, base, iconHash)
+	}
+
+	techs := make(map[string]Technology)
+	for _, tech := range got.Technologies {
+		techs[tech.Name] = tech
+	}
+	wantVersions := map[string]string{
+		"WordPress": "6.4.2",
+		"jQuery":    "3.6.0",
+		"Nginx":     "1.24.0",
+		"PHP":       "8.1.2",
+		"MySQL":     "",
+	}
+	for name, version := range wantVersions {
+		if tech, ok := techs[name]; !ok || tech.Version != version {
+			t.Errorf("Technologies[%s] = %+v, want version %q", name, tech, version)
+		}
+	}
+	if cpe := techs["WordPress"].CPE; cpe != "cpe:2.3:a:wordpress:wordpress:6.4.2:*:*:*:*:*:*:*" {
+		t.Errorf("WordPress cpe = %s", cpe)
+	}
+}
+
+func TestFaviconURLs(t *testing.T) {
+	base, _ := http.NewRequest(http.MethodGet, "https://example.com/app/index.html", nil)
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"默认地址", "<html></html>", []string{"https://example.com/favicon.ico"}},
+		{"相对地址", `<link href="img/fav.ico" rel="icon">`,
+			[]string{"https://example.com/app/img/fav.ico", "https://example.com/favicon.ico"}},
+		{"忽略data地址", `<link rel="icon" href="data:image/png;base64,AAAA">`, []string{"https://example.com/favicon.ico"}},
+		{"忽略apple-touch-icon", `<link rel="apple-touch-icon" href="/touch.png">`, []string{"https://example.com/favicon.ico"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := faviconURLs(base.URL, tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("faviconURLs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	"github.com/Marryname/WebScanner/internal/alive"
+	"github.com/Marryname/WebScanner/internal/cdn"
+	"github.com/Marryname/WebScanner/internal/dnsscan"
+	"github.com/Marryname/WebScanner/internal/fingerprint"
+	"github.com/Marryname/WebScanner/internal/portscan"
+	"github.com/Marryname/WebScanner/internal/subdomain"
+	"github.com/Marryname/WebScanner/internal/tlsscan"
+	"github.com/Marryname/WebScanner/internal/vulnscan"
+)
+
+// PortEvent 端口扫描得到的单个端口结果，包括非开放状态
+type PortEvent struct {
+	Target string
+	Result portscan.ScanResult
+}
+
+// Open 端口是否开放
+func (e PortEvent) Open() bool {
+	return e.Result.State == portscan.StateOpen
+}
+
+// AliveEvent 存活探测结果
+type AliveEvent struct {
+	Target string
+	Result *alive.DetectResult
+}
+
+// SubdomainEvent 发现的子域名，来自证书的子域名没有解析记录
+type SubdomainEvent struct {
+	Target string
+	Result subdomain.Result
+}
+
+// DNSEvent 目标的DNS记录
+type DNSEvent struct {
+	Target string
+	Result *dnsscan.ScanResult
+}
+
+// CDNEvent CDN检测结果
+type CDNEvent struct {
+	Target string
+	Info   *cdn.CDNInfo
+}
+
+// ServiceEvent 服务识别结果
+type ServiceEvent struct {
+	Target string
+	Result fingerprint.ScanResult
+}
+
+// TLSEvent 单个端口的TLS检测结果
+type TLSEvent struct {
+	Target string
+	Result tlsscan.ScanResult
+}
+
+// FindingEvent 漏洞扫描发现的问题
+type FindingEvent struct {
+	Target string
+	Result vulnscan.VulnResult
+}
+
+// Bus 扫描流水线中各模块共享的事件总线
+type Bus struct {
+	Ports      Topic[PortEvent]
+	Alive      Topic[AliveEvent]
+	Subdomains Topic[SubdomainEvent]
+	DNS        Topic[DNSEvent]
+	CDN        Topic[CDNEvent]
+	Services   Topic[ServiceEvent]
+	TLS        Topic[TLSEvent]
+	Findings   Topic[FindingEvent]
+}
+
+// NewBus 创建事件总线
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Topics 返回总线上的全部主题
+func (b *Bus) Topics() []Output {
+	return []Output{
+		&b.Ports,
+		&b.Alive,
+		&b.Subdomains,
+		&b.DNS,
+		&b.CDN,
+		&b.Services,
+		&b.TLS,
+		&b.Findings,
+	}
+}
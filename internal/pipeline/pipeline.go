@@ -0,0 +1,162 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Marryname/WebScanner/pkg/utils"
+)
+
+// 每个订阅者通道的缓冲区大小
+const subscriberBuffer = 64
+
+// Output 可由阶段发布事件的主题，阶段结束后主题的发布者计数减一，
+// 所有发布者结束后主题关闭，订阅者的通道随之关闭
+type Output interface {
+	register()
+	release()
+}
+
+// Topic 类型化的事件主题，发布的每个事件都会投递给所有订阅者
+type Topic[T any] struct {
+	mu         sync.Mutex
+	subs       []chan T
+	publishers int
+	closed     bool
+}
+
+// Subscribe 订阅主题，必须在流水线运行前调用，否则可能错过事件；
+// 订阅者需要持续读取直到通道关闭，否则发布者会被阻塞
+func (t *Topic[T]) Subscribe() <-chan T {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ch := make(chan T, subscriberBuffer)
+	if t.closed {
+		close(ch)
+	}
+	t.subs = append(t.subs, ch)
+	return ch
+}
+
+// Publish 将事件投递给所有订阅者，订阅者处理不过来时阻塞直到上下文取消
+func (t *Topic[T]) Publish(ctx context.Context, event T) error {
+	t.mu.Lock()
+	subs := t.subs
+	t.mu.Unlock()
+
+	for _, ch := range subs {
+		select {
+		case ch <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// register 增加一个发布者
+func (t *Topic[T]) register() {
+	t.mu.Lock()
+	t.publishers++
+	t.mu.Unlock()
+}
+
+// release 减少一个发布者，没有发布者时关闭主题
+func (t *Topic[T]) release() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.publishers > 0 {
+		t.publishers--
+	}
+	if t.publishers == 0 && !t.closed {
+		t.closed = true
+		for _, ch := range t.subs {
+			close(ch)
+		}
+	}
+}
+
+// StageError 阶段执行失败的错误
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// stage 流水线中的一个阶段
+type stage struct {
+	name    string
+	run     func(ctx context.Context) error
+	outputs []Output
+}
+
+// release 释放阶段发布的所有主题
+func (s *stage) release() {
+	for _, out := range s.outputs {
+		out.release()
+	}
+}
+
+// Pipeline 并发运行的阶段集合，阶段之间通过主题传递事件
+type Pipeline struct {
+	topics []Output
+	stages []*stage
+}
+
+// New 创建流水线，topics为流水线使用的全部主题，
+// 运行时没有任何阶段发布的主题会被立即关闭，避免订阅者永久等待
+func New(topics ...Output) *Pipeline {
+	return &Pipeline{
+		topics: topics,
+	}
+}
+
+// Stage 添加一个阶段，outputs为该阶段发布事件的主题
+func (p *Pipeline) Stage(name string, run func(ctx context.Context) error, outputs ...Output) {
+	p.stages = append(p.stages, &stage{
+		name:    name,
+		run:     run,
+		outputs: outputs,
+	})
+}
+
+// Run 并发运行所有阶段，等待全部结束后返回由StageError组成的utils.MultiError
+func (p *Pipeline) Run(ctx context.Context) error {
+	for _, s := range p.stages {
+		for _, out := range s.outputs {
+			out.register()
+		}
+	}
+	for _, topic := range p.topics {
+		topic.register()
+		topic.release()
+	}
+
+	group := utils.NewTaskGroup(ctx, len(p.stages), false)
+	for _, s := range p.stages {
+		s := s
+		if err := group.Go(func(ctx context.Context) error {
+			defer s.release()
+
+			if err := s.run(ctx); err != nil {
+				return &StageError{Stage: s.name, Err: err}
+			}
+			return nil
+		}); err != nil {
+			// 未能启动的阶段同样要释放主题，避免订阅者永久等待
+			s.release()
+		}
+	}
+
+	return group.WaitAll()
+}
@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Marryname/WebScanner/pkg/utils"
+)
+
+func TestPipeline(t *testing.T) {
+	var numbers, doubled Topic[int]
+	var unused Topic[string]
+
+	numbersSub := numbers.Subscribe()
+	doubledSub := doubled.Subscribe()
+	unusedSub := unused.Subscribe()
+
+	p := New(&numbers, &doubled, &unused)
+
+	// 生产者
+	p.Stage("produce", func(ctx context.Context) error {
+		for i := 1; i <= 5; i++ {
+			if err := numbers.Publish(ctx, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, &numbers)
+
+	// 消费上游事件并发布到下游
+	p.Stage("double", func(ctx context.Context) error {
+		for n := range numbersSub {
+			if err := doubled.Publish(ctx, n*2); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, &doubled)
+
+	// 收集结果
+	var sum int
+	p.Stage("collect", func(ctx context.Context) error {
+		for n := range doubledSub {
+			sum += n
+		}
+		// 没有发布者的主题应被立即关闭
+		for range unusedSub {
+		}
+		return nil
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- p.Run(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("流水线未能结束")
+	}
+
+	if sum != 30 {
+		t.Errorf("sum = %d, want 30", sum)
+	}
+}
+
+func TestPipelineStageError(t *testing.T) {
+	var topic Topic[int]
+	sub := topic.Subscribe()
+
+	errStage := errors.New("stage failed")
+	p := New(&topic)
+	p.Stage("fail", func(ctx context.Context) error {
+		return errStage
+	}, &topic)
+	p.Stage("drain", func(ctx context.Context) error {
+		for range sub {
+		}
+		return nil
+	})
+
+	err := p.Run(context.Background())
+	errs, ok := err.(utils.MultiError)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("Run() error = %v, want 一个StageError", err)
+	}
+	var stageErr *StageError
+	if !errors.As(errs[0], &stageErr) {
+		t.Fatalf("Run() error = %v, want StageError", errs[0])
+	}
+	if stageErr.Stage != "fail" || !errors.Is(stageErr, errStage) {
+		t.Errorf("StageError = %v, want fail: %v", stageErr, errStage)
+	}
+}
@@ -0,0 +1,79 @@
+package portscan
+
+import (
+	"sync"
+	"time"
+)
+
+// minAdaptiveTimeout 自适应超时的下限，避免局域网内超时过短导致误判，测试中可以调低
+var minAdaptiveTimeout = 100 * time.Millisecond
+
+// rttEstimator 往返时间估算器，参照RFC 6298(与nmap相同)
+// 根据已完成的连接计算平滑RTT及其偏差，推导出每个主机的自适应超时
+type rttEstimator struct {
+	mu      sync.Mutex
+	srtt    time.Duration
+	rttvar  time.Duration
+	samples int
+	initial time.Duration
+	max     time.Duration
+}
+
+// newRTTEstimator 创建估算器，在获得样本前使用初始超时，且超时不超过该值
+func newRTTEstimator(initial time.Duration) *rttEstimator {
+	return &rttEstimator{
+		initial: initial,
+		max:     initial,
+	}
+}
+
+// Update 记录一次成功往返(SYN-ACK或RST)的耗时
+func (e *rttEstimator) Update(rtt time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.samples == 0 {
+		e.srtt = rtt
+		e.rttvar = rtt / 2
+	} else {
+		delta := e.srtt - rtt
+		if delta < 0 {
+			delta = -delta
+		}
+		// RTTVAR = 3/4 RTTVAR + 1/4 |SRTT - R|, SRTT = 7/8 SRTT + 1/8 R
+		e.rttvar = (3*e.rttvar + delta) / 4
+		e.srtt = (7*e.srtt + rtt) / 8
+	}
+	e.samples++
+}
+
+// Timeout 返回当前的自适应超时: SRTT + 4*RTTVAR
+func (e *rttEstimator) Timeout() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.samples == 0 {
+		return e.initial
+	}
+
+	timeout := e.srtt + 4*e.rttvar
+	if timeout < minAdaptiveTimeout {
+		timeout = minAdaptiveTimeout
+	}
+	if timeout > e.max {
+		timeout = e.max
+	}
+	return timeout
+}
+
+// backoff 返回第attempt次重传使用的超时，每次重传翻倍且不超过上限
+func (e *rttEstimator) backoff(attempt int) time.Duration {
+	timeout := e.Timeout()
+	for i := 0; i < attempt && timeout < e.max; i++ {
+		timeout *= 2
+	}
+	if timeout > e.max {
+		timeout = e.max
+	}
+	return timeout
+}
@@ -0,0 +1,39 @@
+package portscan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRTTEstimator(t *testing.T) {
+	rtt := newRTTEstimator(3 * time.Second)
+
+	// 没有样本时使用初始超时
+	if got := rtt.Timeout(); got != 3*time.Second {
+		t.Errorf("Timeout() = %v, want %v", got, 3*time.Second)
+	}
+
+	// 稳定的RTT应收敛到较小的超时，且不低于下限
+	for i := 0; i < 20; i++ {
+		rtt.Update(time.Millisecond)
+	}
+	if got := rtt.Timeout(); got != minAdaptiveTimeout {
+		t.Errorf("Timeout() = %v, want %v", got, minAdaptiveTimeout)
+	}
+
+	// 重传时超时翻倍
+	if got := rtt.backoff(2); got != 4*minAdaptiveTimeout {
+		t.Errorf("backoff(2) = %v, want %v", got, 4*minAdaptiveTimeout)
+	}
+
+	// 较大的RTT样本会提高超时，但不超过上限
+	for i := 0; i < 20; i++ {
+		rtt.Update(2 * time.Second)
+	}
+	if got := rtt.Timeout(); got <= time.Second || got > 3*time.Second {
+		t.Errorf("Timeout() = %v, want (1s, 3s]", got)
+	}
+	if got := rtt.backoff(5); got != 3*time.Second {
+		t.Errorf("backoff(5) = %v, want %v", got, 3*time.Second)
+	}
+}
@@ -2,37 +2,307 @@ package portscan
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
+
+	"github.com/Marryname/WebScanner/pkg/common"
+	"github.com/Marryname/WebScanner/pkg/utils"
+)
+
+// 端口状态
+const (
+	StateOpen     = "open"
+	StateClosed   = "closed"
+	StateFiltered = "filtered"
+	// StateOpenFiltered UDP端口无响应，无法区分开放和被过滤
+	StateOpenFiltered = "open|filtered"
+)
+
+// 扫描类型，同时作为结果中的协议名称
+const (
+	ScanTCP = "tcp"
+	ScanUDP = "udp"
+)
+
+// 端口状态的判定依据
+const (
+	ReasonSynAck      = "syn-ack"          // 连接建立成功
+	ReasonConnRefused = "conn-refused"     // 收到RST
+	ReasonNoResponse  = "no-response"      // 重传后仍然超时
+	ReasonUnreachable = "host-unreachable" // 主机或网络不可达
+	ReasonUDPResponse = "udp-response"     // 收到UDP响应
+	ReasonPortUnreach = "port-unreach"     // 收到ICMP端口不可达
 )
 
+// commonServices 常见端口对应的服务名称
+var commonServices = map[int]string{
+	21:    "ftp",
+	22:    "ssh",
+	23:    "telnet",
+	25:    "smtp",
+	53:    "domain",
+	80:    "http",
+	110:   "pop3",
+	135:   "msrpc",
+	139:   "netbios-ssn",
+	143:   "imap",
+	443:   "https",
+	445:   "microsoft-ds",
+	465:   "smtps",
+	587:   "submission",
+	993:   "imaps",
+	995:   "pop3s",
+	1433:  "ms-sql-s",
+	1521:  "oracle",
+	3306:  "mysql",
+	3389:  "ms-wbt-server",
+	5432:  "postgresql",
+	5900:  "vnc",
+	6379:  "redis",
+	8080:  "http-proxy",
+	8443:  "https-alt",
+	27017: "mongodb",
+}
+
+// ScanResult 端口扫描结果
 type ScanResult struct {
-	Port    int
-	State   string
-	Service string
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	State    string `json:"state"`
+	Reason   string `json:"reason,omitempty"`
+	Attempts int    `json:"attempts,omitempty"` // 探测次数，包含重传
+	Service  string `json:"service,omitempty"`
 }
 
+// PortScanner TCP连接端口扫描器
 type PortScanner struct {
-	target  string
-	timeout time.Duration
+	target     string
+	ports      []int
+	timeout    time.Duration
+	concurrent int
+	retries    int
+	scanType   string
+	limiter    *utils.RateLimiter
+	pool       *utils.ResolverPool
+	exclude    *common.ExcludeList
+	onResult   func(ScanResult)
 }
 
-func NewPortScanner(target string, timeout time.Duration) *PortScanner {
+// NewPortScanner 创建新的端口扫描器
+func NewPortScanner(target string, ports []int, timeout time.Duration, concurrent int) *PortScanner {
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	if concurrent <= 0 {
+		concurrent = 100
+	}
+
 	return &PortScanner{
-		target:  target,
-		timeout: timeout,
+		target:     target,
+		ports:      ports,
+		timeout:    timeout,
+		concurrent: concurrent,
+		scanType:   ScanTCP,
 	}
 }
 
+// SetRateLimiter 设置共享的速率限制器
+func (s *PortScanner) SetRateLimiter(limiter *utils.RateLimiter) {
+	s.limiter = limiter
+}
+
+// SetResolverPool 设置解析目标使用的解析器池，为空时使用系统解析器
+func (s *PortScanner) SetResolverPool(pool *utils.ResolverPool) {
+	s.pool = pool
+}
+
+// SetExcludeList 设置排除列表，解析出的地址在列表中时不连接
+func (s *PortScanner) SetExcludeList(exclude *common.ExcludeList) {
+	s.exclude = exclude
+}
+
+// OnResult 设置结果回调，每个端口扫描完成时立即调用，回调在同一个协程中串行执行
+func (s *PortScanner) OnResult(fn func(ScanResult)) {
+	s.onResult = fn
+}
+
+// SetScanType 设置扫描类型 (tcp|udp)
+func (s *PortScanner) SetScanType(scanType string) error {
+	switch scanType {
+	case ScanTCP, ScanUDP:
+		s.scanType = scanType
+		return nil
+	}
+	return fmt.Errorf("不支持的扫描类型: %s", scanType)
+}
+
+// SetRetries 设置超时端口的重传次数
+func (s *PortScanner) SetRetries(retries int) {
+	if retries < 0 {
+		retries = 0
+	}
+	s.retries = retries
+}
+
+// Scan 按扫描类型对所有端口执行扫描，结果按端口号排序
 func (s *PortScanner) Scan(ctx context.Context) ([]ScanResult, error) {
-	var results []ScanResult
-
-	// 这里实现实际的端口扫描逻辑
-	// 目前返回一个示例结果
-	results = append(results, ScanResult{
-		Port:    80,
-		State:   "open",
-		Service: "http",
+	ip, err := s.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 每个主机单独估算RTT，超时不超过用户指定的值
+	rtt := newRTTEstimator(s.timeout)
+
+	scanPort := s.scanPort
+	if s.scanType == ScanUDP {
+		scanPort = s.scanUDPPort
+	}
+
+	workers := s.concurrent
+	if workers > len(s.ports) {
+		workers = len(s.ports)
+	}
+
+	var (
+		results    []ScanResult
+		wg         sync.WaitGroup
+		portChan   = make(chan int)
+		resultChan = make(chan ScanResult, workers)
+	)
+
+	// 启动工作协程
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for port := range portChan {
+				if result, ok := scanPort(ctx, rtt, ip, port); ok {
+					resultChan <- result
+				}
+			}
+		}()
+	}
+
+	// 发送端口到工作通道
+	go func() {
+		defer close(portChan)
+		for _, port := range s.ports {
+			select {
+			case <-ctx.Done():
+				return
+			case portChan <- port:
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	for result := range resultChan {
+		if s.onResult != nil {
+			s.onResult(result)
+		}
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Port < results[j].Port
 	})
 
-	return results, nil
+	return results, ctx.Err()
+}
+
+// resolve 解析扫描目标，跳过被排除的地址，优先使用IPv4地址
+func (s *PortScanner) resolve(ctx context.Context) (string, error) {
+	addrs, err := utils.LookupIP(ctx, s.pool, s.target)
+	if err != nil {
+		return "", fmt.Errorf("解析目标失败: %v", err)
+	}
+	if addrs, err = s.exclude.FilterIPs(s.target, addrs); err != nil {
+		return "", err
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("解析目标失败: %s 没有可用地址", s.target)
+	}
+
+	for _, addr := range addrs {
+		if addr.To4() != nil {
+			return addr.String(), nil
+		}
+	}
+	return addrs[0].String(), nil
+}
+
+// scanPort 扫描单个TCP端口，超时的端口按自适应超时重传，上下文被取消时返回false
+func (s *PortScanner) scanPort(ctx context.Context, rtt *rttEstimator, ip string, port int) (ScanResult, bool) {
+	result := ScanResult{
+		Port:     port,
+		Protocol: ScanTCP,
+		Service:  commonServices[port],
+	}
+	addr := net.JoinHostPort(ip, strconv.Itoa(port))
+
+	for attempt := 0; attempt <= s.retries; attempt++ {
+		result.Attempts = attempt + 1
+		if err := s.limiter.Wait(ctx, ip); err != nil {
+			return result, false
+		}
+
+		start := time.Now()
+		dialer := net.Dialer{Timeout: rtt.backoff(attempt)}
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			rtt.Update(time.Since(start))
+			conn.Close()
+			result.State, result.Reason = StateOpen, ReasonSynAck
+			return result, true
+		}
+
+		if ctx.Err() != nil {
+			return result, false
+		}
+
+		result.State, result.Reason = classifyError(err)
+		switch result.Reason {
+		case ReasonConnRefused:
+			// RST同样是一次完整的往返
+			rtt.Update(time.Since(start))
+			return result, true
+		case ReasonUnreachable:
+			return result, true
+		}
+	}
+
+	return result, true
+}
+
+// classifyError 根据连接错误判断端口状态及依据
+func classifyError(err error) (string, string) {
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return StateClosed, ReasonConnRefused
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return StateFiltered, ReasonUnreachable
+	}
+	return StateFiltered, ReasonNoResponse
+}
+
+// OpenPorts 返回扫描结果中处于开放状态的端口
+func OpenPorts(results []ScanResult) []int {
+	var ports []int
+	for _, result := range results {
+		if result.State == StateOpen {
+			ports = append(ports, result.Port)
+		}
+	}
+	return ports
 }
@@ -0,0 +1,80 @@
+package portscan
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// silentPort 返回一个不响应SYN的本地端口：监听队列长度为0且已被一个连接占满，
+// 内核会丢弃之后的SYN，连接只能超时
+func silentPort(t *testing.T) int {
+	t.Helper()
+
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Listen(fd, 0); err != nil {
+		t.Fatal(err)
+	}
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := sa.(*syscall.SockaddrInet4).Port
+
+	// 占满监听队列，连接不被accept
+	conn, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Close(conn) })
+	if err := syscall.Connect(conn, &syscall.SockaddrInet4{Port: port, Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+func TestPortScannerRetransmit(t *testing.T) {
+	floor := minAdaptiveTimeout
+	minAdaptiveTimeout = 20 * time.Millisecond
+	t.Cleanup(func() { minAdaptiveTimeout = floor })
+
+	closed := closedPort(t)
+	silent := silentPort(t)
+
+	// 单个协程按顺序扫描，关闭端口的RST先提供RTT样本，无响应端口的超时从下限开始翻倍
+	scanner := NewPortScanner("127.0.0.1", []int{closed, silent}, 2*time.Second, 1)
+	scanner.SetRetries(2)
+
+	start := time.Now()
+	results, err := scanner.Scan(context.Background())
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	want := map[int]ScanResult{
+		closed: {Port: closed, Protocol: ScanTCP, State: StateClosed, Reason: ReasonConnRefused, Attempts: 1},
+		silent: {Port: silent, Protocol: ScanTCP, State: StateFiltered, Reason: ReasonNoResponse, Attempts: 3},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("Scan() = %+v", results)
+	}
+	for _, result := range results {
+		if result != want[result.Port] {
+			t.Errorf("result = %+v, want %+v", result, want[result.Port])
+		}
+	}
+
+	// 三次探测的超时为 20ms、40ms、80ms；不使用自适应超时时每次都要等待2s
+	if elapsed < 140*time.Millisecond || elapsed >= 2*time.Second {
+		t.Errorf("Scan() 耗时 %v, want [140ms, 2s)", elapsed)
+	}
+}
@@ -2,11 +2,49 @@ package portscan
 
 import (
 	"context"
+	"errors"
+	"net"
 	"testing"
 	"time"
+
+	"github.com/Marryname/WebScanner/pkg/common"
 )
 
+// listenLocal 在本地启动一个TCP监听，返回监听端口
+func listenLocal(t *testing.T) (net.Listener, int) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动本地监听失败: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	return ln, ln.Addr().(*net.TCPAddr).Port
+}
+
+// closedPort 返回一个当前未被监听的本地端口
+func closedPort(t *testing.T) int {
+	t.Helper()
+
+	ln, port := listenLocal(t)
+	ln.Close()
+	return port
+}
+
 func TestPortScanner(t *testing.T) {
+	ln, openPort := listenLocal(t)
+	defer ln.Close()
+	closed := closedPort(t)
+
 	tests := []struct {
 		name    string
 		target  string
@@ -15,21 +53,22 @@ func TestPortScanner(t *testing.T) {
 	}{
 		{
 			name:    "测试有效目标",
-			target:  "localhost",
-			timeout: 5 * time.Second,
+			target:  "127.0.0.1",
+			timeout: 2 * time.Second,
 			wantErr: false,
 		},
 		{
 			name:    "测试无效目标",
-			target:  "invalid-host",
-			timeout: 5 * time.Second,
-			wantErr: false, // 目前示例实现总是返回成功
+			target:  "invalid-host.invalid",
+			timeout: 2 * time.Second,
+			wantErr: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			scanner := NewPortScanner(tt.target, tt.timeout)
+			scanner := NewPortScanner(tt.target, []int{closed, openPort}, tt.timeout, 10)
+			scanner.SetRetries(2)
 
 			results, err := scanner.Scan(context.Background())
 
@@ -37,30 +76,62 @@ func TestPortScanner(t *testing.T) {
 				t.Errorf("Scan() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantErr {
+				return
+			}
 
 			// 验证结果
-			if len(results) == 0 {
-				t.Log("没有发现开放端口")
-			} else {
-				for _, result := range results {
-					t.Logf("发现开放端口: %d (%s)", result.Port, result.Service)
-				}
+			byPort := make(map[int]ScanResult)
+			for _, result := range results {
+				t.Logf("端口 %d: %s (%s, %d 次)", result.Port, result.State, result.Reason, result.Attempts)
+				byPort[result.Port] = result
+			}
+			if got := byPort[openPort]; got.State != StateOpen || got.Reason != ReasonSynAck {
+				t.Errorf("端口 %d = %s/%s, want %s/%s", openPort, got.State, got.Reason, StateOpen, ReasonSynAck)
+			}
+			// 收到RST的端口不应重传
+			if got := byPort[closed]; got.State != StateClosed || got.Reason != ReasonConnRefused || got.Attempts != 1 {
+				t.Errorf("端口 %d = %s/%s (%d 次), want %s/%s (1 次)",
+					closed, got.State, got.Reason, got.Attempts, StateClosed, ReasonConnRefused)
+			}
+			if got := OpenPorts(results); len(got) != 1 || got[0] != openPort {
+				t.Errorf("OpenPorts() = %v, want [%d]", got, openPort)
 			}
 		})
 	}
 }
 
 func TestPortScannerWithContext(t *testing.T) {
-	scanner := NewPortScanner("localhost", 5*time.Second)
+	ports := make([]int, 0, 1000)
+	for port := 1; port <= 1000; port++ {
+		ports = append(ports, port)
+	}
+	scanner := NewPortScanner("127.0.0.1", ports, 5*time.Second, 1)
 
 	// 测试上下文取消
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
-	defer cancel()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
 
 	results, err := scanner.Scan(ctx)
-	if err != nil {
-		t.Logf("预期的上下文取消错误: %v", err)
-	} else {
-		t.Logf("扫描完成，发现 %d 个开放端口", len(results))
+	if err != context.Canceled {
+		t.Errorf("期望上下文取消错误，得到: %v", err)
+	}
+	t.Logf("扫描被取消，返回 %d 个结果", len(results))
+}
+
+func TestPortScannerExclude(t *testing.T) {
+	exclude := common.NewExcludeList()
+	if err := exclude.Add("127.0.0.0/8, ::1/128"); err != nil {
+		t.Fatal(err)
+	}
+
+	// localhost 解析出的地址被排除时不扫描任何端口
+	scanner := NewPortScanner("localhost", []int{80}, time.Second, 1)
+	scanner.SetExcludeList(exclude)
+	scanner.OnResult(func(result ScanResult) {
+		t.Errorf("被排除的目标不应产生结果: %+v", result)
+	})
+	if _, err := scanner.Scan(context.Background()); !errors.Is(err, common.ErrExcluded) {
+		t.Errorf("Scan() error = %v, want ErrExcluded", err)
 	}
 }
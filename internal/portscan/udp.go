@@ -0,0 +1,121 @@
+package portscan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// commonUDPServices 常见UDP端口对应的服务名称
+var commonUDPServices = map[int]string{
+	53:    "domain",
+	67:    "dhcps",
+	69:    "tftp",
+	111:   "rpcbind",
+	123:   "ntp",
+	137:   "netbios-ns",
+	161:   "snmp",
+	500:   "isakmp",
+	1434:  "ms-sql-m",
+	1900:  "upnp",
+	5353:  "zeroconf",
+	11211: "memcache",
+}
+
+// dnsProbe 查询根域NS记录的标准DNS请求
+var dnsProbe = []byte("\x12\x34\x01\x00\x00\x01\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x02\x00\x01")
+
+// udpProbes 各端口对应的协议探测载荷，UDP服务通常只响应合法请求
+var udpProbes = map[int][]byte{
+	// DNS
+	53:   dnsProbe,
+	5353: dnsProbe,
+	// TFTP 读请求
+	69: []byte("\x00\x01r7tftp.txt\x00octet\x00"),
+	// SunRPC portmapper NULL调用
+	111: []byte("\x72\xfe\x1d\x13\x00\x00\x00\x00\x00\x00\x00\x02\x00\x01\x86\xa0" +
+		"\x00\x00\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+		"\x00\x00\x00\x00\x00\x00\x00\x00"),
+	// NTP v4 客户端请求
+	123: append([]byte{0xe3}, make([]byte, 47)...),
+	// NetBIOS NBSTAT 查询
+	137: []byte("\x80\xf0\x00\x10\x00\x01\x00\x00\x00\x00\x00\x00" +
+		"\x20CKAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\x00\x00\x21\x00\x01"),
+	// SNMPv1 get-request, community public
+	161: []byte("\x30\x26\x02\x01\x00\x04\x06public\xa0\x19\x02\x04\x71\xb4\xb5\x68" +
+		"\x02\x01\x00\x02\x01\x00\x30\x0b\x30\x09\x06\x05\x2b\x06\x01\x02\x01\x05\x00"),
+	// MSSQL Browser 枚举实例
+	1434: []byte("\x02"),
+	// SSDP 发现
+	1900: []byte("M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\nMX: 1\r\nST: ssdp:all\r\n\r\n"),
+	// memcached stats
+	11211: []byte("\x00\x01\x00\x00\x00\x01\x00\x00stats\r\n"),
+}
+
+// udpPayload 返回端口对应的探测载荷，未知端口发送空数据报
+func udpPayload(port int) []byte {
+	return udpProbes[port]
+}
+
+// scanUDPPort 扫描单个UDP端口
+// 收到响应为open，收到ICMP端口不可达为closed，重传后仍无响应为open|filtered
+func (s *PortScanner) scanUDPPort(ctx context.Context, rtt *rttEstimator, ip string, port int) (ScanResult, bool) {
+	result := ScanResult{
+		Port:     port,
+		Protocol: ScanUDP,
+		Service:  commonUDPServices[port],
+	}
+
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "udp", net.JoinHostPort(ip, strconv.Itoa(port)))
+	if err != nil {
+		if ctx.Err() != nil {
+			return result, false
+		}
+		result.State, result.Reason = StateFiltered, ReasonUnreachable
+		return result, true
+	}
+	defer conn.Close()
+
+	payload := udpPayload(port)
+	buf := make([]byte, 1500)
+
+	for attempt := 0; attempt <= s.retries; attempt++ {
+		result.Attempts = attempt + 1
+		if err := s.limiter.Wait(ctx, ip); err != nil {
+			return result, false
+		}
+
+		start := time.Now()
+		conn.SetDeadline(start.Add(rtt.backoff(attempt)))
+
+		// 已连接的UDP套接字会在后续读写时返回之前收到的ICMP端口不可达
+		_, err := conn.Write(payload)
+		if err == nil {
+			_, err = conn.Read(buf)
+		}
+
+		if ctx.Err() != nil {
+			return result, false
+		}
+
+		switch {
+		case err == nil:
+			rtt.Update(time.Since(start))
+			result.State, result.Reason = StateOpen, ReasonUDPResponse
+			return result, true
+		case errors.Is(err, syscall.ECONNREFUSED):
+			rtt.Update(time.Since(start))
+			result.State, result.Reason = StateClosed, ReasonPortUnreach
+			return result, true
+		}
+	}
+
+	result.State, result.Reason = StateOpenFiltered, ReasonNoResponse
+	return result, true
+}
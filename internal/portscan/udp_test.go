@@ -0,0 +1,91 @@
+package portscan
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// listenUDP 启动本地UDP服务，对收到的非空数据报回显
+func listenUDP(t *testing.T) (net.PacketConn, int) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动本地UDP监听失败: %v", err)
+	}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	return conn, conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestUDPScan(t *testing.T) {
+	conn, openPort := listenUDP(t)
+	defer conn.Close()
+
+	// 绑定后立即关闭，得到一个会返回ICMP端口不可达的端口
+	closedConn, closed := listenUDP(t)
+	closedConn.Close()
+
+	scanner := NewPortScanner("127.0.0.1", []int{openPort, closed}, time.Second, 2)
+	if err := scanner.SetScanType(ScanUDP); err != nil {
+		t.Fatalf("SetScanType() error = %v", err)
+	}
+
+	results, err := scanner.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	byPort := make(map[int]ScanResult)
+	for _, result := range results {
+		t.Logf("端口 %d/%s: %s (%s)", result.Port, result.Protocol, result.State, result.Reason)
+		if result.Protocol != ScanUDP {
+			t.Errorf("端口 %d 协议 = %s, want %s", result.Port, result.Protocol, ScanUDP)
+		}
+		byPort[result.Port] = result
+	}
+	if got := byPort[openPort]; got.State != StateOpen || got.Reason != ReasonUDPResponse {
+		t.Errorf("端口 %d = %s/%s, want %s/%s", openPort, got.State, got.Reason, StateOpen, ReasonUDPResponse)
+	}
+	if got := byPort[closed]; got.State != StateClosed || got.Reason != ReasonPortUnreach {
+		t.Errorf("端口 %d = %s/%s, want %s/%s", closed, got.State, got.Reason, StateClosed, ReasonPortUnreach)
+	}
+}
+
+func TestUDPPayload(t *testing.T) {
+	// DNS探测必须是包含一个问题的合法请求
+	dns := udpPayload(53)
+	if len(dns) < 12 || binary.BigEndian.Uint16(dns[4:6]) != 1 {
+		t.Errorf("DNS探测载荷无效: %x", dns)
+	}
+
+	// NTP探测为48字节的客户端模式请求
+	ntp := udpPayload(123)
+	if len(ntp) != 48 || ntp[0]&0x07 != 3 {
+		t.Errorf("NTP探测载荷无效: %x", ntp)
+	}
+
+	// SNMP探测为BER编码的SEQUENCE，长度字段与实际长度一致
+	snmp := udpPayload(161)
+	if snmp[0] != 0x30 || int(snmp[1]) != len(snmp)-2 || !bytes.Contains(snmp, []byte("public")) {
+		t.Errorf("SNMP探测载荷无效: %x", snmp)
+	}
+
+	// 未知端口发送空数据报
+	if got := udpPayload(40000); len(got) != 0 {
+		t.Errorf("udpPayload(40000) = %x, want empty", got)
+	}
+}
@@ -1,26 +1,454 @@
 package subdomain
 
 import (
+	"bufio"
 	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Marryname/WebScanner/pkg/common"
+	"github.com/Marryname/WebScanner/pkg/utils"
 )
 
+// 内置子域名字典
+//
+//go:embed wordlist.txt
+var builtinWordlist string
+
+// DefaultResolvers 未指定解析器时使用的公共DNS服务器
+var DefaultResolvers = []string{"223.5.5.5", "119.29.29.29", "114.114.114.114", "8.8.8.8", "1.1.1.1"}
+
+// Result 子域名及其解析记录，Sources 为发现该子域名的数据源
+type Result struct {
+	Name    string   `json:"name"`
+	A       []string `json:"a,omitempty"`
+	AAAA    []string `json:"aaaa,omitempty"`
+	CNAME   string   `json:"cname,omitempty"`
+	Sources []string `json:"sources,omitempty"`
+}
+
+// Merge 合并同一子域名的另一结果，来源取并集，本结果没有解析记录时使用另一结果的记录
+func (r *Result) Merge(other Result) {
+	r.Sources = appendUnique(r.Sources, other.Sources...)
+	sort.Strings(r.Sources)
+	if len(r.A)+len(r.AAAA) == 0 && len(other.A)+len(other.AAAA) > 0 {
+		r.A, r.AAAA, r.CNAME = other.A, other.AAAA, other.CNAME
+	}
+}
+
+// Finder 子域名发现，使用字典爆破和被动数据源收集子域名，通过解析器池验证
 type Finder struct {
-	domain string
+	domain     string
+	words      []string
+	sources    []Source
+	techniques []string
+	resolvers  []string
+	timeout    time.Duration
+	concurrent int
+	limiter    *utils.RateLimiter
+	// pool 共享的解析器池，为空时按 resolvers 为每次 Find 创建
+	pool *utils.ResolverPool
+
+	// 是否为发现的子域名生成排列，以及递归爆破的最大层级
+	permute bool
+	depth   int
+
+	// 区域传送和NSEC遍历连接权威服务器使用的端口
+	nsPort string
+
+	// 最近一次 Find 过滤掉的子域名、检测到的泛解析和出错的数据源
+	filtered     []Filtered
+	wildcards    []Wildcard
+	sourceErrors map[string]error
 }
 
+// NewFinder 创建使用内置字典和默认解析器的子域名发现器
 func NewFinder(domain string) *Finder {
 	return &Finder{
-		domain: domain,
+		domain:     strings.ToLower(strings.TrimSuffix(domain, ".")),
+		words:      DefaultWordlist(),
+		resolvers:  DefaultResolvers,
+		timeout:    3 * time.Second,
+		concurrent: 50,
+		nsPort:     "53",
+	}
+}
+
+// SetWordlist 设置爆破使用的子域名前缀，替换内置字典
+func (f *Finder) SetWordlist(words []string) {
+	if len(words) > 0 {
+		f.words = words
+	}
+}
+
+// SetSources 设置被动数据源
+func (f *Finder) SetSources(sources []Source) {
+	f.sources = sources
+}
+
+// SetTechniques 设置使用的DNS协议发现技术：axfr 向各权威服务器请求区域传送，
+// srv 查询常见的SRV记录，nsec 在区域启用DNSSEC时沿NSEC记录遍历。未知的技术返回错误
+func (f *Finder) SetTechniques(techniques []string) error {
+	for _, technique := range techniques {
+		if !contains(DNSTechniques, technique) {
+			return fmt.Errorf("未知的发现技术: %s", technique)
+		}
+	}
+	f.techniques = techniques
+	return nil
+}
+
+// SetPermutations 设置是否为发现的子域名生成排列(dev-www、www-01、web02 等)并解析验证
+func (f *Finder) SetPermutations(enabled bool) {
+	f.permute = enabled
+}
+
+// SetRecursion 设置递归爆破的层级，发现的子域名层级不超过depth时继续用字典爆破其下一级，
+// 如depth为1时发现 dev.example.com 后会查询 www.dev.example.com。0表示不递归
+func (f *Finder) SetRecursion(depth int) {
+	if depth >= 0 {
+		f.depth = depth
+	}
+}
+
+// SetResolvers 设置解析器池，地址可以是IP或 host:port
+func (f *Finder) SetResolvers(servers []string) {
+	if len(servers) > 0 {
+		f.resolvers = servers
+	}
+}
+
+// SetTimeout 设置单次查询的超时时间
+func (f *Finder) SetTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		f.timeout = timeout
+	}
+}
+
+// SetConcurrent 设置同时查询的子域名数量
+func (f *Finder) SetConcurrent(concurrent int) {
+	if concurrent > 0 {
+		f.concurrent = concurrent
+	}
+}
+
+// SetResolverPool 设置共享的解析器池，解析器的统计和剔除结果在多次 Find 之间保留，
+// 设置后 SetResolvers 和 SetRateLimiter 不再生效
+func (f *Finder) SetResolverPool(pool *utils.ResolverPool) {
+	f.pool = pool
+}
+
+// SetRateLimiter 设置共享的速率限制器，按解析器地址限速
+func (f *Finder) SetRateLimiter(limiter *utils.RateLimiter) {
+	f.limiter = limiter
+}
+
+// Find 合并字典爆破、被动数据源和DNS协议发现技术得到的候选子域名并逐个解析，只返回能解析出地址的子域名，
+// 结果按名称排序并记录来源。开启排列和递归时，新发现的子域名会继续生成候选并解析。父域名存在泛解析时，只是泛解析应答的子域名会被过滤，
+// 可通过 Filtered 查看；数据源出错不影响其他结果，可通过 SourceErrors 查看。
+// 所有查询都因解析器出错而失败时返回错误
+func (f *Finder) Find(ctx context.Context) ([]Result, error) {
+	if !common.IsValidDomain(f.domain) {
+		return nil, fmt.Errorf("无效的域名: %s", f.domain)
+	}
+
+	pool := f.pool
+	if pool == nil {
+		pool = utils.NewResolverPool(f.resolvers, f.timeout)
+		pool.SetRateLimiter(f.limiter)
+	}
+	if pool.Size() == 0 {
+		return nil, fmt.Errorf("没有可用的DNS解析器")
+	}
+
+	// 候选子域名及其来源
+	candidates := make(map[string][]string)
+	for _, word := range f.words {
+		name := word + "." + f.domain
+		candidates[name] = appendUnique(candidates[name], SourceBruteforce)
+	}
+	sources := append([]Source{}, f.sources...)
+	client := utils.NewDNSClient(f.timeout)
+	for _, technique := range f.techniques {
+		sources = append(sources, &dnsSource{name: technique, finder: f, pool: pool, client: client})
+	}
+	for name, sources := range f.enumerateSources(ctx, sources) {
+		candidates[name] = appendUnique(candidates[name], sources...)
+	}
+
+	d := &discovery{finder: f, pool: pool, cache: newWildcardCache(), seen: make(map[string]bool)}
+	found, err := d.round(ctx, candidates)
+	if err == nil {
+		err = d.expand(ctx, found)
+	}
+
+	sort.Slice(d.results, func(i, j int) bool {
+		return d.results[i].Name < d.results[j].Name
+	})
+	sort.Slice(d.filtered, func(i, j int) bool {
+		return d.filtered[i].Result.Name < d.filtered[j].Result.Name
+	})
+	f.filtered = d.filtered
+	f.wildcards = d.cache.wildcards()
+	if err != nil && ctx.Err() == nil {
+		return nil, err
+	}
+	return d.results, err
+}
+
+// discovery 一次 Find 的查询状态，按轮次解析候选并累积结果
+type discovery struct {
+	finder *Finder
+	pool   *utils.ResolverPool
+	cache  *wildcardCache
+	// 已查询过的名称，后续轮次不再重复查询
+	seen     map[string]bool
+	results  []Result
+	filtered []Filtered
+}
+
+// round 解析一轮候选并累积结果，返回本轮新发现的子域名
+func (d *discovery) round(ctx context.Context, candidates map[string][]string) ([]Result, error) {
+	for name := range candidates {
+		d.seen[name] = true
+	}
+	results, filtered, err := d.finder.resolveCandidates(ctx, d.pool, d.cache, candidates)
+	d.results = append(d.results, results...)
+	d.filtered = append(d.filtered, filtered...)
+	return results, err
+}
+
+// expand 为新发现的子域名生成排列，并在深度允许时用字典递归爆破其下一级，
+// 直到没有新的子域名。首轮之后的查询全部失败不算错误，只有上下文取消时返回错误
+func (d *discovery) expand(ctx context.Context, found []Result) error {
+	f := d.finder
+	for len(found) > 0 {
+		if f.permute {
+			candidates := make(map[string][]string)
+			for _, result := range found {
+				for _, name := range permutations(result.Name, f.domain, defaultPermutationWords) {
+					if !d.seen[name] && len(candidates) < maxPermutations {
+						candidates[name] = []string{SourcePermutation}
+					}
+				}
+			}
+			results, _ := d.round(ctx, candidates)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			found = append(found, results...)
+		}
+
+		candidates := make(map[string][]string)
+		for _, result := range found {
+			if subdomainDepth(result.Name, f.domain) > f.depth {
+				continue
+			}
+			for _, word := range f.words {
+				if name := word + "." + result.Name; !d.seen[name] {
+					candidates[name] = []string{SourceRecursive}
+				}
+			}
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+		found, _ = d.round(ctx, candidates)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enumerateSources 并发查询全部数据源，返回名称及提供该名称的数据源
+func (f *Finder) enumerateSources(ctx context.Context, sources []Source) map[string][]string {
+	var mu sync.Mutex
+	names := make(map[string][]string)
+	f.sourceErrors = make(map[string]error)
+
+	group := utils.NewTaskGroup(ctx, len(sources)+1, false)
+	for _, source := range sources {
+		source := source
+		group.Go(func(ctx context.Context) error {
+			results, err := source.Enumerate(ctx, f.domain)
+			if err != nil {
+				mu.Lock()
+				f.sourceErrors[source.Name()] = err
+				mu.Unlock()
+			}
+			// 响应解析出错时出错前得到的结果仍然有效
+			if results == nil {
+				return nil
+			}
+			for result := range results {
+				mu.Lock()
+				names[result.Name] = appendUnique(names[result.Name], source.Name())
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	group.Wait()
+	return names
+}
+
+// resolveCandidates 并发解析候选子域名，返回解析成功的结果和被泛解析过滤的结果，
+// 都按名称排序。候选全部因解析器出错而失败时返回错误
+func (f *Finder) resolveCandidates(ctx context.Context, pool *utils.ResolverPool, cache *wildcardCache,
+	candidates map[string][]string) ([]Result, []Filtered, error) {
+	names := make([]string, 0, len(candidates))
+	for name := range candidates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		results  []Result
+		filtered []Filtered
+		failed   int
+		lastErr  error
+		mu       sync.Mutex
+	)
+
+	group := utils.NewTaskGroup(ctx, f.concurrent, false)
+	for _, name := range names {
+		name := name
+		if err := group.Go(func(ctx context.Context) error {
+			result, err := f.resolve(ctx, pool, name)
+
+			var reason FilterReason
+			var wildcard *Wildcard
+			if result != nil {
+				result.Sources = append([]string{}, candidates[name]...)
+				sort.Strings(result.Sources)
+				if wildcard = cache.detect(ctx, f, pool, parentDomain(name)); wildcard != nil {
+					reason = wildcard.match(result)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				failed++
+				lastErr = err
+			case reason != "":
+				filtered = append(filtered, Filtered{Result: *result, Reason: reason, Wildcard: "*." + wildcard.Domain})
+			case result != nil:
+				results = append(results, *result)
+			}
+			return nil
+		}); err != nil {
+			break
+		}
 	}
+	group.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	sort.Slice(filtered, func(i, j int) bool {
+		return filtered[i].Result.Name < filtered[j].Result.Name
+	})
+
+	if err := ctx.Err(); err != nil {
+		return results, filtered, err
+	}
+	if failed > 0 && failed == len(names) {
+		return nil, filtered, fmt.Errorf("子域名查询全部失败: %v", lastErr)
+	}
+	return results, filtered, nil
+}
+
+// Filtered 返回最近一次 Find 因泛解析而过滤掉的子域名及原因，按名称排序
+func (f *Finder) Filtered() []Filtered {
+	return f.filtered
+}
+
+// Wildcards 返回最近一次 Find 检测到的泛解析记录，按域名排序
+func (f *Finder) Wildcards() []Wildcard {
+	return f.wildcards
+}
+
+// SourceErrors 返回最近一次 Find 中查询失败的数据源及错误
+func (f *Finder) SourceErrors() map[string]error {
+	return f.sourceErrors
+}
+
+// resolve 通过解析器池查询子域名，解析器出错时由解析器池换下一个重试。
+// 子域名不存在时返回nil
+func (f *Finder) resolve(ctx context.Context, pool *utils.ResolverPool, name string) (*Result, error) {
+	ips, cname, err := pool.Resolve(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return newResult(name, ips, cname), nil
+}
+
+// newResult 按地址族拆分解析结果
+func newResult(name string, ips []net.IP, cname string) *Result {
+	result := &Result{
+		Name:  name,
+		CNAME: cname,
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			result.A = append(result.A, ip.String())
+		} else {
+			result.AAAA = append(result.AAAA, ip.String())
+		}
+	}
+	sort.Strings(result.A)
+	sort.Strings(result.AAAA)
+	return result
+}
+
+// DefaultWordlist 返回内置子域名字典
+func DefaultWordlist() []string {
+	return parseWordlist(strings.NewReader(builtinWordlist))
 }
 
-func (f *Finder) Find(ctx context.Context) ([]string, error) {
-	var subdomains []string
+// LoadWordlist 读取子域名字典文件，每行一个前缀，忽略空行和 # 开头的注释
+func LoadWordlist(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取字典文件失败: %v", err)
+	}
+	defer file.Close()
+
+	words := parseWordlist(file)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("字典文件 %s 为空", path)
+	}
+	return words, nil
+}
 
-	// 这里实现实际的子域名发现逻辑
-	// 目前返回一个示例结果
-	subdomains = append(subdomains, "www."+f.domain)
-	subdomains = append(subdomains, "mail."+f.domain)
+// parseWordlist 解析字典，前缀转为小写并去重
+func parseWordlist(r io.Reader) []string {
+	var words []string
+	seen := make(map[string]bool)
 
-	return subdomains, nil
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		word := strings.ToLower(strings.Trim(strings.TrimSpace(scanner.Text()), "."))
+		if word == "" || strings.HasPrefix(word, "#") || seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+	return words
 }
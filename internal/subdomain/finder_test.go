@@ -2,62 +2,295 @@ package subdomain
 
 import (
 	"context"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/Marryname/WebScanner/internal/dnstest"
+	"golang.org/x/net/dns/dnsmessage"
 )
 
+// testRecord 测试DNS服务器上的记录
+type testRecord struct {
+	a     []string
+	aaaa  []string
+	cname string
+	ns    []string
+	// srv SRV记录的目标主机
+	srv []string
+	// nsec NSEC记录指向的下一个名称
+	nsec string
+	// transfer 区域顶点上允许AXFR区域传送
+	transfer bool
+}
+
+// serveDNS 启动测试DNS服务器，records的键为不带末尾点的域名，支持 *.example.com 形式的泛解析记录。
+// rcode不为成功时所有查询都返回该响应码
+func serveDNS(t *testing.T, records map[string]testRecord, rcode dnsmessage.RCode) string {
+	zone := &dnstest.Zone{RCode: rcode}
+	for name, rec := range records {
+		for _, ip := range rec.a {
+			zone.Records = append(zone.Records, dnstest.A(name, 300, ip))
+		}
+		for _, ip := range rec.aaaa {
+			zone.Records = append(zone.Records, dnstest.AAAA(name, 300, ip))
+		}
+		if rec.cname != "" {
+			zone.Records = append(zone.Records, dnstest.CNAME(name, 300, rec.cname))
+		}
+		for _, ns := range rec.ns {
+			zone.Records = append(zone.Records, dnstest.NS(name, 300, ns))
+		}
+		for _, target := range rec.srv {
+			zone.Records = append(zone.Records, dnstest.RR(name, 300,
+				&dnsmessage.SRVResource{Priority: 10, Weight: 10, Port: 389, Target: dnsmessage.MustNewName(target + ".")}))
+		}
+		if rec.nsec != "" {
+			zone.Records = append(zone.Records, dnstest.NSEC(name, 300, rec.nsec))
+		}
+		if rec.transfer {
+			zone.Transfer = append(zone.Transfer, name)
+		}
+	}
+	return dnstest.Serve(t, zone)
+}
+
+// nsecData 返回NSEC记录的RDATA
+func nsecData(next string) []byte {
+	return dnstest.NSEC("example.com", 0, next).Body.(*dnsmessage.UnknownResource).Data
+}
+
+// bruteforce 字典爆破得到的结果的来源
+var bruteforce = []string{SourceBruteforce}
+
 func TestFinder(t *testing.T) {
+	server := serveDNS(t, map[string]testRecord{
+		"www.example.com":     {a: []string{"192.0.2.1"}, aaaa: []string{"2001:db8::1"}},
+		"api.example.com":     {cname: "api.cdn.example.net"},
+		"api.cdn.example.net": {a: []string{"192.0.2.3", "192.0.2.2"}},
+		"mail.example.com":    {a: []string{"192.0.2.4"}},
+		"example.org":         {a: []string{"192.0.2.5"}},
+	}, dnsmessage.RCodeSuccess)
+	broken := serveDNS(t, nil, dnsmessage.RCodeServerFailure)
+
 	tests := []struct {
 		name    string
 		domain  string
+		want    []Result
 		wantErr bool
 	}{
 		{
-			name:    "测试有效域名",
-			domain:  "example.com",
-			wantErr: false,
+			name:   "测试有效域名",
+			domain: "Example.com.",
+			want: []Result{
+				{Name: "api.example.com", A: []string{"192.0.2.2", "192.0.2.3"}, CNAME: "api.cdn.example.net", Sources: bruteforce},
+				{Name: "mail.example.com", A: []string{"192.0.2.4"}, Sources: bruteforce},
+				{Name: "www.example.com", A: []string{"192.0.2.1"}, AAAA: []string{"2001:db8::1"}, Sources: bruteforce},
+			},
+		},
+		{
+			name:   "没有子域名",
+			domain: "example.org",
 		},
 		{
 			name:    "测试无效域名",
 			domain:  "invalid-domain",
-			wantErr: false, // 目前示例实现总是返回成功
+			wantErr: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			finder := NewFinder(tt.domain)
-			subdomains, err := finder.Find(context.Background())
+			finder.SetWordlist([]string{"www", "api", "mail", "missing"})
+			// 出错的解析器不影响结果，查询会换到下一个解析器
+			finder.SetResolvers([]string{broken, server})
+			finder.SetTimeout(time.Second)
 
+			got, err := finder.Find(context.Background())
 			if (err != nil) != tt.wantErr {
-				t.Errorf("Find() error = %v, wantErr %v", err, tt.wantErr)
-				return
+				t.Fatalf("Find() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find() = %+v, want %+v", got, tt.want)
 			}
+		})
+	}
+}
+
+func TestFinderWildcard(t *testing.T) {
+	server := serveDNS(t, map[string]testRecord{
+		"*.example.com":    {a: []string{"192.0.2.101", "192.0.2.100"}},
+		"www.example.com":  {a: []string{"192.0.2.1"}},
+		"mail.example.com": {a: []string{"192.0.2.100"}},
+		"*.example.org":    {cname: "lb.example.net"},
+		"lb.example.net":   {a: []string{"192.0.2.50"}},
+		"api.example.org":  {cname: "api.example.net"},
+		"api.example.net":  {a: []string{"192.0.2.50"}},
+	}, dnsmessage.RCodeSuccess)
 
-			// 验证结果
-			if len(subdomains) == 0 {
-				t.Log("没有发现子域名")
-			} else {
-				for _, subdomain := range subdomains {
-					t.Logf("发现子域名: %s", subdomain)
-				}
+	tests := []struct {
+		name          string
+		domain        string
+		want          []Result
+		wantFiltered  []Filtered
+		wantWildcards []Wildcard
+	}{
+		{
+			name:   "泛解析地址",
+			domain: "example.com",
+			want:   []Result{{Name: "www.example.com", A: []string{"192.0.2.1"}, Sources: bruteforce}},
+			wantFiltered: []Filtered{
+				{Result: Result{Name: "api.example.com", A: []string{"192.0.2.100", "192.0.2.101"}, Sources: bruteforce},
+					Reason: FilterWildcardIP, Wildcard: "*.example.com"},
+				{Result: Result{Name: "mail.example.com", A: []string{"192.0.2.100"}, Sources: bruteforce},
+					Reason: FilterWildcardIP, Wildcard: "*.example.com"},
+			},
+			wantWildcards: []Wildcard{{Domain: "example.com", A: []string{"192.0.2.100", "192.0.2.101"}}},
+		},
+		{
+			name:   "泛解析CNAME",
+			domain: "example.org",
+			// 地址相同但CNAME不同，说明存在独立的记录
+			want: []Result{{Name: "api.example.org", A: []string{"192.0.2.50"}, CNAME: "api.example.net", Sources: bruteforce}},
+			wantFiltered: []Filtered{
+				{Result: Result{Name: "mail.example.org", A: []string{"192.0.2.50"}, CNAME: "lb.example.net", Sources: bruteforce},
+					Reason: FilterWildcardCNAME, Wildcard: "*.example.org"},
+				{Result: Result{Name: "www.example.org", A: []string{"192.0.2.50"}, CNAME: "lb.example.net", Sources: bruteforce},
+					Reason: FilterWildcardCNAME, Wildcard: "*.example.org"},
+			},
+			wantWildcards: []Wildcard{{Domain: "example.org", A: []string{"192.0.2.50"}, CNAME: []string{"lb.example.net"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finder := NewFinder(tt.domain)
+			finder.SetWordlist([]string{"www", "api", "mail"})
+			finder.SetResolvers([]string{server})
+			finder.SetTimeout(time.Second)
+
+			got, err := finder.Find(context.Background())
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find() = %+v, want %+v", got, tt.want)
+			}
+			if got := finder.Filtered(); !reflect.DeepEqual(got, tt.wantFiltered) {
+				t.Errorf("Filtered() = %+v, want %+v", got, tt.wantFiltered)
+			}
+			if got := finder.Wildcards(); !reflect.DeepEqual(got, tt.wantWildcards) {
+				t.Errorf("Wildcards() = %+v, want %+v", got, tt.wantWildcards)
 			}
 		})
 	}
 }
 
+func TestFinderExpand(t *testing.T) {
+	server := serveDNS(t, map[string]testRecord{
+		"www.example.com":         {a: []string{"192.0.2.1"}},
+		"web01.example.com":       {a: []string{"192.0.2.2"}},
+		"dev.example.com":         {a: []string{"192.0.2.3"}},
+		"dev-www.example.com":     {a: []string{"192.0.2.4"}},
+		"web02.example.com":       {a: []string{"192.0.2.5"}},
+		"api.dev.example.com":     {a: []string{"192.0.2.6"}},
+		"www.api.dev.example.com": {a: []string{"192.0.2.7"}},
+	}, dnsmessage.RCodeSuccess)
+
+	found := []Result{
+		{Name: "dev.example.com", A: []string{"192.0.2.3"}, Sources: bruteforce},
+		{Name: "web01.example.com", A: []string{"192.0.2.2"}, Sources: bruteforce},
+		{Name: "www.example.com", A: []string{"192.0.2.1"}, Sources: bruteforce},
+	}
+	permutation := []string{SourcePermutation}
+	recursive := []string{SourceRecursive}
+
+	tests := []struct {
+		name    string
+		permute bool
+		depth   int
+		want    []Result
+	}{
+		{
+			name: "不扩展",
+			want: found,
+		},
+		{
+			name:    "排列",
+			permute: true,
+			// api 也是排列词，插入标签得到 api.dev.example.com
+			want: []Result{
+				{Name: "api.dev.example.com", A: []string{"192.0.2.6"}, Sources: permutation},
+				{Name: "dev-www.example.com", A: []string{"192.0.2.4"}, Sources: permutation},
+				found[0], found[1],
+				{Name: "web02.example.com", A: []string{"192.0.2.5"}, Sources: permutation},
+				found[2],
+			},
+		},
+		{
+			name:  "递归一级",
+			depth: 1,
+			want: []Result{
+				{Name: "api.dev.example.com", A: []string{"192.0.2.6"}, Sources: recursive},
+				found[0], found[1], found[2],
+			},
+		},
+		{
+			name:  "递归两级",
+			depth: 2,
+			want: []Result{
+				{Name: "api.dev.example.com", A: []string{"192.0.2.6"}, Sources: recursive},
+				found[0], found[1],
+				{Name: "www.api.dev.example.com", A: []string{"192.0.2.7"}, Sources: recursive},
+				found[2],
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finder := NewFinder("example.com")
+			finder.SetWordlist([]string{"www", "web01", "dev", "api"})
+			finder.SetResolvers([]string{server})
+			finder.SetTimeout(time.Second)
+			finder.SetPermutations(tt.permute)
+			finder.SetRecursion(tt.depth)
+
+			got, err := finder.Find(context.Background())
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinderResolverFailure(t *testing.T) {
+	broken := serveDNS(t, nil, dnsmessage.RCodeServerFailure)
+
+	finder := NewFinder("example.com")
+	finder.SetWordlist([]string{"www", "mail"})
+	finder.SetResolvers([]string{broken})
+	finder.SetTimeout(time.Second)
+
+	if _, err := finder.Find(context.Background()); err == nil {
+		t.Error("Find() 在解析器全部出错时应返回错误")
+	}
+}
+
 func TestFinderWithContext(t *testing.T) {
 	finder := NewFinder("example.com")
 
 	// 测试上下文取消
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
-	defer cancel()
-
-	subdomains, err := finder.Find(ctx)
-	if err != nil {
-		t.Logf("预期的上下文取消错误: %v", err)
-	} else {
-		t.Logf("发现 %d 个子域名", len(subdomains))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := finder.Find(ctx); err != context.Canceled {
+		t.Errorf("Find() error = %v, want %v", err, context.Canceled)
 	}
 }
 
@@ -74,13 +307,21 @@ func TestFinderWithInvalidInput(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			finder := NewFinder(tt.domain)
-			subdomains, err := finder.Find(context.Background())
-
-			// 目前的示例实现不会返回错误，所以只记录结果
-			if err != nil {
-				t.Logf("获取到预期的错误: %v", err)
+			if _, err := finder.Find(context.Background()); err == nil {
+				t.Errorf("Find(%q) 应返回错误", tt.domain)
 			}
-			t.Logf("发现 %d 个子域名", len(subdomains))
 		})
 	}
 }
+
+func TestParseWordlist(t *testing.T) {
+	got := parseWordlist(strings.NewReader("# 注释\nwww\n\n  API \nwww\n.dev.\n"))
+	want := []string{"www", "api", "dev"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseWordlist() = %v, want %v", got, want)
+	}
+
+	if words := DefaultWordlist(); len(words) < 100 {
+		t.Errorf("DefaultWordlist() 只有 %d 个前缀", len(words))
+	}
+}
@@ -0,0 +1,92 @@
+package subdomain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 单轮最多生成的排列候选数量，避免结果较多时查询量失控
+const maxPermutations = 20000
+
+// defaultPermutationWords 生成排列使用的常见环境和用途词
+var defaultPermutationWords = []string{
+	"dev", "test", "staging", "stage", "uat", "qa", "pre", "prod",
+	"beta", "demo", "new", "old", "internal", "admin", "api", "backup",
+}
+
+// permutations 按 altdns 的方式为已发现的子域名生成候选名称：
+// 第一个标签加词前后缀(dev-www、www-dev)、插入新标签(dev.www)、
+// 追加序号(www-01、www1)、递增递减标签中的数字(web01→web02)以及交换域名下相邻的标签。
+// 返回的名称已去重且不包含原名称，按名称排序
+func permutations(name, domain string, words []string) []string {
+	if !strings.HasSuffix(name, "."+domain) {
+		return nil
+	}
+	labels := strings.Split(strings.TrimSuffix(name, "."+domain), ".")
+	first, rest := labels[0], strings.TrimPrefix(name, labels[0])
+
+	set := make(map[string]bool)
+	add := func(label, rest string) {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return
+		}
+		set[label+rest] = true
+	}
+
+	for _, word := range words {
+		if word == first {
+			continue
+		}
+		add(word+"-"+first, rest)
+		add(first+"-"+word, rest)
+		add(word, "."+name)
+	}
+
+	if number := trailingNumber(first); number == "" {
+		for _, suffix := range []string{"-01", "-02", "1", "2"} {
+			add(first+suffix, rest)
+		}
+	} else {
+		prefix := strings.TrimSuffix(first, number)
+		n, _ := strconv.Atoi(number)
+		for _, next := range []int{n - 1, n + 1, n + 2} {
+			if next >= 0 {
+				add(prefix+fmt.Sprintf("%0*d", len(number), next), rest)
+			}
+		}
+	}
+
+	// 交换相邻标签，如 api.dev.example.com → dev.api.example.com
+	for i := 0; i+1 < len(labels); i++ {
+		swapped := append([]string{}, labels...)
+		swapped[i], swapped[i+1] = swapped[i+1], swapped[i]
+		set[strings.Join(swapped, ".")+"."+domain] = true
+	}
+
+	delete(set, name)
+	result := make([]string, 0, len(set))
+	for candidate := range set {
+		result = append(result, candidate)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// trailingNumber 返回标签末尾的数字部分
+func trailingNumber(label string) string {
+	i := len(label)
+	for i > 0 && label[i-1] >= '0' && label[i-1] <= '9' {
+		i--
+	}
+	return label[i:]
+}
+
+// subdomainDepth 返回名称在域名下的层级，www.example.com 为1
+func subdomainDepth(name, domain string) int {
+	if !strings.HasSuffix(name, "."+domain) {
+		return 0
+	}
+	return strings.Count(strings.TrimSuffix(name, "."+domain), ".") + 1
+}
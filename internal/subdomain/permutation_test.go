@@ -0,0 +1,76 @@
+package subdomain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPermutations(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		domain string
+		words  []string
+		want   []string
+	}{
+		{
+			name:   "前后缀和序号",
+			input:  "www.example.com",
+			domain: "example.com",
+			words:  []string{"dev"},
+			want: []string{
+				"dev-www.example.com", "dev.www.example.com", "www-01.example.com", "www-02.example.com",
+				"www-dev.example.com", "www1.example.com", "www2.example.com",
+			},
+		},
+		{
+			name:   "数字递增递减",
+			input:  "web09.example.com",
+			domain: "example.com",
+			want:   []string{"web08.example.com", "web10.example.com", "web11.example.com"},
+		},
+		{
+			name:   "交换标签",
+			input:  "api.dev.example.com",
+			domain: "example.com",
+			words:  []string{"dev"},
+			want: []string{
+				"api-01.dev.example.com", "api-02.dev.example.com", "api-dev.dev.example.com",
+				"api1.dev.example.com", "api2.dev.example.com", "dev-api.dev.example.com",
+				"dev.api.dev.example.com", "dev.api.example.com",
+			},
+		},
+		{
+			name:   "不属于域名",
+			input:  "www.example.org",
+			domain: "example.com",
+			words:  []string{"dev"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := permutations(tt.input, tt.domain, tt.words); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("permutations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubdomainDepth(t *testing.T) {
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"www.example.com", 1},
+		{"api.dev.example.com", 2},
+		{"example.com", 0},
+		{"www.example.org", 0},
+	}
+
+	for _, tt := range tests {
+		if got := subdomainDepth(tt.name, "example.com"); got != tt.want {
+			t.Errorf("subdomainDepth(%s) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package subdomain
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 数据源名称，记录在结果的 Sources 中
+const (
+	SourceBruteforce     = "bruteforce"
+	SourcePermutation    = "permutation"
+	SourceRecursive      = "recursive"
+	SourceAXFR           = "axfr"
+	SourceSRV            = "srv"
+	SourceNSEC           = "nsec"
+	SourceCertificate    = "certificate"
+	SourceCrtSh          = "crtsh"
+	SourceOTX            = "otx"
+	SourceSecurityTrails = "securitytrails"
+	SourceWayback        = "wayback"
+)
+
+// PassiveSources 支持的被动数据源
+var PassiveSources = []string{SourceCrtSh, SourceOTX, SourceSecurityTrails, SourceWayback}
+
+// 各被动数据源的默认接口地址
+var defaultBaseURLs = map[string]string{
+	SourceCrtSh:          "https://crt.sh",
+	SourceOTX:            "https://otx.alienvault.com",
+	SourceSecurityTrails: "https://api.securitytrails.com",
+	SourceWayback:        "https://web.archive.org",
+}
+
+// Source 子域名数据源
+type Source interface {
+	// Name 数据源名称
+	Name() string
+	// Enumerate 查询域名的子域名，结果通过通道逐个返回，查询结束或上下文取消后关闭通道。
+	// 请求失败时返回错误；响应解析出错时同时返回已得到结果的通道和错误
+	Enumerate(ctx context.Context, domain string) (<-chan Result, error)
+}
+
+// SourceConfig 被动数据源的配置
+type SourceConfig struct {
+	// BaseURL 接口地址，为空时使用官方地址
+	BaseURL string
+	APIKey  string
+	Timeout time.Duration
+}
+
+// NewSource 按名称创建被动数据源，需要API密钥的数据源未配置密钥时返回错误
+func NewSource(name string, config SourceConfig) (Source, error) {
+	if config.BaseURL == "" {
+		config.BaseURL = defaultBaseURLs[name]
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	source := &httpSource{
+		name:   name,
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+	switch name {
+	case SourceCrtSh:
+		source.request, source.parse = crtShRequest, parseCrtSh
+	case SourceOTX:
+		source.request, source.parse = otxRequest, parseOTX
+	case SourceSecurityTrails:
+		if config.APIKey == "" {
+			return nil, fmt.Errorf("数据源 %s 需要配置API密钥", name)
+		}
+		source.request, source.parse = securityTrailsRequest, parseSecurityTrails
+	case SourceWayback:
+		source.request, source.parse = waybackRequest, parseWayback
+	default:
+		return nil, fmt.Errorf("未知的数据源: %s", name)
+	}
+	return source, nil
+}
+
+// httpSource 通过HTTP接口查询的被动数据源
+type httpSource struct {
+	name    string
+	config  SourceConfig
+	client  *http.Client
+	request func(config SourceConfig, domain string) (string, http.Header)
+	// parse 解析响应并对每个名称调用emit，emit返回false时停止解析
+	parse func(r io.Reader, domain string, emit func(name string) bool) error
+}
+
+// Name 数据源名称
+func (s *httpSource) Name() string {
+	return s.name
+}
+
+// Enumerate 请求接口并解析响应，只返回属于该域名的子域名，名称已去重。
+// 响应解析出错时返回错误，出错前解析到的结果仍通过通道返回
+func (s *httpSource) Enumerate(ctx context.Context, domain string) (<-chan Result, error) {
+	rawURL, header := s.request(s.config, domain)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s 请求失败: %v", s.name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s 返回状态码 %d", s.name, resp.StatusCode)
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	err = s.parse(resp.Body, domain, func(raw string) bool {
+		if name, ok := normalizeName(raw, domain); ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		return ctx.Err() == nil
+	})
+
+	results := make(chan Result, len(names))
+	for _, name := range names {
+		results <- Result{Name: name, Sources: []string{s.name}}
+	}
+	close(results)
+	if err != nil {
+		return results, fmt.Errorf("%s 响应解析失败: %v", s.name, err)
+	}
+	return results, nil
+}
+
+// normalizeName 规范化数据源返回的名称，去掉通配符前缀和末尾的点，
+// 名称不是该域名的子域名时ok为false
+func normalizeName(name, domain string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "*."), ".")
+	if !strings.HasSuffix(name, "."+domain) {
+		return "", false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.' || r == '_') {
+			return "", false
+		}
+	}
+	return name, !strings.Contains(name, "..")
+}
+
+// crtShRequest 查询证书透明度日志中包含该域名的证书
+func crtShRequest(config SourceConfig, domain string) (string, http.Header) {
+	query := url.Values{"q": {"%." + domain}, "output": {"json"}}
+	return config.BaseURL + "/?" + query.Encode(), nil
+}
+
+// parseCrtSh 解析crt.sh的JSON结果，name_value中可能包含多个以换行分隔的名称
+func parseCrtSh(r io.Reader, domain string, emit func(name string) bool) error {
+	var entries []struct {
+		CommonName string `json:"common_name"`
+		NameValue  string `json:"name_value"`
+	}
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		for _, name := range append(strings.Split(entry.NameValue, "\n"), entry.CommonName) {
+			if !emit(name) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// otxRequest 查询AlienVault OTX的被动DNS记录，API密钥可选
+func otxRequest(config SourceConfig, domain string) (string, http.Header) {
+	header := http.Header{}
+	if config.APIKey != "" {
+		header.Set("X-OTX-API-KEY", config.APIKey)
+	}
+	return config.BaseURL + "/api/v1/indicators/domain/" + url.PathEscape(domain) + "/passive_dns", header
+}
+
+func parseOTX(r io.Reader, domain string, emit func(name string) bool) error {
+	var result struct {
+		PassiveDNS []struct {
+			Hostname string `json:"hostname"`
+		} `json:"passive_dns"`
+	}
+	if err := json.NewDecoder(r).Decode(&result); err != nil {
+		return err
+	}
+
+	for _, record := range result.PassiveDNS {
+		if !emit(record.Hostname) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// securityTrailsRequest 查询SecurityTrails的子域名列表
+func securityTrailsRequest(config SourceConfig, domain string) (string, http.Header) {
+	header := http.Header{}
+	header.Set("APIKEY", config.APIKey)
+	return config.BaseURL + "/v1/domain/" + url.PathEscape(domain) + "/subdomains", header
+}
+
+// parseSecurityTrails 解析SecurityTrails的结果，返回的是不含域名的前缀
+func parseSecurityTrails(r io.Reader, domain string, emit func(name string) bool) error {
+	var result struct {
+		Subdomains []string `json:"subdomains"`
+	}
+	if err := json.NewDecoder(r).Decode(&result); err != nil {
+		return err
+	}
+
+	for _, prefix := range result.Subdomains {
+		if !emit(prefix + "." + domain) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// waybackRequest 查询Wayback Machine存档过的该域名下的全部URL
+func waybackRequest(config SourceConfig, domain string) (string, http.Header) {
+	query := url.Values{
+		"url":      {"*." + domain + "/*"},
+		"output":   {"txt"},
+		"fl":       {"original"},
+		"collapse": {"urlkey"},
+	}
+	return config.BaseURL + "/cdx/search/cdx?" + query.Encode(), nil
+}
+
+// parseWayback 逐行解析存档的URL并提取主机名
+func parseWayback(r io.Reader, domain string, emit func(name string) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.Contains(line, "://") {
+			line = "http://" + line
+		}
+		u, err := url.Parse(line)
+		if err != nil {
+			continue
+		}
+		if !emit(u.Hostname()) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
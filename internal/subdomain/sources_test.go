@@ -0,0 +1,222 @@
+package subdomain
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// servePassive 启动模拟各被动数据源接口的测试服务器，需要密钥的接口校验密钥
+func servePassive(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") != "%.example.com" || r.URL.Query().Get("output") != "json" {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"common_name": "www.example.com", "name_value": "www.example.com\n*.dev.example.com"},
+			{"common_name": "WWW.example.com.", "name_value": "example.com\nevil.com\nvpn.example.com"}
+		]`)
+	})
+	mux.HandleFunc("/api/v1/indicators/domain/example.com/passive_dns", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"passive_dns": [
+			{"hostname": "mail.example.com"},
+			{"hostname": "www.example.com"},
+			{"hostname": "example.com.cdn.net"}
+		]}`)
+	})
+	mux.HandleFunc("/v1/domain/example.com/subdomains", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("APIKEY") != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"subdomains": ["api", "mail"]}`)
+	})
+	mux.HandleFunc("/cdx/search/cdx", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("url") != "*.example.com/*" {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, "http://blog.example.com/index.html\nhttps://blog.example.com:8443/a?b=c\nstatic.example.com/x.js\n\nhttp://other.org/\n")
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// collect 读取数据源返回的全部名称并排序
+func collect(t *testing.T, source Source, domain string) ([]string, error) {
+	results, err := source.Enumerate(context.Background(), domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for result := range results {
+		if !reflect.DeepEqual(result.Sources, []string{source.Name()}) {
+			t.Errorf("Sources = %v, want [%s]", result.Sources, source.Name())
+		}
+		names = append(names, result.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func TestSources(t *testing.T) {
+	server := servePassive(t)
+
+	tests := []struct {
+		name    string
+		source  string
+		apiKey  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "证书透明度",
+			source: SourceCrtSh,
+			want:   []string{"dev.example.com", "vpn.example.com", "www.example.com"},
+		},
+		{
+			name:   "被动DNS",
+			source: SourceOTX,
+			want:   []string{"mail.example.com", "www.example.com"},
+		},
+		{
+			name:   "SecurityTrails",
+			source: SourceSecurityTrails,
+			apiKey: "secret",
+			want:   []string{"api.example.com", "mail.example.com"},
+		},
+		{
+			name:    "密钥错误",
+			source:  SourceSecurityTrails,
+			apiKey:  "wrong",
+			wantErr: true,
+		},
+		{
+			name:   "网页存档",
+			source: SourceWayback,
+			want:   []string{"blog.example.com", "static.example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewSource(tt.source, SourceConfig{BaseURL: server.URL + "/", APIKey: tt.apiKey})
+			if err != nil {
+				t.Fatalf("NewSource() error = %v", err)
+			}
+			if source.Name() != tt.source {
+				t.Errorf("Name() = %s, want %s", source.Name(), tt.source)
+			}
+
+			got, err := collect(t, source, "example.com")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Enumerate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Enumerate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSource(t *testing.T) {
+	if _, err := NewSource("unknown", SourceConfig{}); err == nil {
+		t.Error("NewSource() 对未知数据源应返回错误")
+	}
+	if _, err := NewSource(SourceSecurityTrails, SourceConfig{}); err == nil {
+		t.Error("NewSource() 在缺少API密钥时应返回错误")
+	}
+	for _, name := range PassiveSources {
+		if name == SourceSecurityTrails {
+			continue
+		}
+		if _, err := NewSource(name, SourceConfig{}); err != nil {
+			t.Errorf("NewSource(%s) error = %v", name, err)
+		}
+	}
+}
+
+func TestFinderSources(t *testing.T) {
+	passive := servePassive(t)
+	server := serveDNS(t, map[string]testRecord{
+		"www.example.com":  {a: []string{"192.0.2.1"}},
+		"mail.example.com": {a: []string{"192.0.2.2"}},
+		"api.example.com":  {a: []string{"192.0.2.3"}},
+		"vpn.example.com":  {a: []string{"192.0.2.4"}},
+	}, dnsmessage.RCodeSuccess)
+
+	var sources []Source
+	for _, config := range []struct {
+		name   string
+		apiKey string
+	}{
+		{SourceCrtSh, ""},
+		{SourceOTX, ""},
+		{SourceSecurityTrails, "secret"},
+		// 密钥错误的数据源不影响其他数据源
+		{SourceSecurityTrails, "wrong"},
+	} {
+		source, err := NewSource(config.name, SourceConfig{BaseURL: passive.URL, APIKey: config.apiKey, Timeout: time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, source)
+	}
+	// 响应中途出错的数据源记录错误，出错前解析到的名称仍然保留
+	truncated := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "http://www.example.com/\nhttp://%s.example.com/\n", strings.Repeat("a", 2*1024*1024))
+	}))
+	t.Cleanup(truncated.Close)
+	wayback, err := NewSource(SourceWayback, SourceConfig{BaseURL: truncated.URL, Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources = append(sources, wayback)
+
+	finder := NewFinder("example.com")
+	finder.SetWordlist([]string{"www"})
+	finder.SetSources(sources)
+	finder.SetResolvers([]string{server})
+	finder.SetTimeout(time.Second)
+
+	got, err := finder.Find(context.Background())
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	// dev.example.com 无法解析，不在结果中
+	want := []Result{
+		{Name: "api.example.com", A: []string{"192.0.2.3"}, Sources: []string{SourceSecurityTrails}},
+		{Name: "mail.example.com", A: []string{"192.0.2.2"}, Sources: []string{SourceOTX, SourceSecurityTrails}},
+		{Name: "vpn.example.com", A: []string{"192.0.2.4"}, Sources: []string{SourceCrtSh}},
+		{Name: "www.example.com", A: []string{"192.0.2.1"}, Sources: []string{SourceBruteforce, SourceCrtSh, SourceOTX, SourceWayback}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Find() = %+v, want %+v", got, want)
+	}
+	if errs := finder.SourceErrors(); len(errs) != 2 || errs[SourceSecurityTrails] == nil || errs[SourceWayback] == nil {
+		t.Errorf("SourceErrors() = %v, want securitytrails 和 wayback 错误", errs)
+	}
+}
+
+func TestResultMerge(t *testing.T) {
+	result := Result{Name: "www.example.com", Sources: []string{SourceCrtSh}}
+	result.Merge(Result{Name: "www.example.com", A: []string{"192.0.2.1"}, Sources: []string{SourceBruteforce, SourceCrtSh}})
+
+	want := Result{Name: "www.example.com", A: []string{"192.0.2.1"}, Sources: []string{SourceBruteforce, SourceCrtSh}}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("Merge() = %+v, want %+v", result, want)
+	}
+}
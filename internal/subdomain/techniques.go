@@ -0,0 +1,253 @@
+package subdomain
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/Marryname/WebScanner/pkg/utils"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNSTechniques 支持的DNS协议发现技术
+var DNSTechniques = []string{SourceAXFR, SourceSRV, SourceNSEC}
+
+// NSEC遍历最多查询的记录数量
+const maxNSECWalk = 10000
+
+// commonSRV 常见的SRV服务名称
+var commonSRV = []string{
+	"_ldap._tcp", "_ldaps._tcp", "_gc._tcp", "_kerberos._tcp", "_kerberos._udp",
+	"_kpasswd._tcp", "_kpasswd._udp", "_kerberos-master._tcp", "_ldap._tcp.dc._msdcs",
+	"_sip._tcp", "_sip._udp", "_sip._tls", "_sips._tcp", "_sipfederationtls._tcp",
+	"_xmpp-client._tcp", "_xmpp-server._tcp", "_jabber._tcp",
+	"_autodiscover._tcp", "_caldav._tcp", "_caldavs._tcp", "_carddav._tcp", "_carddavs._tcp",
+	"_imap._tcp", "_imaps._tcp", "_pop3._tcp", "_pop3s._tcp", "_submission._tcp", "_smtp._tcp",
+	"_http._tcp", "_https._tcp", "_ftp._tcp", "_ssh._tcp", "_matrix._tcp",
+	"_mysql._tcp", "_postgresql._tcp", "_mongodb._tcp", "_vlmcs._tcp", "_h323cs._tcp",
+}
+
+// dnsSource 利用DNS协议本身发现子域名的技术，实现 Source 接口，
+// 使用 Finder 的解析器池、超时和速率限制
+type dnsSource struct {
+	name   string
+	finder *Finder
+	pool   *utils.ResolverPool
+	client *utils.DNSClient
+}
+
+// Name 技术名称
+func (s *dnsSource) Name() string {
+	return s.name
+}
+
+// Enumerate 执行发现技术，得到的名称全部通过通道返回
+func (s *dnsSource) Enumerate(ctx context.Context, domain string) (<-chan Result, error) {
+	var (
+		names []string
+		err   error
+	)
+	switch s.name {
+	case SourceAXFR:
+		names, err = s.transfer(ctx, domain)
+	case SourceSRV:
+		names, err = s.srv(ctx, domain)
+	case SourceNSEC:
+		names, err = s.walk(ctx, domain)
+	default:
+		err = fmt.Errorf("未知的发现技术: %s", s.name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(chan Result, len(names))
+	for _, name := range names {
+		results <- Result{Name: name, Sources: []string{s.name}}
+	}
+	close(results)
+	return results, nil
+}
+
+// transfer 依次向每个权威服务器请求区域传送，返回区域内全部记录的名称。
+// 所有服务器都拒绝时返回错误
+func (s *dnsSource) transfer(ctx context.Context, domain string) ([]string, error) {
+	servers, err := s.nameservers(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, server := range servers {
+		if err := s.finder.limiter.Wait(ctx, server); err != nil {
+			return nil, err
+		}
+		records, err := s.client.Transfer(ctx, server, domain)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %v", server, err)
+			continue
+		}
+
+		var names []string
+		for _, record := range records {
+			if name, ok := normalizeName(record.Header.Name.String(), domain); ok {
+				names = appendUnique(names, name)
+			}
+		}
+		return names, nil
+	}
+	return nil, fmt.Errorf("区域传送失败: %v", lastErr)
+}
+
+// srv 查询常见的SRV记录，返回属于该域名的目标主机
+func (s *dnsSource) srv(ctx context.Context, domain string) ([]string, error) {
+	var (
+		mu    sync.Mutex
+		names []string
+	)
+
+	group := utils.NewTaskGroup(ctx, s.finder.concurrent, false)
+	for _, service := range commonSRV {
+		service := service
+		if err := group.Go(func(ctx context.Context) error {
+			records, err := s.pool.LookupSRV(ctx, service+"."+domain)
+			if err != nil {
+				return nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, record := range records {
+				if name, ok := normalizeName(record.Target, domain); ok {
+					names = appendUnique(names, name)
+				}
+			}
+			return nil
+		}); err != nil {
+			break
+		}
+	}
+	group.Wait()
+	return names, ctx.Err()
+}
+
+// walk 沿NSEC记录的下一个名称遍历启用了DNSSEC的区域，直到回到区域顶点。权威服务器出错或没有返回
+// NSEC记录时依次换下一个服务器继续遍历。所有服务器在第一步都失败时返回错误，遍历中途失败时返回已得到的名称
+func (s *dnsSource) walk(ctx context.Context, domain string) ([]string, error) {
+	servers, err := s.nameservers(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		names   []string
+		lastErr error
+	)
+	seen := map[string]bool{domain: true}
+	current := domain
+	for i := 0; i < maxNSECWalk; i++ {
+		var next string
+		for len(servers) > 0 {
+			if next, err = s.nsecStep(ctx, servers[0], current, domain); err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				return names, ctx.Err()
+			}
+			lastErr = fmt.Errorf("%s: %v", servers[0], err)
+			servers = servers[1:]
+		}
+		if len(servers) == 0 {
+			if i == 0 {
+				return nil, fmt.Errorf("NSEC遍历失败: %v", lastErr)
+			}
+			break
+		}
+
+		if seen[next] || !strings.HasSuffix(next, "."+domain) {
+			break
+		}
+		seen[next] = true
+		if name, ok := normalizeName(next, domain); ok {
+			names = append(names, name)
+		}
+		current = next
+	}
+	return names, nil
+}
+
+// nsecStep 向权威服务器查询名称的NSEC记录，返回链上的下一个名称
+func (s *dnsSource) nsecStep(ctx context.Context, server, name, domain string) (string, error) {
+	if err := s.finder.limiter.Wait(ctx, server); err != nil {
+		return "", err
+	}
+	resp, err := s.client.Query(ctx, server, name, utils.TypeNSEC)
+	if err != nil {
+		return "", err
+	}
+	next, ok := nsecNext(resp, name)
+	if !ok {
+		return "", fmt.Errorf("%s 未启用DNSSEC或使用了NSEC3", domain)
+	}
+	return next, nil
+}
+
+// nameservers 通过解析器池查询域名的权威服务器并解析出地址，返回 ip:port 列表
+func (s *dnsSource) nameservers(ctx context.Context, domain string) ([]string, error) {
+	hosts, err := s.pool.LookupNS(ctx, domain)
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("未找到 %s 的权威服务器: %v", domain, err)
+	}
+
+	var servers []string
+	for _, host := range hosts {
+		result, err := s.finder.resolve(ctx, s.pool, host)
+		if err != nil || result == nil {
+			continue
+		}
+		for _, ip := range append(result.A, result.AAAA...) {
+			servers = append(servers, net.JoinHostPort(ip, s.finder.nsPort))
+		}
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("无法解析 %s 的权威服务器地址", domain)
+	}
+	return servers, nil
+}
+
+// nsecNext 返回响应中属于name的NSEC记录指向的下一个名称，已转为小写并去掉末尾的点
+func nsecNext(resp *dnsmessage.Message, name string) (string, bool) {
+	for _, record := range resp.Answers {
+		body, ok := record.Body.(*dnsmessage.UnknownResource)
+		if !ok || body.Type != utils.TypeNSEC {
+			continue
+		}
+		owner := strings.ToLower(strings.TrimSuffix(record.Header.Name.String(), "."))
+		if owner != name {
+			continue
+		}
+		if next, ok := parseWireName(body.Data); ok {
+			return next, true
+		}
+	}
+	return "", false
+}
+
+// parseWireName 解析RDATA开头未压缩的线路格式域名
+func parseWireName(data []byte) (string, bool) {
+	var labels []string
+	for off := 0; off < len(data); {
+		length := int(data[off])
+		if length == 0 {
+			return strings.ToLower(strings.Join(labels, ".")), len(labels) > 0
+		}
+		if length > 63 || off+1+length > len(data) {
+			return "", false
+		}
+		labels = append(labels, string(data[off+1:off+1+length]))
+		off += 1 + length
+	}
+	return "", false
+}
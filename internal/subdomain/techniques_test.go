@@ -0,0 +1,153 @@
+package subdomain
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// zoneRecords 启用了DNSSEC的测试区域，NSEC链为 example.com → ftp → ns1 → vpn → example.com
+func zoneRecords(transfer bool, signed bool) map[string]testRecord {
+	records := map[string]testRecord{
+		"example.com":            {ns: []string{"ns1.example.com"}, transfer: transfer, nsec: "ftp.example.com"},
+		"ns1.example.com":        {a: []string{"127.0.0.1"}, nsec: "vpn.example.com"},
+		"ftp.example.com":        {a: []string{"192.0.2.10"}, nsec: "ns1.example.com"},
+		"vpn.example.com":        {a: []string{"192.0.2.11"}, nsec: "example.com"},
+		"_ldap._tcp.example.com": {srv: []string{"dc1.example.com"}},
+		"dc1.example.com":        {a: []string{"192.0.2.12"}},
+	}
+	if !signed {
+		for name, rec := range records {
+			rec.nsec = ""
+			records[name] = rec
+		}
+	}
+	return records
+}
+
+func TestFinderTechniques(t *testing.T) {
+	open := serveDNS(t, zoneRecords(true, true), dnsmessage.RCodeSuccess)
+	closed := serveDNS(t, zoneRecords(false, false), dnsmessage.RCodeSuccess)
+	// 第一个权威服务器 ns0.example.com 解析到没有监听的 127.0.0.2
+	records := zoneRecords(false, true)
+	records["example.com"] = testRecord{ns: []string{"ns0.example.com", "ns1.example.com"}, nsec: "ftp.example.com"}
+	records["ns0.example.com"] = testRecord{a: []string{"127.0.0.2"}}
+	fallback := serveDNS(t, records, dnsmessage.RCodeSuccess)
+
+	ns1 := func(source string) Result {
+		return Result{Name: "ns1.example.com", A: []string{"127.0.0.1"}, Sources: []string{source}}
+	}
+	ftp := func(source string) Result {
+		return Result{Name: "ftp.example.com", A: []string{"192.0.2.10"}, Sources: []string{source}}
+	}
+	vpn := func(source string) Result {
+		return Result{Name: "vpn.example.com", A: []string{"192.0.2.11"}, Sources: []string{source}}
+	}
+	dc1 := func(source string) Result {
+		return Result{Name: "dc1.example.com", A: []string{"192.0.2.12"}, Sources: []string{source}}
+	}
+
+	tests := []struct {
+		name       string
+		server     string
+		technique  string
+		want       []Result
+		wantSource bool
+	}{
+		{
+			name:      "区域传送",
+			server:    open,
+			technique: SourceAXFR,
+			// _ldap._tcp.example.com 没有地址，解析后被丢弃
+			want: []Result{dc1(SourceAXFR), ftp(SourceAXFR), ns1(SourceAXFR), vpn(SourceAXFR)},
+		},
+		{
+			name:       "拒绝区域传送",
+			server:     closed,
+			technique:  SourceAXFR,
+			wantSource: true,
+		},
+		{
+			name:      "SRV记录",
+			server:    open,
+			technique: SourceSRV,
+			want:      []Result{dc1(SourceSRV)},
+		},
+		{
+			name:      "NSEC遍历",
+			server:    open,
+			technique: SourceNSEC,
+			want:      []Result{ftp(SourceNSEC), ns1(SourceNSEC), vpn(SourceNSEC)},
+		},
+		{
+			name:      "NSEC遍历换用下一个权威服务器",
+			server:    fallback,
+			technique: SourceNSEC,
+			want:      []Result{ftp(SourceNSEC), ns1(SourceNSEC), vpn(SourceNSEC)},
+		},
+		{
+			name:       "未签名区域",
+			server:     closed,
+			technique:  SourceNSEC,
+			wantSource: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finder := NewFinder("example.com")
+			finder.SetWordlist([]string{"missing"})
+			finder.SetResolvers([]string{tt.server})
+			finder.SetTimeout(time.Second)
+			if err := finder.SetTechniques([]string{tt.technique}); err != nil {
+				t.Fatal(err)
+			}
+			// 权威服务器 ns1.example.com 解析到 127.0.0.1，端口使用测试服务器的端口
+			_, finder.nsPort, _ = net.SplitHostPort(tt.server)
+
+			got, err := finder.Find(context.Background())
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find() = %+v, want %+v", got, tt.want)
+			}
+			if _, ok := finder.SourceErrors()[tt.technique]; ok != tt.wantSource {
+				t.Errorf("SourceErrors() = %v, want error %v", finder.SourceErrors(), tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestSetTechniques(t *testing.T) {
+	finder := NewFinder("example.com")
+	if err := finder.SetTechniques(DNSTechniques); err != nil {
+		t.Errorf("SetTechniques() error = %v", err)
+	}
+	if err := finder.SetTechniques([]string{"unknown"}); err == nil {
+		t.Error("SetTechniques() 对未知技术应返回错误")
+	}
+}
+
+func TestParseWireName(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want string
+		ok   bool
+	}{
+		{nsecData("WWW.example.com"), "www.example.com", true},
+		{[]byte{3, 'w', 'w'}, "", false},
+		{[]byte{0}, "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseWireName(tt.data)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseWireName(%v) = %q, %v, want %q, %v", tt.data, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package subdomain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Marryname/WebScanner/pkg/utils"
+)
+
+// 检测泛解析时每一级查询的随机名称数量
+const wildcardProbes = 3
+
+// FilterReason 子域名被过滤的原因
+type FilterReason string
+
+const (
+	// FilterWildcardIP 没有CNAME且地址全部属于泛解析地址
+	FilterWildcardIP FilterReason = "wildcard-ip"
+	// FilterWildcardCNAME CNAME与泛解析的CNAME相同
+	FilterWildcardCNAME FilterReason = "wildcard-cname"
+)
+
+// Wildcard 父域名的泛解析记录，为多个随机名称解析结果的并集
+type Wildcard struct {
+	// Domain 配置了泛解析的父域名，即 *.Domain 能够解析
+	Domain string   `json:"domain"`
+	A      []string `json:"a,omitempty"`
+	AAAA   []string `json:"aaaa,omitempty"`
+	CNAME  []string `json:"cname,omitempty"`
+}
+
+// Filtered 因匹配泛解析而被过滤的子域名
+type Filtered struct {
+	Result   Result       `json:"result"`
+	Reason   FilterReason `json:"reason"`
+	Wildcard string       `json:"wildcard"`
+}
+
+// match 判断解析结果是否只是泛解析的应答，是则返回过滤原因。
+// 有CNAME的结果只比较CNAME，CNAME不同说明存在独立的记录
+func (w *Wildcard) match(result *Result) FilterReason {
+	if result.CNAME != "" {
+		if contains(w.CNAME, result.CNAME) {
+			return FilterWildcardCNAME
+		}
+		return ""
+	}
+
+	for _, ip := range result.A {
+		if !contains(w.A, ip) {
+			return ""
+		}
+	}
+	for _, ip := range result.AAAA {
+		if !contains(w.AAAA, ip) {
+			return ""
+		}
+	}
+	return FilterWildcardIP
+}
+
+// wildcardEntry 单个父域名的检测结果，每个父域名只检测一次
+type wildcardEntry struct {
+	once     sync.Once
+	wildcard *Wildcard
+}
+
+// wildcardCache 按父域名缓存的泛解析检测结果
+type wildcardCache struct {
+	mu      sync.Mutex
+	entries map[string]*wildcardEntry
+}
+
+func newWildcardCache() *wildcardCache {
+	return &wildcardCache{
+		entries: make(map[string]*wildcardEntry),
+	}
+}
+
+// detect 返回父域名的泛解析记录，没有泛解析时返回nil
+func (c *wildcardCache) detect(ctx context.Context, f *Finder, pool *utils.ResolverPool, parent string) *Wildcard {
+	c.mu.Lock()
+	entry, ok := c.entries[parent]
+	if !ok {
+		entry = &wildcardEntry{}
+		c.entries[parent] = entry
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.wildcard = f.detectWildcard(ctx, pool, parent)
+	})
+	return entry.wildcard
+}
+
+// wildcards 返回检测到的全部泛解析记录，按域名排序
+func (c *wildcardCache) wildcards() []Wildcard {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var wildcards []Wildcard
+	for _, entry := range c.entries {
+		if entry.wildcard != nil {
+			wildcards = append(wildcards, *entry.wildcard)
+		}
+	}
+	sort.Slice(wildcards, func(i, j int) bool {
+		return wildcards[i].Domain < wildcards[j].Domain
+	})
+	return wildcards
+}
+
+// detectWildcard 解析父域名下的多个随机名称，任一名称能解析即认为存在泛解析，
+// 记录全部随机名称解析到的地址和CNAME
+func (f *Finder) detectWildcard(ctx context.Context, pool *utils.ResolverPool, parent string) *Wildcard {
+	var wildcard *Wildcard
+	for i := 0; i < wildcardProbes; i++ {
+		result, err := f.resolve(ctx, pool, randomLabel()+"."+parent)
+		if err != nil || result == nil {
+			continue
+		}
+
+		if wildcard == nil {
+			wildcard = &Wildcard{Domain: parent}
+		}
+		wildcard.A = appendUnique(wildcard.A, result.A...)
+		wildcard.AAAA = appendUnique(wildcard.AAAA, result.AAAA...)
+		if result.CNAME != "" {
+			wildcard.CNAME = appendUnique(wildcard.CNAME, result.CNAME)
+		}
+	}
+
+	if wildcard != nil {
+		sort.Strings(wildcard.A)
+		sort.Strings(wildcard.AAAA)
+		sort.Strings(wildcard.CNAME)
+	}
+	return wildcard
+}
+
+// randomLabel 生成几乎不可能真实存在的随机标签
+func randomLabel() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "wc-" + hex.EncodeToString(b)
+}
+
+// parentDomain 返回去掉第一个标签后的父域名
+func parentDomain(name string) string {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		return name[i+1:]
+	}
+	return ""
+}
+
+// contains 判断列表中是否包含指定值，不区分大小写
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendUnique 追加列表中尚不存在的值
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		if !contains(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
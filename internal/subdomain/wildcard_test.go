@@ -0,0 +1,45 @@
+package subdomain
+
+import "testing"
+
+func TestWildcardMatch(t *testing.T) {
+	wildcard := &Wildcard{
+		Domain: "example.com",
+		A:      []string{"192.0.2.1", "192.0.2.2"},
+		AAAA:   []string{"2001:db8::1"},
+		CNAME:  []string{"lb.example.net"},
+	}
+
+	tests := []struct {
+		name   string
+		result Result
+		want   FilterReason
+	}{
+		{"地址属于泛解析", Result{A: []string{"192.0.2.2"}, AAAA: []string{"2001:db8::1"}}, FilterWildcardIP},
+		{"部分地址不同", Result{A: []string{"192.0.2.1", "192.0.2.3"}}, ""},
+		{"IPv6地址不同", Result{A: []string{"192.0.2.1"}, AAAA: []string{"2001:db8::2"}}, ""},
+		{"CNAME相同", Result{A: []string{"192.0.2.9"}, CNAME: "LB.example.net"}, FilterWildcardCNAME},
+		{"CNAME不同", Result{A: []string{"192.0.2.1"}, CNAME: "www.example.net"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wildcard.match(&tt.result); got != tt.want {
+				t.Errorf("match() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParentDomain(t *testing.T) {
+	tests := map[string]string{
+		"www.example.com": "example.com",
+		"a.b.example.com": "b.example.com",
+		"localhost":       "",
+	}
+	for name, want := range tests {
+		if got := parentDomain(name); got != want {
+			t.Errorf("parentDomain(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
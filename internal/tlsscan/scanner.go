@@ -0,0 +1,373 @@
+package tlsscan
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Marryname/WebScanner/pkg/common"
+	"github.com/Marryname/WebScanner/pkg/utils"
+)
+
+// DefaultPorts 未提供端口列表时检测的常见TLS端口
+var DefaultPorts = []int{443, 465, 636, 853, 993, 995, 8443, 9443}
+
+// 检测的协议版本，由低到高
+var protocolVersions = []uint16{
+	tls.VersionTLS10,
+	tls.VersionTLS11,
+	tls.VersionTLS12,
+	tls.VersionTLS13,
+}
+
+// 协议版本名称
+const (
+	VersionTLS10 = "TLS 1.0"
+	VersionTLS11 = "TLS 1.1"
+	VersionTLS12 = "TLS 1.2"
+	VersionTLS13 = "TLS 1.3"
+)
+
+var versionNames = map[uint16]string{
+	tls.VersionTLS10: VersionTLS10,
+	tls.VersionTLS11: VersionTLS11,
+	tls.VersionTLS12: VersionTLS12,
+	tls.VersionTLS13: VersionTLS13,
+}
+
+// Certificate 证书信息
+type Certificate struct {
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	SANs               []string  `json:"sans,omitempty"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	KeyType            string    `json:"key_type"`
+	KeySize            int       `json:"key_size"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	SelfSigned         bool      `json:"self_signed"`
+	SHA256             string    `json:"sha256"`
+}
+
+// Cipher 服务端接受的密码套件
+type Cipher struct {
+	Version string `json:"version"`
+	Name    string `json:"name"`
+}
+
+// ScanResult 单个IP上单个端口的TLS检测结果
+type ScanResult struct {
+	IP         string        `json:"ip"`
+	Port       int           `json:"port"`
+	Chain      []Certificate `json:"chain"`
+	Versions   []string      `json:"versions"`
+	Ciphers    []Cipher      `json:"ciphers"`
+	Weaknesses []string      `json:"weaknesses,omitempty"`
+}
+
+// DNSNames 返回叶子证书SAN中的域名，不包括IP地址
+func (r *ScanResult) DNSNames() []string {
+	if len(r.Chain) == 0 {
+		return nil
+	}
+
+	var names []string
+	for _, san := range r.Chain[0].SANs {
+		if net.ParseIP(san) == nil {
+			names = append(names, san)
+		}
+	}
+	return names
+}
+
+// Scanner TLS检测器。协议版本和密码套件通过 crypto/tls 客户端握手枚举，
+// 只能检测 crypto/tls 实现的 TLS 1.0-1.3 和其中的套件，SSLv3、SSLv2、导出级套件、
+// NULL 和匿名套件等不会被发现，服务端即使支持也不会出现在结果中
+type Scanner struct {
+	target     string
+	timeout    time.Duration
+	concurrent int
+	limiter    *utils.RateLimiter
+	pool       *utils.ResolverPool
+	exclude    *common.ExcludeList
+
+	mu  sync.Mutex
+	ips []string
+}
+
+// NewScanner 创建TLS检测器
+func NewScanner(target string, timeout time.Duration) *Scanner {
+	return &Scanner{
+		target:     target,
+		timeout:    timeout,
+		concurrent: 10,
+	}
+}
+
+// SetRateLimiter 设置共享的速率限制器
+func (s *Scanner) SetRateLimiter(limiter *utils.RateLimiter) {
+	s.limiter = limiter
+}
+
+// SetResolverPool 设置解析目标使用的解析器池，未设置时使用系统解析器
+func (s *Scanner) SetResolverPool(pool *utils.ResolverPool) {
+	s.pool = pool
+}
+
+// SetExcludeList 设置排除列表，解析出的地址在列表中时不连接
+func (s *Scanner) SetExcludeList(exclude *common.ExcludeList) {
+	s.exclude = exclude
+}
+
+// SetConcurrent 设置同时检测的端口数量
+func (s *Scanner) SetConcurrent(concurrent int) {
+	if concurrent > 0 {
+		s.concurrent = concurrent
+	}
+}
+
+// Scan 在目标解析出的每个IP地址上检测多个端口，不支持TLS的端口不会出现在结果中，
+// 结果按IP和端口排序
+func (s *Scanner) Scan(ctx context.Context, ports []int) ([]ScanResult, error) {
+	ips, err := s.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		results []ScanResult
+		mu      sync.Mutex
+	)
+
+	group := utils.NewTaskGroup(ctx, s.concurrent, false)
+	for _, ip := range ips {
+		for _, port := range ports {
+			ip, port := ip, port
+			if err := group.Go(func(ctx context.Context) error {
+				result, err := s.ScanPort(ctx, ip, port)
+				if err != nil {
+					return nil
+				}
+				mu.Lock()
+				results = append(results, *result)
+				mu.Unlock()
+				return nil
+			}); err != nil {
+				break
+			}
+		}
+	}
+	group.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].IP != results[j].IP {
+			return results[i].IP < results[j].IP
+		}
+		return results[i].Port < results[j].Port
+	})
+	return results, ctx.Err()
+}
+
+// resolve 解析目标的全部IP地址，跳过被排除的地址，结果会被缓存
+func (s *Scanner) resolve(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ips != nil {
+		return s.ips, nil
+	}
+
+	addrs, err := utils.LookupIP(ctx, s.pool, s.target)
+	if err != nil {
+		return nil, fmt.Errorf("解析目标失败: %v", err)
+	}
+	if addrs, err = s.exclude.FilterIPs(s.target, addrs); err != nil {
+		return nil, err
+	}
+	ips := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.String())
+	}
+	s.ips = ips
+	return ips, nil
+}
+
+// ScanPort 检测目标某个IP上单个端口的证书链、支持的协议版本和密码套件，
+// 目标为主机名时以其作为SNI。端口不支持TLS时返回错误
+func (s *Scanner) ScanPort(ctx context.Context, ip string, port int) (*ScanResult, error) {
+	addr := net.JoinHostPort(ip, strconv.Itoa(port))
+
+	state, err := s.handshake(ctx, addr, tls.VersionTLS10, tls.VersionTLS13, nil)
+	if err != nil {
+		return nil, fmt.Errorf("TLS握手失败: %v", err)
+	}
+
+	result := &ScanResult{
+		IP:   ip,
+		Port: port,
+	}
+	for _, cert := range state.PeerCertificates {
+		result.Chain = append(result.Chain, parseCertificate(cert))
+	}
+
+	for _, version := range protocolVersions {
+		ciphers, err := s.enumerateCiphers(ctx, addr, version)
+		if err != nil {
+			return nil, err
+		}
+		if len(ciphers) == 0 {
+			continue
+		}
+		result.Versions = append(result.Versions, versionNames[version])
+		for _, id := range ciphers {
+			result.Ciphers = append(result.Ciphers, Cipher{
+				Version: versionNames[version],
+				Name:    tls.CipherSuiteName(id),
+			})
+		}
+	}
+
+	result.Weaknesses = Analyze(result, time.Now())
+	return result, nil
+}
+
+// enumerateCiphers 枚举服务端在指定版本下接受的密码套件：
+// 每次握手后去掉已协商的套件重新握手，直到握手失败。
+// TLS 1.3 的套件不可配置，只记录协商结果。候选套件只有 crypto/tls 实现的套件，
+// 见 cipherSuitesFor。只有上下文取消时返回错误
+func (s *Scanner) enumerateCiphers(ctx context.Context, addr string, version uint16) ([]uint16, error) {
+	if version == tls.VersionTLS13 {
+		state, err := s.handshake(ctx, addr, version, version, nil)
+		if err != nil {
+			return nil, ctx.Err()
+		}
+		return []uint16{state.CipherSuite}, nil
+	}
+
+	remaining := cipherSuitesFor(version)
+	var accepted []uint16
+	for len(remaining) > 0 {
+		state, err := s.handshake(ctx, addr, version, version, remaining)
+		if err != nil {
+			break
+		}
+
+		accepted = append(accepted, state.CipherSuite)
+		next := remaining[:0:0]
+		for _, id := range remaining {
+			if id != state.CipherSuite {
+				next = append(next, id)
+			}
+		}
+		if len(next) == len(remaining) {
+			break
+		}
+		remaining = next
+	}
+	return accepted, ctx.Err()
+}
+
+// cipherSuitesFor 返回 crypto/tls 中支持指定版本的全部套件，包括不安全的套件；
+// crypto/tls 没有实现的导出级、NULL、匿名和 DSS 套件无法测试
+func cipherSuitesFor(version uint16) []uint16 {
+	var ids []uint16
+	for _, suites := range [][]*tls.CipherSuite{tls.CipherSuites(), tls.InsecureCipherSuites()} {
+		for _, suite := range suites {
+			for _, v := range suite.SupportedVersions {
+				if v == version {
+					ids = append(ids, suite.ID)
+					break
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// handshake 连接 ip:port 地址，以指定的版本范围和套件完成一次握手，不校验证书
+func (s *Scanner) handshake(ctx context.Context, addr string, minVersion, maxVersion uint16, suites []uint16) (*tls.ConnectionState, error) {
+	ip, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.limiter.Wait(ctx, ip); err != nil {
+		return nil, err
+	}
+
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	config := &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         minVersion,
+		MaxVersion:         maxVersion,
+		CipherSuites:       suites,
+	}
+	// IP地址不能作为SNI
+	if net.ParseIP(s.target) == nil {
+		config.ServerName = s.target
+	}
+
+	tlsConn := tls.Client(conn, config)
+	tlsConn.SetDeadline(time.Now().Add(s.timeout))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+
+	state := tlsConn.ConnectionState()
+	return &state, nil
+}
+
+// parseCertificate 提取证书信息
+func parseCertificate(cert *x509.Certificate) Certificate {
+	info := Certificate{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SANs:               append([]string{}, cert.DNSNames...),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		SelfSigned:         isSelfSigned(cert),
+	}
+	for _, ip := range cert.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+
+	sum := sha256.Sum256(cert.Raw)
+	info.SHA256 = hex.EncodeToString(sum[:])
+
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		info.KeyType, info.KeySize = "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		info.KeyType, info.KeySize = "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		info.KeyType, info.KeySize = "Ed25519", 256
+	default:
+		info.KeyType = cert.PublicKeyAlgorithm.String()
+	}
+	return info
+}
+
+// isSelfSigned 证书是否由自身的密钥签发
+func isSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawSubject, cert.RawIssuer) {
+		return false
+	}
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
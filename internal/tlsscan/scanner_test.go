@@ -0,0 +1,172 @@
+package tlsscan
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScanPort(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{
+		MinVersion: tls.VersionTLS10,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+		},
+	}
+	// 枚举套件时的握手失败是预期的
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	scanner := NewScanner("127.0.0.1", 2*time.Second)
+	result, err := scanner.ScanPort(context.Background(), "127.0.0.1", port)
+	if err != nil {
+		t.Fatalf("ScanPort() error = %v", err)
+	}
+
+	if len(result.Chain) == 0 {
+		t.Fatal("ScanPort() returned empty chain")
+	}
+	leaf := result.Chain[0]
+	if !leaf.SelfSigned || leaf.KeyType == "" || leaf.KeySize == 0 || leaf.SHA256 == "" {
+		t.Errorf("leaf = %+v", leaf)
+	}
+	if names := result.DNSNames(); !reflect.DeepEqual(names, []string{"example.com", "*.example.com"}) {
+		t.Errorf("DNSNames() = %v, want [example.com *.example.com]", names)
+	}
+
+	wantVersions := []string{VersionTLS10, VersionTLS11, VersionTLS12, VersionTLS13}
+	if !reflect.DeepEqual(result.Versions, wantVersions) {
+		t.Errorf("Versions = %v, want %v", result.Versions, wantVersions)
+	}
+
+	var tls12 []string
+	for _, c := range result.Ciphers {
+		if c.Version == VersionTLS12 {
+			tls12 = append(tls12, c.Name)
+		}
+	}
+	if len(tls12) != 3 {
+		t.Errorf("TLS 1.2 ciphers = %v, want 3", tls12)
+	}
+
+	for _, weakness := range []string{WeakSelfSigned, WeakTLS10, WeakTLS11, Weak3DES} {
+		if !contains(result.Weaknesses, weakness) {
+			t.Errorf("Weaknesses = %v, missing %s", result.Weaknesses, weakness)
+		}
+	}
+}
+
+func TestScanSNI(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		names []string
+	)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			mu.Lock()
+			names = append(names, hello.ServerName)
+			mu.Unlock()
+			return nil, nil
+		},
+	}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	// 按解析出的IP连接，SNI使用主机名
+	scanner := NewScanner("localhost", 2*time.Second)
+	results, err := scanner.Scan(context.Background(), []int{port})
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(results) != 1 || results[0].IP != "127.0.0.1" || results[0].Port != port {
+		t.Fatalf("Scan() = %+v, want 127.0.0.1:%d 的结果", results, port)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range names {
+		if name != "localhost" {
+			t.Errorf("ServerName = %q, want localhost", name)
+		}
+	}
+}
+
+func TestScanNonTLS(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-OpenSSH_8.2\r\n"))
+			conn.Close()
+		}
+	}()
+
+	scanner := NewScanner("127.0.0.1", time.Second)
+	results, err := scanner.Scan(context.Background(), []int{ln.Addr().(*net.TCPAddr).Port})
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Scan() = %+v, want no results", results)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := &ScanResult{
+		Chain: []Certificate{
+			{NotAfter: now.Add(-time.Hour), SignatureAlgorithm: "SHA1-RSA"},
+			{NotAfter: now.Add(time.Hour), SignatureAlgorithm: "SHA1-RSA", SelfSigned: true},
+		},
+		Versions: []string{VersionTLS12},
+		Ciphers: []Cipher{
+			{Version: VersionTLS12, Name: "TLS_ECDHE_RSA_WITH_RC4_128_SHA"},
+			{Version: VersionTLS12, Name: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+		},
+	}
+
+	want := []string{WeakExpired, WeakSHA1, WeakRC4}
+	if got := Analyze(result, now); !reflect.DeepEqual(got, want) {
+		t.Errorf("Analyze() = %v, want %v", got, want)
+	}
+
+	clean := &ScanResult{
+		Chain:    []Certificate{{NotAfter: now.Add(time.Hour), SignatureAlgorithm: "SHA256-RSA"}},
+		Versions: []string{VersionTLS12, VersionTLS13},
+	}
+	if got := Analyze(clean, now); len(got) != 0 {
+		t.Errorf("Analyze() = %v, want none", got)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package tlsscan
+
+import (
+	"strings"
+	"time"
+)
+
+// 弱配置标记
+const (
+	WeakExpired    = "expired"
+	WeakSelfSigned = "self-signed"
+	WeakSHA1       = "sha1-signature"
+	WeakTLS10      = "tls1.0"
+	WeakTLS11      = "tls1.1"
+	WeakRC4        = "rc4"
+	Weak3DES       = "3des"
+)
+
+// Analyze 根据检测结果标记弱配置：证书链中的过期证书、自签名叶子证书、
+// SHA-1签名，以及TLS 1.0/1.1协议和RC4/3DES套件
+func Analyze(result *ScanResult, now time.Time) []string {
+	var weaknesses []string
+	add := func(weakness string) {
+		for _, w := range weaknesses {
+			if w == weakness {
+				return
+			}
+		}
+		weaknesses = append(weaknesses, weakness)
+	}
+
+	for i, cert := range result.Chain {
+		if now.After(cert.NotAfter) {
+			add(WeakExpired)
+		}
+		if i == 0 && cert.SelfSigned {
+			add(WeakSelfSigned)
+		}
+		// 信任锚自身的签名不参与校验，不计入SHA-1
+		if !cert.SelfSigned && strings.Contains(cert.SignatureAlgorithm, "SHA1") {
+			add(WeakSHA1)
+		}
+	}
+
+	for _, version := range result.Versions {
+		switch version {
+		case VersionTLS10:
+			add(WeakTLS10)
+		case VersionTLS11:
+			add(WeakTLS11)
+		}
+	}
+
+	for _, cipher := range result.Ciphers {
+		switch {
+		case strings.Contains(cipher.Name, "_RC4_"):
+			add(WeakRC4)
+		case strings.Contains(cipher.Name, "_3DES_"):
+			add(Weak3DES)
+		}
+	}
+
+	return weaknesses
+}
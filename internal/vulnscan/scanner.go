@@ -3,10 +3,14 @@ package vulnscan
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"sync"
 	"time"
+
+	"github.com/Marryname/WebScanner/pkg/common"
+	"github.com/Marryname/WebScanner/pkg/utils"
 )
 
 // VulnResult 漏洞扫描结果
@@ -27,6 +31,9 @@ type Scanner struct {
 	concurrent int
 	templates  *TemplateManager
 	client     *http.Client
+	limiter    *utils.RateLimiter
+	pool       *utils.ResolverPool
+	exclude    *common.ExcludeList
 }
 
 // NewScanner 创建新的漏洞扫描器
@@ -38,21 +45,46 @@ func NewScanner(target string, timeout time.Duration, concurrent int) *Scanner {
 		concurrent = 10
 	}
 
-	return &Scanner{
+	s := &Scanner{
 		target:     target,
 		timeout:    timeout,
 		concurrent: concurrent,
 		templates:  NewTemplateManager(),
-		client: &http.Client{
-			Timeout: timeout,
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				if len(via) >= 10 {
-					return fmt.Errorf("too many redirects")
-				}
-				return nil
-			},
+	}
+	s.client = &http.Client{
+		// 每个请求单独连接，使速率限制按请求生效
+		Transport: &http.Transport{DialContext: s.dial, DisableKeepAlives: true},
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("too many redirects")
+			}
+			return nil
 		},
 	}
+	return s
+}
+
+// SetRateLimiter 设置共享的速率限制器
+func (s *Scanner) SetRateLimiter(limiter *utils.RateLimiter) {
+	s.limiter = limiter
+}
+
+// SetResolverPool 设置解析目标使用的解析器池，未设置时使用系统解析器
+func (s *Scanner) SetResolverPool(pool *utils.ResolverPool) {
+	s.pool = pool
+}
+
+// SetExcludeList 设置排除列表，解析出的地址在列表中时不连接
+func (s *Scanner) SetExcludeList(exclude *common.ExcludeList) {
+	s.exclude = exclude
+}
+
+// dial 通过解析器池解析请求的主机，跳过被排除的地址，按解析出的IP限速后建立连接。
+// 重定向到其他主机时同样经过这里
+func (s *Scanner) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := utils.HostDialer{Pool: s.pool, Limiter: s.limiter, Exclude: s.exclude, Timeout: s.timeout}
+	return dialer.DialContext(ctx, network, addr)
 }
 
 // LoadTemplates 加载漏洞模板
@@ -72,59 +104,28 @@ func (s *Scanner) Scan(ctx context.Context) ([]VulnResult, error) {
 	var (
 		results []VulnResult
 		mu      sync.Mutex
-		wg      sync.WaitGroup
-		errChan = make(chan error, 1)
 	)
 
-	// 创建工作通道
-	templateChan := make(chan *Template, s.concurrent)
-
-	// 启动工作协程
-	for i := 0; i < s.concurrent; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for template := range templateChan {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					if result := s.scanWithTemplate(ctx, template); result != nil {
-						mu.Lock()
-						results = append(results, *result)
-						mu.Unlock()
-					}
-				}
+	// 每个模板作为一个任务提交，上下文取消后停止提交
+	group := utils.NewTaskGroup(ctx, s.concurrent, false)
+	for _, template := range s.templates.templates {
+		template := template
+		if err := group.Go(func(ctx context.Context) error {
+			if result := s.scanWithTemplate(ctx, template); result != nil {
+				mu.Lock()
+				results = append(results, *result)
+				mu.Unlock()
 			}
-		}()
+			return nil
+		}); err != nil {
+			break
+		}
 	}
 
-	// 发送模板到工作通道
-	go func() {
-		for _, template := range s.templates.templates {
-			select {
-			case <-ctx.Done():
-				close(templateChan)
-				return
-			case templateChan <- template:
-			}
-		}
-		close(templateChan)
-	}()
-
-	// 等待所有工作完成
-	go func() {
-		wg.Wait()
-		close(errChan)
-	}()
-
-	// 等待完成或上下文取消
-	select {
-	case <-ctx.Done():
-		return results, ctx.Err()
-	case err := <-errChan:
+	if err := group.Wait(); err != nil {
 		return results, err
 	}
+	return results, ctx.Err()
 }
 
 // scanWithTemplate 使用单个模板进行扫描
@@ -0,0 +1,191 @@
+package common
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// ErrExcluded 目标或其解析出的全部地址都在排除列表中
+var ErrExcluded = errors.New("目标在排除列表中")
+
+// ExcludeList 目标排除列表，支持IP、CIDR、IP范围、主机名以及 *.example.com 形式的通配域名
+type ExcludeList struct {
+	nets     []*net.IPNet
+	ips      map[string]bool
+	hosts    map[string]bool
+	suffixes []string
+}
+
+// NewExcludeList 创建空的排除列表
+func NewExcludeList() *ExcludeList {
+	return &ExcludeList{
+		ips:   make(map[string]bool),
+		hosts: make(map[string]bool),
+	}
+}
+
+// Add 添加逗号分隔的排除项
+func (e *ExcludeList) Add(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if err := e.addItem(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddFile 从文件读取排除项，格式与目标文件相同
+func (e *ExcludeList) AddFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("读取排除文件失败: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := e.Add(line); err != nil {
+			return fmt.Errorf("%s 第 %d 行: %v", path, lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取排除文件失败: %v", err)
+	}
+	return nil
+}
+
+// addItem 添加单个排除项
+func (e *ExcludeList) addItem(item string) error {
+	// CIDR按网段匹配，无需展开
+	if strings.Contains(item, "/") {
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return fmt.Errorf("无效的排除网段: %s", item)
+		}
+		e.nets = append(e.nets, ipNet)
+		return nil
+	}
+
+	// 通配域名
+	if strings.HasPrefix(item, "*.") {
+		suffix := strings.ToLower(strings.TrimSuffix(item[1:], "."))
+		if !hostnamePattern.MatchString(suffix[1:]) {
+			return fmt.Errorf("无效的排除域名: %s", item)
+		}
+		e.suffixes = append(e.suffixes, suffix)
+		return nil
+	}
+
+	expanded, err := expandTarget(item)
+	if err != nil {
+		return fmt.Errorf("无效的排除项: %s", item)
+	}
+	for _, target := range expanded {
+		if net.ParseIP(target) != nil {
+			e.ips[target] = true
+		} else {
+			e.hosts[target] = true
+		}
+	}
+	return nil
+}
+
+// Len 返回排除项数量
+func (e *ExcludeList) Len() int {
+	if e == nil {
+		return 0
+	}
+	return len(e.nets) + len(e.ips) + len(e.hosts) + len(e.suffixes)
+}
+
+// Contains 判断目标是否被排除，nil 的排除列表不排除任何目标
+func (e *ExcludeList) Contains(target string) bool {
+	if e == nil {
+		return false
+	}
+	if ip := net.ParseIP(target); ip != nil {
+		if e.ips[normalizeIP(ip).String()] {
+			return true
+		}
+		for _, ipNet := range e.nets {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(target), ".")
+	if e.hosts[host] {
+		return true
+	}
+	for _, suffix := range e.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter 过滤目标列表，返回保留的目标和被排除的目标
+func (e *ExcludeList) Filter(targets []string) (kept, skipped []string) {
+	for _, target := range targets {
+		if e.Contains(target) {
+			skipped = append(skipped, target)
+		} else {
+			kept = append(kept, target)
+		}
+	}
+	return kept, skipped
+}
+
+// FilterIPs 从主机解析出的地址中移除被排除的地址。主机名本身被排除，
+// 或者全部地址都被排除时返回 ErrExcluded
+func (e *ExcludeList) FilterIPs(host string, ips []net.IP) ([]net.IP, error) {
+	if e.Len() == 0 {
+		return ips, nil
+	}
+	if e.Contains(host) {
+		return nil, fmt.Errorf("%s: %w", host, ErrExcluded)
+	}
+
+	kept := make([]net.IP, 0, len(ips))
+	for _, ip := range ips {
+		if !e.Contains(ip.String()) {
+			kept = append(kept, ip)
+		}
+	}
+	if len(kept) == 0 && len(ips) > 0 {
+		return nil, fmt.Errorf("%s 的地址: %w", host, ErrExcluded)
+	}
+	return kept, nil
+}
+
+// FilterPorts 从端口列表中移除被排除的端口，返回保留的端口和被排除的端口
+func FilterPorts(ports, excluded []int) (kept, skipped []int) {
+	excludedSet := make(map[int]bool, len(excluded))
+	for _, port := range excluded {
+		excludedSet[port] = true
+	}
+
+	for _, port := range ports {
+		if excludedSet[port] {
+			skipped = append(skipped, port)
+		} else {
+			kept = append(kept, port)
+		}
+	}
+	return kept, skipped
+}
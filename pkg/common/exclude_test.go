@@ -0,0 +1,76 @@
+package common
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestExcludeList(t *testing.T) {
+	excludes := NewExcludeList()
+	if err := excludes.Add("10.0.0.0/30, 192.168.1.5-6, admin.example.com, *.internal.example.com"); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"10.0.0.2", true},
+		{"10.0.0.4", false},
+		{"192.168.1.6", true},
+		{"192.168.1.7", false},
+		{"ADMIN.example.com", true},
+		{"www.example.com", false},
+		{"db.internal.example.com", true},
+		{"internal.example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			if got := excludes.Contains(tt.target); got != tt.want {
+				t.Errorf("Contains(%s) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+
+	if err := excludes.Add("bad host!"); err == nil {
+		t.Error("期望无效的排除项返回错误")
+	}
+}
+
+func TestExcludeListFilterIPs(t *testing.T) {
+	excludes := NewExcludeList()
+	if err := excludes.Add("10.0.0.0/30, admin.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	ips := []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("192.0.2.1")}
+
+	kept, err := excludes.FilterIPs("www.example.com", ips)
+	if err != nil || len(kept) != 1 || kept[0].String() != "192.0.2.1" {
+		t.Errorf("FilterIPs() = %v, %v, want [192.0.2.1]", kept, err)
+	}
+	if _, err := excludes.FilterIPs("www.example.com", ips[:1]); !errors.Is(err, ErrExcluded) {
+		t.Errorf("FilterIPs() error = %v, want ErrExcluded", err)
+	}
+	if _, err := excludes.FilterIPs("admin.example.com", ips[1:]); !errors.Is(err, ErrExcluded) {
+		t.Errorf("FilterIPs() error = %v, 被排除的主机名应返回 ErrExcluded", err)
+	}
+
+	// nil 的排除列表不排除任何地址
+	var none *ExcludeList
+	if kept, err := none.FilterIPs("admin.example.com", ips); err != nil || len(kept) != 2 || none.Contains("10.0.0.1") {
+		t.Errorf("nil FilterIPs() = %v, %v", kept, err)
+	}
+}
+
+func TestFilterPorts(t *testing.T) {
+	kept, skipped := FilterPorts([]int{22, 80, 443, 3389}, []int{22, 3389, 8080})
+	if !reflect.DeepEqual(kept, []int{80, 443}) {
+		t.Errorf("kept = %v, want [80 443]", kept)
+	}
+	if !reflect.DeepEqual(skipped, []int{22, 3389}) {
+		t.Errorf("skipped = %v, want [22 3389]", skipped)
+	}
+}
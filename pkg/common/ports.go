@@ -0,0 +1,111 @@
+package common
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//go:embed top-ports.txt
+var topPortsData string
+
+// topPorts nmap 中最常见的1000个TCP端口，只有前 rankedPorts 个严格按频率排序
+var topPorts = loadTopPorts(topPortsData)
+
+// rankedPorts 内置列表中按频率排序的端口数量，之后的端口按端口号排列
+const rankedPorts = 100
+
+// 端口预设名称
+const (
+	PresetCommon  = "common"  // 配置文件中的 ports.common_ports
+	PresetAll     = "all"     // 1-65535
+	PresetTop100  = "top100"  // 频率最高的100个端口
+	PresetTop1000 = "top1000" // 最常见的1000个端口
+	presetTop     = "top"
+)
+
+// loadTopPorts 解析内置的端口频率列表
+func loadTopPorts(data string) []int {
+	var ports []int
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		port, err := strconv.Atoi(line)
+		if err != nil {
+			panic(fmt.Sprintf("invalid built-in port list entry: %s", line))
+		}
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// TopPorts 返回最常见的n个TCP端口，n只能是100或1000：内置列表只有前100个端口
+// 按频率排序，其他n截取出的只是端口号较小的端口，并不是频率最高的n个
+func TopPorts(n int) ([]int, error) {
+	if n != rankedPorts && n != len(topPorts) {
+		return nil, fmt.Errorf("unsupported port preset top%d (use %s or %s)", n, PresetTop100, PresetTop1000)
+	}
+	ports := make([]int, n)
+	copy(ports, topPorts[:n])
+	return ports, nil
+}
+
+// ParsePorts 解析端口描述，逗号分隔的每一项可以是端口、端口范围或预设名称
+// (top100、top1000、common、all)，commonPorts 为 common 预设对应的端口列表，
+// 结果去重并保持顺序
+func ParsePorts(spec string, commonPorts []int) ([]int, error) {
+	var ports []int
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+
+		switch {
+		case item == PresetCommon:
+			if len(commonPorts) == 0 {
+				return nil, fmt.Errorf("common port list is not configured (ports.common_ports)")
+			}
+			ports = append(ports, commonPorts...)
+		case item == PresetAll:
+			for port := 1; port < 65536; port++ {
+				ports = append(ports, port)
+			}
+		case strings.HasPrefix(item, presetTop):
+			n, err := strconv.Atoi(strings.TrimPrefix(item, presetTop))
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid port preset: %s", item)
+			}
+			top, err := TopPorts(n)
+			if err != nil {
+				return nil, err
+			}
+			ports = append(ports, top...)
+		default:
+			parsed, err := ParsePortRange(item)
+			if err != nil {
+				return nil, err
+			}
+			ports = append(ports, parsed...)
+		}
+	}
+
+	return dedupPorts(ports), nil
+}
+
+// dedupPorts 去除重复端口，保持首次出现的顺序
+func dedupPorts(ports []int) []int {
+	seen := make(map[int]bool, len(ports))
+	result := make([]int, 0, len(ports))
+	for _, port := range ports {
+		if seen[port] {
+			continue
+		}
+		seen[port] = true
+		result = append(result, port)
+	}
+	return result
+}
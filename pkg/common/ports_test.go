@@ -0,0 +1,62 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTopPorts(t *testing.T) {
+	if got, err := TopPorts(1000); err != nil || len(got) != 1000 {
+		t.Errorf("TopPorts(1000) = %d 个端口, %v, want 1000", len(got), err)
+	}
+	got, err := TopPorts(100)
+	if err != nil || len(got) != 100 || !reflect.DeepEqual(got[:3], []int{80, 23, 443}) {
+		t.Errorf("TopPorts(100) = %v, %v", got, err)
+	}
+
+	// 第100个之后的端口不按频率排序，不能截取
+	for _, n := range []int{3, 500} {
+		if _, err := TopPorts(n); err == nil {
+			t.Errorf("TopPorts(%d) 应返回错误", n)
+		}
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	commonPorts := []int{22, 80, 443}
+
+	tests := []struct {
+		name    string
+		spec    string
+		wantLen int
+		want    []int
+		wantErr bool
+	}{
+		{name: "top100", spec: "top100", wantLen: 100},
+		{name: "top1000", spec: "top1000", wantLen: 1000},
+		{name: "all", spec: "all", wantLen: 65535},
+		{name: "common", spec: "common", want: []int{22, 80, 443}},
+		{name: "预设与范围混合并去重", spec: "common,80-81,8443", want: []int{22, 80, 443, 81, 8443}},
+		{name: "超出内置列表", spec: "top5000", wantErr: true},
+		{name: "未按频率排序的截取", spec: "top500", wantErr: true},
+		{name: "无效预设", spec: "topabc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePorts(tt.spec, commonPorts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePorts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePorts() = %v, want %v", got, tt.want)
+			}
+			if tt.wantLen > 0 && len(got) != tt.wantLen {
+				t.Errorf("len(ParsePorts()) = %d, want %d", len(got), tt.wantLen)
+			}
+		})
+	}
+}
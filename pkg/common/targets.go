@@ -0,0 +1,187 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MaxExpandHosts 单个CIDR或IP范围允许展开的最大主机数量
+const MaxExpandHosts = 65536
+
+var hostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]([a-zA-Z0-9_-]*[a-zA-Z0-9_])?(\.[a-zA-Z0-9_]([a-zA-Z0-9_-]*[a-zA-Z0-9_])?)*\.?$`)
+
+// ParseTargets 解析目标描述，支持逗号分隔的IP、域名、CIDR(10.0.0.0/24)
+// 和IP范围(10.0.0.1-50 或 10.0.0.1-10.0.0.50)，结果去重并保持原有顺序
+func ParseTargets(spec string) ([]string, error) {
+	var targets []string
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		expanded, err := expandTarget(item)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, expanded...)
+	}
+
+	return DedupTargets(targets), nil
+}
+
+// ReadTargetFile 从文件读取目标，每行可包含一个或多个逗号分隔的目标，
+// 忽略空行和以#开头的注释
+func ReadTargetFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取目标文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var targets []string
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parsed, err := ParseTargets(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s 第 %d 行: %v", path, lineNo, err)
+		}
+		targets = append(targets, parsed...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取目标文件失败: %v", err)
+	}
+
+	return DedupTargets(targets), nil
+}
+
+// DedupTargets 去除重复目标，保持首次出现的顺序
+func DedupTargets(targets []string) []string {
+	seen := make(map[string]bool, len(targets))
+	result := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+		result = append(result, target)
+	}
+	return result
+}
+
+// expandTarget 展开单个目标
+func expandTarget(item string) ([]string, error) {
+	// CIDR网段
+	if strings.Contains(item, "/") {
+		return expandCIDR(item)
+	}
+
+	// IP范围，仅当'-'左侧为IP地址时才视为范围，避免与含'-'的域名混淆
+	if idx := strings.Index(item, "-"); idx > 0 {
+		if start := net.ParseIP(item[:idx]); start != nil {
+			return expandRange(start, item[idx+1:])
+		}
+	}
+
+	if ip := net.ParseIP(item); ip != nil {
+		return []string{ip.String()}, nil
+	}
+
+	if len(item) > 255 || !hostnamePattern.MatchString(item) {
+		return nil, fmt.Errorf("无效的目标: %s", item)
+	}
+	return []string{strings.TrimSuffix(strings.ToLower(item), ".")}, nil
+}
+
+// expandCIDR 展开CIDR网段中的所有地址
+func expandCIDR(cidr string) ([]string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("无效的CIDR: %s", cidr)
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	if bits-ones > 16 {
+		return nil, fmt.Errorf("CIDR范围过大: %s (最多 %d 个地址)", cidr, MaxExpandHosts)
+	}
+
+	start := normalizeIP(ipNet.IP)
+	count := 1 << uint(bits-ones)
+	targets := make([]string, 0, count)
+	for i, ip := 0, start; i < count; i, ip = i+1, nextIP(ip) {
+		targets = append(targets, ip.String())
+	}
+	return targets, nil
+}
+
+// expandRange 展开IP范围，end可以是完整IP或最后一段的数值
+func expandRange(start net.IP, end string) ([]string, error) {
+	start = normalizeIP(start)
+	endIP := net.ParseIP(end)
+	if endIP == nil {
+		// 简写形式: 10.0.0.1-50
+		last, err := strconv.Atoi(end)
+		if err != nil || last < 0 || last > 255 || start.To4() == nil {
+			return nil, fmt.Errorf("无效的IP范围: %s-%s", start, end)
+		}
+		endIP = make(net.IP, len(start))
+		copy(endIP, start)
+		endIP[len(endIP)-1] = byte(last)
+	}
+	endIP = normalizeIP(endIP)
+
+	if len(start) != len(endIP) {
+		return nil, fmt.Errorf("IP范围两端地址类型不一致: %s-%s", start, endIP)
+	}
+
+	lo := new(big.Int).SetBytes(start)
+	hi := new(big.Int).SetBytes(endIP)
+	if lo.Cmp(hi) > 0 {
+		return nil, fmt.Errorf("IP范围起始地址大于结束地址: %s-%s", start, endIP)
+	}
+	count := new(big.Int).Sub(hi, lo)
+	if !count.IsInt64() || count.Int64() >= MaxExpandHosts {
+		return nil, fmt.Errorf("IP范围过大: %s-%s (最多 %d 个地址)", start, endIP, MaxExpandHosts)
+	}
+
+	var targets []string
+	for ip := start; ; ip = nextIP(ip) {
+		targets = append(targets, ip.String())
+		if ip.Equal(endIP) {
+			break
+		}
+	}
+	return targets, nil
+}
+
+// normalizeIP IPv4地址统一使用4字节表示
+func normalizeIP(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip.To16()
+}
+
+// nextIP 返回下一个IP地址
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "逗号分隔并去重",
+			spec: "example.com, 10.0.0.1,Example.com,10.0.0.1",
+			want: []string{"example.com", "10.0.0.1"},
+		},
+		{
+			name: "CIDR",
+			spec: "192.168.1.0/30",
+			want: []string{"192.168.1.0", "192.168.1.1", "192.168.1.2", "192.168.1.3"},
+		},
+		{
+			name: "IP范围简写",
+			spec: "10.0.0.254-255",
+			want: []string{"10.0.0.254", "10.0.0.255"},
+		},
+		{
+			name: "IP范围完整形式跨网段",
+			spec: "10.0.0.255-10.0.1.1",
+			want: []string{"10.0.0.255", "10.0.1.0", "10.0.1.1"},
+		},
+		{
+			name: "含连字符的域名",
+			spec: "my-host.example.com",
+			want: []string{"my-host.example.com"},
+		},
+		{
+			name:    "CIDR过大",
+			spec:    "10.0.0.0/8",
+			wantErr: true,
+		},
+		{
+			name:    "范围倒置",
+			spec:    "10.0.0.50-10",
+			wantErr: true,
+		},
+		{
+			name:    "无效目标",
+			spec:    "bad host!",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTargets(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTargets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTargets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadTargetFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	content := "# 资产列表\nexample.com\n\n10.0.0.1-2, example.com\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("写入目标文件失败: %v", err)
+	}
+
+	got, err := ReadTargetFile(path)
+	if err != nil {
+		t.Fatalf("ReadTargetFile() error = %v", err)
+	}
+	want := []string{"example.com", "10.0.0.1", "10.0.0.2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadTargetFile() = %v, want %v", got, want)
+	}
+}
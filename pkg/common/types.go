@@ -6,11 +6,12 @@ import (
 
 // Result 通用扫描结果结构
 type Result struct {
-	Target    string
-	Status    Status
-	Timestamp time.Time
-	Duration  time.Duration
-	Error     error
+	Target    string        `json:"target"`
+	Status    Status        `json:"status"`
+	Reason    string        `json:"reason,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+	Duration  time.Duration `json:"duration,omitempty"`
+	Error     error         `json:"-"`
 }
 
 type Status string
@@ -21,6 +22,16 @@ const (
 	StatusSkipped Status = "skipped"
 )
 
+// NewSkippedResult 创建表示目标被跳过的结果
+func NewSkippedResult(target, reason string) Result {
+	return Result{
+		Target:    target,
+		Status:    StatusSkipped,
+		Reason:    reason,
+		Timestamp: time.Now(),
+	}
+}
+
 // Config 通用配置结构
 type Config struct {
 	Timeout    time.Duration
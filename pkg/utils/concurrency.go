@@ -1,39 +1,136 @@
 package utils
 
 import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
 	"sync"
 )
 
-// ConcurrencyLimiter 并发限制器
-type ConcurrencyLimiter struct {
-	limit  int
-	tokens chan struct{}
-	wg     sync.WaitGroup
+// PanicError 任务执行过程中发生panic时记录的错误
+type PanicError struct {
+	Value interface{}
+	Stack []byte
 }
 
-// NewConcurrencyLimiter 创建新的并发限制器
-func NewConcurrencyLimiter(limit int) *ConcurrencyLimiter {
-	return &ConcurrencyLimiter{
-		limit:  limit,
-		tokens: make(chan struct{}, limit),
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("任务发生panic: %v", e.Value)
+}
+
+// MultiError 多个任务错误的集合，errors.Is/As 不会检查其中的错误，需要遍历切片逐个判断
+type MultiError []error
+
+func (m MultiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
 	}
+	return strings.Join(msgs, "; ")
+}
+
+// TaskGroup 支持上下文取消、错误收集和panic恢复的并发任务组
+type TaskGroup struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	tokens   chan struct{}
+	wg       sync.WaitGroup
+	failFast bool
+
+	mu   sync.Mutex
+	errs []error
 }
 
-// Execute 执行受限制的并发任务
-func (l *ConcurrencyLimiter) Execute(fn func()) {
-	l.tokens <- struct{}{} // 获取令牌
-	l.wg.Add(1)
+// NewTaskGroup 创建任务组，limit为最大并发数，
+// failFast为true时第一个错误会取消任务组的上下文，尚未开始的任务将不再执行
+func NewTaskGroup(ctx context.Context, limit int, failFast bool) *TaskGroup {
+	if limit <= 0 {
+		limit = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &TaskGroup{
+		ctx:      ctx,
+		cancel:   cancel,
+		tokens:   make(chan struct{}, limit),
+		failFast: failFast,
+	}
+}
+
+// Context 返回任务组的上下文
+func (g *TaskGroup) Context() context.Context {
+	return g.ctx
+}
+
+// Go 获取令牌后在新协程中执行任务
+// 等待令牌期间上下文被取消时直接返回上下文错误，任务不会执行
+func (g *TaskGroup) Go(fn func(ctx context.Context) error) error {
+	if err := g.ctx.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case <-g.ctx.Done():
+		return g.ctx.Err()
+	case g.tokens <- struct{}{}:
+	}
 
+	g.wg.Add(1)
 	go func() {
 		defer func() {
-			<-l.tokens // 释放令牌
-			l.wg.Done()
+			if r := recover(); r != nil {
+				g.addError(&PanicError{Value: r, Stack: debug.Stack()})
+			}
+			<-g.tokens
+			g.wg.Done()
 		}()
-		fn()
+
+		if err := fn(g.ctx); err != nil {
+			g.addError(err)
+		}
 	}()
+
+	return nil
+}
+
+// addError 记录任务错误
+func (g *TaskGroup) addError(err error) {
+	g.mu.Lock()
+	g.errs = append(g.errs, err)
+	g.mu.Unlock()
+
+	if g.failFast {
+		g.cancel()
+	}
+}
+
+// Wait 等待所有已提交的任务完成并返回第一个错误，之后任务组不能再提交任务
+func (g *TaskGroup) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.errs) > 0 {
+		return g.errs[0]
+	}
+	return nil
 }
 
-// Wait 等待所有任务完成
-func (l *ConcurrencyLimiter) Wait() {
-	l.wg.Wait()
+// WaitAll 等待所有已提交的任务完成并返回包含全部错误的MultiError
+func (g *TaskGroup) WaitAll() error {
+	g.Wait()
+	if errs := g.Errors(); len(errs) > 0 {
+		return MultiError(errs)
+	}
+	return nil
+}
+
+// Errors 返回目前收集到的所有错误
+func (g *TaskGroup) Errors() []error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	errs := make([]error, len(g.errs))
+	copy(errs, g.errs)
+	return errs
 }
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTaskGroupLimit(t *testing.T) {
+	group := NewTaskGroup(context.Background(), 3, false)
+
+	var running, peak int32
+	for i := 0; i < 20; i++ {
+		group.Go(func(ctx context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if peak > 3 {
+		t.Errorf("最大并发数 = %d, want <= 3", peak)
+	}
+}
+
+func TestTaskGroupContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	group := NewTaskGroup(ctx, 1, false)
+
+	// 占用唯一的令牌
+	release := make(chan struct{})
+	group.Go(func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	// 令牌已满时取消上下文，Go应立即返回而不是阻塞
+	done := make(chan error)
+	go func() {
+		done <- group.Go(func(ctx context.Context) error {
+			t.Error("上下文取消后任务不应执行")
+			return nil
+		})
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Go() error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("上下文取消后Go()仍然阻塞")
+	}
+
+	close(release)
+	group.Wait()
+}
+
+func TestTaskGroupErrors(t *testing.T) {
+	errFirst := errors.New("first")
+
+	group := NewTaskGroup(context.Background(), 1, false)
+	group.Go(func(ctx context.Context) error { return errFirst })
+	group.Go(func(ctx context.Context) error { panic("boom") })
+
+	err := group.WaitAll()
+	multi, ok := err.(MultiError)
+	if !ok || len(multi) != 2 {
+		t.Fatalf("WaitAll() = %v, want 2 errors", err)
+	}
+	if multi[0] != errFirst {
+		t.Errorf("第一个错误 = %v, want %v", multi[0], errFirst)
+	}
+	var panicErr *PanicError
+	if !errors.As(multi[1], &panicErr) || panicErr.Value != "boom" {
+		t.Errorf("第二个错误 = %v, want PanicError(boom)", multi[1])
+	}
+}
+
+func TestTaskGroupFailFast(t *testing.T) {
+	group := NewTaskGroup(context.Background(), 1, true)
+
+	errFail := errors.New("fail")
+	group.Go(func(ctx context.Context) error { return errFail })
+
+	// 第一个任务失败后上下文被取消，后续任务不再提交
+	var executed int32
+	for i := 0; i < 10; i++ {
+		if err := group.Go(func(ctx context.Context) error {
+			atomic.AddInt32(&executed, 1)
+			return nil
+		}); err != nil {
+			break
+		}
+	}
+
+	if err := group.Wait(); err != errFail {
+		t.Errorf("Wait() error = %v, want %v", err, errFail)
+	}
+	if executed == 10 {
+		t.Error("failFast模式下第一个错误后仍执行了全部任务")
+	}
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/Marryname/WebScanner/pkg/common"
+)
+
+// LookupIP 通过解析器池查询主机的地址，pool 为nil时使用系统解析器，IP地址直接返回
+func LookupIP(ctx context.Context, pool *ResolverPool, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	if pool != nil {
+		ips, _, err := pool.Resolve(ctx, host)
+		return ips, err
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+	return ips, nil
+}
+
+// HostDialer 按主机名连接时先通过解析器池解析，再依次连接解析出的地址，
+// 可以作为 http.Transport 的 DialContext 使用
+type HostDialer struct {
+	// Pool 解析主机名使用的解析器池，为nil时使用系统解析器
+	Pool *ResolverPool
+	// Limiter 每次连接前按解析出的IP等待，与其他模块共享同一主机的限额
+	Limiter *RateLimiter
+	// Exclude 不允许连接的主机和地址，解析出的地址逐个检查
+	Exclude *common.ExcludeList
+	Timeout time.Duration
+}
+
+// DialContext 连接 host:port 形式的地址，跳过被排除的地址，返回第一个连接成功的地址的连接。
+// 全部地址都被排除时返回 common.ErrExcluded
+func (d *HostDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := LookupIP(ctx, d.Pool, host)
+	if err != nil {
+		return nil, err
+	}
+	if ips, err = d.Exclude.FilterIPs(host, ips); err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("%s 没有可用地址", host)
+	}
+
+	dialer := net.Dialer{Timeout: d.Timeout}
+	var lastErr error
+	for _, ip := range ips {
+		if err := d.Limiter.Wait(ctx, ip.String()); err != nil {
+			return nil, err
+		}
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
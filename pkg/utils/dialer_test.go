@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Marryname/WebScanner/internal/dnstest"
+	"github.com/Marryname/WebScanner/pkg/common"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestHostDialer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	// 只有解析器池知道 www.example.com 指向本机
+	pool := NewResolverPool([]string{dnstest.Serve(t, &dnstest.Zone{Records: []dnsmessage.Resource{
+		dnstest.A("www.example.com", 60, "127.0.0.1"),
+	}})}, time.Second)
+
+	limiter := NewRateLimiter(0, 100)
+	dialer := &HostDialer{Pool: pool, Limiter: limiter, Timeout: time.Second}
+	conn, err := dialer.DialContext(context.Background(), "tcp", net.JoinHostPort("www.example.com", port))
+	if err != nil {
+		t.Fatalf("DialContext() error = %v", err)
+	}
+	if got := conn.RemoteAddr().String(); got != ln.Addr().String() {
+		t.Errorf("RemoteAddr() = %s, want %s", got, ln.Addr())
+	}
+	conn.Close()
+
+	if _, err := dialer.DialContext(context.Background(), "tcp", net.JoinHostPort("missing.example.com", port)); err == nil {
+		t.Error("DialContext() 对无法解析的名称应返回错误")
+	}
+	if stats := pool.Stats(); stats[0].Queries == 0 {
+		t.Error("DialContext() 没有使用解析器池")
+	}
+	// 限速按解析出的IP计算，与直接按IP连接的模块共用同一个限额
+	if _, ok := limiter.hosts["127.0.0.1"]; !ok || len(limiter.hosts) != 1 {
+		t.Errorf("limiter hosts = %v, want 只有 127.0.0.1", limiter.hosts)
+	}
+}
+
+func TestHostDialerExclude(t *testing.T) {
+	exclude := common.NewExcludeList()
+	if err := exclude.Add("127.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 被排除的地址不会被连接，也不会消耗限速
+	limiter := NewRateLimiter(0, 100)
+	dialer := &HostDialer{Limiter: limiter, Exclude: exclude, Timeout: time.Second}
+	if _, err := dialer.DialContext(context.Background(), "tcp", "127.0.0.1:80"); !errors.Is(err, common.ErrExcluded) {
+		t.Errorf("DialContext() error = %v, want ErrExcluded", err)
+	}
+	if len(limiter.hosts) != 0 {
+		t.Errorf("limiter hosts = %v, want 空", limiter.hosts)
+	}
+}
+
+func TestLookupIP(t *testing.T) {
+	ips, err := LookupIP(context.Background(), nil, "192.0.2.1")
+	if err != nil || len(ips) != 1 || ips[0].String() != "192.0.2.1" {
+		t.Errorf("LookupIP() = %v, %v", ips, err)
+	}
+}
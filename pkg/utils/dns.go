@@ -0,0 +1,426 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// 单次区域传送最多读取的报文数量
+const maxTransferMessages = 10000
+
+// EDNS0声明的UDP报文大小，减少大响应被截断
+const ednsPayloadSize = 4096
+
+// dnsmessage 未定义的记录类型
+const (
+	TypeCAA  dnsmessage.Type = 257
+	TypeNSEC dnsmessage.Type = 47
+)
+
+// dnsTypes 支持按名称查询的记录类型
+var dnsTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"NS":    dnsmessage.TypeNS,
+	"TXT":   dnsmessage.TypeTXT,
+	"SOA":   dnsmessage.TypeSOA,
+	"CAA":   TypeCAA,
+	"PTR":   dnsmessage.TypePTR,
+	"SRV":   dnsmessage.TypeSRV,
+	"NSEC":  TypeNSEC,
+}
+
+// ParseDNSType 按名称返回记录类型，不区分大小写
+func ParseDNSType(name string) (dnsmessage.Type, error) {
+	if qtype, ok := dnsTypes[strings.ToUpper(name)]; ok {
+		return qtype, nil
+	}
+	return 0, fmt.Errorf("不支持的记录类型: %s", name)
+}
+
+// DNSTypeName 返回记录类型的名称，未知类型按 RFC 3597 表示为 TYPE<编号>
+func DNSTypeName(qtype dnsmessage.Type) string {
+	for name, t := range dnsTypes {
+		if t == qtype {
+			return name
+		}
+	}
+	return "TYPE" + strconv.Itoa(int(qtype))
+}
+
+// DNSRecord 单条DNS记录，Value 为记录数据的文本形式，与区域文件中的写法一致，
+// 如MX记录为 "10 mail.example.com"
+type DNSRecord struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	TTL   uint32 `json:"ttl"`
+	Value string `json:"value"`
+}
+
+// DNSResponse 一次查询的结果，Raw 为原始响应报文
+type DNSResponse struct {
+	Name      string      `json:"name"`
+	Type      string      `json:"type"`
+	Server    string      `json:"server"`
+	Network   string      `json:"network"`
+	RCode     string      `json:"rcode"`
+	Answers   []DNSRecord `json:"answers,omitempty"`
+	Authority []DNSRecord `json:"authority,omitempty"`
+	Raw       []byte      `json:"raw,omitempty"`
+}
+
+// DNSClient 直接收发DNS报文的客户端，用于标准解析器不支持的查询，如任意记录类型、
+// 区域传送和NSEC记录。服务器地址可以是IP或 host:port，未指定端口时使用53端口，
+// 也可以是 https:// 开头的DoH地址或 tls:// 开头的DoT地址
+type DNSClient struct {
+	Timeout time.Duration
+	// TCP 为true时总是通过TCP查询，否则先用UDP，响应被截断时改用TCP。DoH和DoT服务器忽略该设置
+	TCP bool
+	// NoRecursion 为true时查询不设置RD标志，用于直接向权威服务器查询区域中设置的记录
+	NoRecursion bool
+	// TLSConfig DoH和DoT连接使用的TLS配置，为空时使用系统根证书
+	TLSConfig *tls.Config
+}
+
+// NewDNSClient 创建新的DNS客户端
+func NewDNSClient(timeout time.Duration) *DNSClient {
+	return &DNSClient{
+		Timeout: timeout,
+	}
+}
+
+// Query 向server查询name的qtype记录。响应码不为成功时同样返回响应，由调用方判断
+func (c *DNSClient) Query(ctx context.Context, server, name string, qtype dnsmessage.Type) (*dnsmessage.Message, error) {
+	resp, _, _, err := c.query(ctx, server, name, qtype)
+	return resp, err
+}
+
+// Lookup 向server查询name的qtype记录，返回带TTL的应答和授权记录以及原始响应。
+// 响应码不为成功时同样返回结果，由调用方判断
+func (c *DNSClient) Lookup(ctx context.Context, server, name string, qtype dnsmessage.Type) (*DNSResponse, error) {
+	resp, raw, network, err := c.query(ctx, server, name, qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DNSResponse{
+		Name:    strings.TrimSuffix(name, "."),
+		Type:    DNSTypeName(qtype),
+		Server:  dnsAddress(server),
+		Network: network,
+		RCode:   rcodeName(resp.Header.RCode),
+		Raw:     raw,
+	}
+	for _, record := range resp.Answers {
+		result.Answers = append(result.Answers, newDNSRecord(record))
+	}
+	for _, record := range resp.Authorities {
+		result.Authority = append(result.Authority, newDNSRecord(record))
+	}
+	return result, nil
+}
+
+// query 发送查询，UDP响应被截断时改用TCP重新查询，返回解析后的响应、原始报文和实际使用的协议，
+// DoH和DoT服务器的协议为 https 和 tls
+func (c *DNSClient) query(ctx context.Context, server, name string, qtype dnsmessage.Type) (*dnsmessage.Message, []byte, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	query, id, err := newQuery(name, qtype, !c.NoRecursion)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	network := "udp"
+	if transport := dnsTransport(server); transport != "" {
+		network = transport
+	} else if c.TCP {
+		network = "tcp"
+	}
+	resp, raw, err := c.exchange(ctx, network, server, query, id)
+	if err == nil && network == "udp" && resp.Header.Truncated {
+		network = "tcp"
+		resp, raw, err = c.exchange(ctx, network, server, query, id)
+	}
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return resp, raw, network, nil
+}
+
+// Transfer 通过TCP向server请求zone的AXFR区域传送，返回区域内的全部记录，
+// 首尾的SOA记录也包含在内。服务器拒绝传送时返回错误，DoH服务器不支持区域传送
+func (c *DNSClient) Transfer(ctx context.Context, server, zone string) ([]dnsmessage.Resource, error) {
+	if dnsTransport(server) == TransportHTTPS {
+		return nil, fmt.Errorf("DoH服务器不支持区域传送")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	query, id, err := newQuery(zone, dnsmessage.TypeAXFR, false)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := c.dial(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := writeTCP(conn, query); err != nil {
+		return nil, err
+	}
+
+	// 区域数据以SOA记录开始并以同一SOA记录结束，可能分布在多个报文中
+	var records []dnsmessage.Resource
+	soa := 0
+	for i := 0; i < maxTransferMessages && soa < 2; i++ {
+		resp, _, err := readTCP(conn, id)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Header.RCode != dnsmessage.RCodeSuccess {
+			return nil, fmt.Errorf("区域传送被拒绝: %v", resp.Header.RCode)
+		}
+		if len(resp.Answers) == 0 {
+			return nil, fmt.Errorf("区域传送没有返回记录")
+		}
+		for _, record := range resp.Answers {
+			if record.Header.Type == dnsmessage.TypeSOA {
+				soa++
+			} else if soa == 0 {
+				return nil, fmt.Errorf("区域传送未以SOA记录开始")
+			}
+			records = append(records, record)
+		}
+	}
+	if soa < 2 {
+		return nil, fmt.Errorf("区域传送不完整")
+	}
+	return records, nil
+}
+
+// exchange 通过指定协议发送查询并等待ID匹配的响应，同时返回原始报文。
+// 除UDP外都按TCP格式收发报文
+func (c *DNSClient) exchange(ctx context.Context, network, server string, query []byte, id uint16) (*dnsmessage.Message, []byte, error) {
+	conn, err := c.dial(ctx, network, server)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	if network != "udp" {
+		if err := writeTCP(conn, query); err != nil {
+			return nil, nil, err
+		}
+		return readTCP(conn, id)
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		// 忽略ID不匹配或无法解析的报文，继续等待到超时
+		var resp dnsmessage.Message
+		if resp.Unpack(buf[:n]) == nil && resp.Header.ID == id && resp.Header.Response {
+			return &resp, append([]byte{}, buf[:n]...), nil
+		}
+	}
+}
+
+// dial 连接DNS服务器，连接的读写截止时间与上下文一致
+func (c *DNSClient) dial(ctx context.Context, network, server string) (net.Conn, error) {
+	conn, err := dialDNS(ctx, network, server, c.Timeout, c.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
+
+// newQuery 构造单个问题的查询报文，返回报文和随机ID
+func newQuery(name string, qtype dnsmessage.Type, recursion bool) ([]byte, uint16, error) {
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return nil, 0, fmt.Errorf("无效的域名 %s: %v", name, err)
+	}
+
+	var b [2]byte
+	rand.Read(b[:])
+	id := binary.BigEndian.Uint16(b[:])
+
+	var opt dnsmessage.ResourceHeader
+	opt.SetEDNS0(ednsPayloadSize, dnsmessage.RCodeSuccess, false)
+
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: recursion},
+		Questions: []dnsmessage.Question{
+			{Name: qname, Type: qtype, Class: dnsmessage.ClassINET},
+		},
+		Additionals: []dnsmessage.Resource{
+			{Header: opt, Body: &dnsmessage.OPTResource{}},
+		},
+	}
+	packed, err := msg.Pack()
+	return packed, id, err
+}
+
+// writeTCP 发送带两字节长度前缀的TCP报文
+func writeTCP(conn net.Conn, msg []byte) error {
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	copy(buf[2:], msg)
+	_, err := conn.Write(buf)
+	return err
+}
+
+// readTCP 读取一个TCP报文并校验ID，同时返回原始报文
+func readTCP(conn net.Conn, id uint16) (*dnsmessage.Message, []byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, nil, err
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf); err != nil {
+		return nil, nil, fmt.Errorf("解析DNS响应失败: %v", err)
+	}
+	if resp.Header.ID != id {
+		return nil, nil, fmt.Errorf("DNS响应ID不匹配")
+	}
+	return &resp, buf, nil
+}
+
+// newDNSRecord 把资源记录转为文本形式
+func newDNSRecord(record dnsmessage.Resource) DNSRecord {
+	result := DNSRecord{
+		Name: strings.TrimSuffix(record.Header.Name.String(), "."),
+		Type: DNSTypeName(record.Header.Type),
+		TTL:  record.Header.TTL,
+	}
+
+	switch body := record.Body.(type) {
+	case *dnsmessage.AResource:
+		result.Value = net.IP(body.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		result.Value = net.IP(body.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		result.Value = trimDot(body.CNAME)
+	case *dnsmessage.NSResource:
+		result.Value = trimDot(body.NS)
+	case *dnsmessage.PTRResource:
+		result.Value = trimDot(body.PTR)
+	case *dnsmessage.MXResource:
+		result.Value = fmt.Sprintf("%d %s", body.Pref, trimDot(body.MX))
+	case *dnsmessage.TXTResource:
+		result.Value = strings.Join(body.TXT, "")
+	case *dnsmessage.SOAResource:
+		result.Value = fmt.Sprintf("%s %s %d %d %d %d %d", trimDot(body.NS), trimDot(body.MBox),
+			body.Serial, body.Refresh, body.Retry, body.Expire, body.MinTTL)
+	case *dnsmessage.SRVResource:
+		result.Value = fmt.Sprintf("%d %d %d %s", body.Priority, body.Weight, body.Port, trimDot(body.Target))
+	case *dnsmessage.UnknownResource:
+		if body.Type == TypeCAA {
+			result.Value = formatCAA(body.Data)
+		} else {
+			result.Value = hex.EncodeToString(body.Data)
+		}
+	}
+	return result
+}
+
+// formatCAA 按 "flags tag \"value\"" 的形式格式化CAA记录，数据不合法时返回十六进制
+func formatCAA(data []byte) string {
+	if len(data) < 2 || len(data) < 2+int(data[1]) {
+		return hex.EncodeToString(data)
+	}
+	tag := string(data[2 : 2+int(data[1])])
+	return fmt.Sprintf("%d %s %q", data[0], tag, string(data[2+int(data[1]):]))
+}
+
+// rcodeName 返回响应码的名称，如 NOERROR、NXDOMAIN
+func rcodeName(rcode dnsmessage.RCode) string {
+	switch rcode {
+	case dnsmessage.RCodeSuccess:
+		return "NOERROR"
+	case dnsmessage.RCodeFormatError:
+		return "FORMERR"
+	case dnsmessage.RCodeServerFailure:
+		return "SERVFAIL"
+	case dnsmessage.RCodeNameError:
+		return "NXDOMAIN"
+	case dnsmessage.RCodeNotImplemented:
+		return "NOTIMP"
+	case dnsmessage.RCodeRefused:
+		return "REFUSED"
+	}
+	return "RCODE" + strconv.Itoa(int(rcode))
+}
+
+// trimDot 返回不带末尾点的名称
+func trimDot(name dnsmessage.Name) string {
+	return strings.TrimSuffix(name.String(), ".")
+}
+
+// ReverseName 返回IP地址用于PTR查询的反向域名，如 1.2.0.192.in-addr.arpa
+func ReverseName(ip string) (string, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return "", fmt.Errorf("无效的IP地址: %s", ip)
+	}
+
+	if v4 := addr.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", v4[3], v4[2], v4[1], v4[0]), nil
+	}
+	digits := hex.EncodeToString(addr.To16())
+	labels := make([]string, 0, len(digits)+1)
+	for i := len(digits) - 1; i >= 0; i-- {
+		labels = append(labels, string(digits[i]))
+	}
+	return strings.Join(append(labels, "ip6.arpa"), "."), nil
+}
+
+// dnsAddress 返回DNS服务器的 host:port 地址，未指定端口时使用53端口。
+// DoT地址返回 tls://host:port，默认853端口；DoH地址返回补全路径后的URL
+func dnsAddress(server string) string {
+	switch dnsTransport(server) {
+	case TransportHTTPS:
+		return normalizeDoHURL(server)
+	case TransportTLS:
+		return TransportTLS + "://" + hostPort(strings.TrimPrefix(server, TransportTLS+"://"), "853")
+	}
+	return hostPort(server, "53")
+}
+
+// hostPort 地址未指定端口时补上默认端口
+func hostPort(address, port string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(strings.Trim(address, "[]"), port)
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Marryname/WebScanner/internal/dnstest"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testZone 测试服务器上 example.com 的记录，TXT记录只能通过TCP完整返回
+var testZone = &dnstest.Zone{
+	Records: []dnsmessage.Resource{
+		dnstest.A("example.com", 300, "192.0.2.1"),
+		dnstest.AAAA("example.com", 300, "2001:db8::1"),
+		dnstest.CNAME("example.com", 60, "cdn.example.net"),
+		dnstest.RR("example.com", 3600, &dnsmessage.MXResource{Pref: 10, MX: dnsmessage.MustNewName("mail.example.com.")}),
+		dnstest.NS("example.com", 86400, "ns1.example.com"),
+		dnstest.RR("example.com", 120, &dnsmessage.TXTResource{TXT: []string{"v=spf1 ", "-all"}}),
+		dnstest.RR("example.com", 900, &dnsmessage.SOAResource{NS: dnsmessage.MustNewName("ns1.example.com."),
+			MBox: dnsmessage.MustNewName("admin.example.com."), Serial: 2024010101, Refresh: 7200, Retry: 3600, Expire: 1209600, MinTTL: 300}),
+		dnstest.RR("example.com", 3600, &dnsmessage.UnknownResource{Type: TypeCAA, Data: append([]byte{0, 5}, "issueletsencrypt.org"...)}),
+		dnstest.RR("example.com", 600, &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("www.example.com.")}),
+	},
+	TCPOnly: []dnsmessage.Type{dnsmessage.TypeTXT},
+}
+
+func TestDNSClientLookup(t *testing.T) {
+	server := dnstest.Serve(t, testZone)
+	client := NewDNSClient(time.Second)
+
+	tests := []struct {
+		qtype       string
+		want        DNSRecord
+		wantNetwork string
+	}{
+		{"A", DNSRecord{Type: "A", TTL: 300, Value: "192.0.2.1"}, "udp"},
+		{"AAAA", DNSRecord{Type: "AAAA", TTL: 300, Value: "2001:db8::1"}, "udp"},
+		{"CNAME", DNSRecord{Type: "CNAME", TTL: 60, Value: "cdn.example.net"}, "udp"},
+		{"MX", DNSRecord{Type: "MX", TTL: 3600, Value: "10 mail.example.com"}, "udp"},
+		{"NS", DNSRecord{Type: "NS", TTL: 86400, Value: "ns1.example.com"}, "udp"},
+		// UDP响应被截断，改用TCP查询
+		{"TXT", DNSRecord{Type: "TXT", TTL: 120, Value: "v=spf1 -all"}, "tcp"},
+		{"SOA", DNSRecord{Type: "SOA", TTL: 900,
+			Value: "ns1.example.com admin.example.com 2024010101 7200 3600 1209600 300"}, "udp"},
+		{"caa", DNSRecord{Type: "CAA", TTL: 3600, Value: `0 issue "letsencrypt.org"`}, "udp"},
+		{"PTR", DNSRecord{Type: "PTR", TTL: 600, Value: "www.example.com"}, "udp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.qtype, func(t *testing.T) {
+			qtype, err := ParseDNSType(tt.qtype)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Lookup(context.Background(), server, "example.com.", qtype)
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+
+			tt.want.Name = "example.com"
+			if !reflect.DeepEqual(resp.Answers, []DNSRecord{tt.want}) {
+				t.Errorf("Answers = %+v, want %+v", resp.Answers, tt.want)
+			}
+			if resp.Network != tt.wantNetwork || resp.RCode != "NOERROR" || resp.Server != server {
+				t.Errorf("Network = %s, RCode = %s, Server = %s", resp.Network, resp.RCode, resp.Server)
+			}
+
+			var raw dnsmessage.Message
+			if err := raw.Unpack(resp.Raw); err != nil || len(raw.Answers) != 1 {
+				t.Errorf("Raw 无法解析: %v", err)
+			}
+		})
+	}
+}
+
+func TestDNSClientTCP(t *testing.T) {
+	client := NewDNSClient(time.Second)
+	client.TCP = true
+
+	resp, err := client.Lookup(context.Background(), dnstest.Serve(t, testZone), "example.com", dnsmessage.TypeA)
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if resp.Network != "tcp" || len(resp.Answers) != 1 {
+		t.Errorf("Lookup() = %+v, want 通过TCP返回1条记录", resp)
+	}
+}
+
+func TestDNSClientNoRecursion(t *testing.T) {
+	server := dnstest.Serve(t, testZone)
+	client := NewDNSClient(time.Second)
+
+	// 测试服务器在响应中原样返回RD标志
+	for _, noRecursion := range []bool{false, true} {
+		client.NoRecursion = noRecursion
+		resp, err := client.Query(context.Background(), server, "example.com", dnsmessage.TypeA)
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		if resp.Header.RecursionDesired == noRecursion {
+			t.Errorf("NoRecursion = %v, RD = %v", noRecursion, resp.Header.RecursionDesired)
+		}
+	}
+}
+
+func TestParseDNSType(t *testing.T) {
+	if _, err := ParseDNSType("ANY"); err == nil {
+		t.Error("ParseDNSType() 对不支持的类型应返回错误")
+	}
+	if name := DNSTypeName(dnsmessage.Type(65)); name != "TYPE65" {
+		t.Errorf("DNSTypeName() = %s, want TYPE65", name)
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	tests := []struct {
+		ip      string
+		want    string
+		wantErr bool
+	}{
+		{"192.0.2.1", "1.2.0.192.in-addr.arpa", false},
+		{"2001:db8::1", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", false},
+		{"invalid", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ReverseName(tt.ip)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ReverseName(%s) = %s, %v, want %s", tt.ip, got, err, tt.want)
+		}
+	}
+}
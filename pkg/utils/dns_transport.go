@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DNS服务器地址的传输方式前缀，没有前缀的地址使用普通的UDP/TCP
+const (
+	// TransportHTTPS DNS-over-HTTPS (RFC 8484)，地址形如 https://dns.google/dns-query
+	TransportHTTPS = "https"
+	// TransportTLS DNS-over-TLS (RFC 7858)，地址形如 tls://1.1.1.1:853
+	TransportTLS = "tls"
+)
+
+// DoH请求和响应的媒体类型
+const dohMediaType = "application/dns-message"
+
+// DNS报文的最大长度
+const maxDNSMessageSize = 65535
+
+// dohClients 按TLS配置缓存的DoH客户端，使同一配置的查询复用连接
+var dohClients sync.Map
+
+// dnsTransport 返回服务器地址使用的加密传输方式，普通地址返回空字符串
+func dnsTransport(server string) string {
+	switch {
+	case strings.HasPrefix(server, TransportHTTPS+"://"):
+		return TransportHTTPS
+	case strings.HasPrefix(server, TransportTLS+"://"):
+		return TransportTLS
+	}
+	return ""
+}
+
+// dialDNS 按服务器地址的传输方式建立连接。DoT和DoH连接按TCP格式(两字节长度前缀)收发报文，
+// 普通地址按network指定的协议连接
+func dialDNS(ctx context.Context, network, server string, timeout time.Duration, config *tls.Config) (net.Conn, error) {
+	address := dnsAddress(server)
+	switch dnsTransport(server) {
+	case TransportHTTPS:
+		return &dohConn{ctx: ctx, url: address, client: dohClient(config)}, nil
+	case TransportTLS:
+		host := strings.TrimPrefix(address, TransportTLS+"://")
+		d := tls.Dialer{
+			NetDialer: &net.Dialer{Timeout: timeout},
+			Config:    dotConfig(host, config),
+		}
+		return d.DialContext(ctx, "tcp", host)
+	}
+	d := net.Dialer{Timeout: timeout}
+	return d.DialContext(ctx, network, address)
+}
+
+// dotConfig 返回DoT连接的TLS配置，未指定 ServerName 时使用服务器的主机名
+func dotConfig(address string, config *tls.Config) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	if config.ServerName == "" {
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			config.ServerName = host
+		}
+	}
+	return config
+}
+
+// dohClient 返回使用指定TLS配置的DoH客户端，相同配置共享同一个客户端
+func dohClient(config *tls.Config) *http.Client {
+	if client, ok := dohClients.Load(config); ok {
+		return client.(*http.Client)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	client, _ := dohClients.LoadOrStore(config, &http.Client{Transport: transport})
+	return client.(*http.Client)
+}
+
+// dohConn 把按TCP格式写入的查询通过HTTPS POST发送给DoH服务器，响应同样按TCP格式读出，
+// 使标准解析器和 DNSClient 可以像使用TCP连接一样使用DoH
+type dohConn struct {
+	ctx      context.Context
+	url      string
+	client   *http.Client
+	deadline time.Time
+
+	query    bytes.Buffer
+	response bytes.Buffer
+}
+
+// Write 缓存查询报文，读取响应时才发送
+func (c *dohConn) Write(b []byte) (int, error) {
+	return c.query.Write(b)
+}
+
+// Read 读取响应，没有未读的响应时先发送缓存的查询
+func (c *dohConn) Read(b []byte) (int, error) {
+	if c.response.Len() == 0 {
+		if c.query.Len() == 0 {
+			return 0, io.EOF
+		}
+		if err := c.roundTrip(); err != nil {
+			return 0, err
+		}
+	}
+	return c.response.Read(b)
+}
+
+// roundTrip 发送一个完整的查询报文，把响应加上长度前缀后放入读缓冲
+func (c *dohConn) roundTrip() error {
+	data := c.query.Bytes()
+	if len(data) < 2 || int(binary.BigEndian.Uint16(data)) != len(data)-2 {
+		return fmt.Errorf("不完整的DNS查询报文")
+	}
+	query := append([]byte{}, data[2:]...)
+	c.query.Reset()
+
+	ctx := c.ctx
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(query))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", dohMediaType)
+	req.Header.Set("Accept", dohMediaType)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("DoH服务器返回 %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, dohMediaType) {
+		return fmt.Errorf("DoH响应类型错误: %s", ct)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDNSMessageSize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxDNSMessageSize {
+		return fmt.Errorf("DoH响应过长")
+	}
+
+	var length [2]byte
+	binary.BigEndian.PutUint16(length[:], uint16(len(body)))
+	c.response.Write(length[:])
+	c.response.Write(body)
+	return nil
+}
+
+// Close 没有需要释放的连接，HTTP连接由客户端复用
+func (c *dohConn) Close() error {
+	return nil
+}
+
+func (c *dohConn) LocalAddr() net.Addr {
+	return dohAddr("")
+}
+
+func (c *dohConn) RemoteAddr() net.Addr {
+	return dohAddr(c.url)
+}
+
+// SetDeadline 设置请求的截止时间，读写共用
+func (c *dohConn) SetDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+func (c *dohConn) SetReadDeadline(t time.Time) error {
+	return c.SetDeadline(t)
+}
+
+func (c *dohConn) SetWriteDeadline(t time.Time) error {
+	return c.SetDeadline(t)
+}
+
+// dohAddr DoH服务器的URL
+type dohAddr string
+
+func (a dohAddr) Network() string { return TransportHTTPS }
+func (a dohAddr) String() string  { return string(a) }
+
+// normalizeDoHURL 补全DoH地址的路径，未指定路径时使用 /dns-query
+func normalizeDoHURL(server string) string {
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		return server
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/dns-query"
+	}
+	return u.String()
+}
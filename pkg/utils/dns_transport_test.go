@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Marryname/WebScanner/internal/dnstest"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serveDoH 启动按 RFC 8484 应答POST查询的测试DoH服务器，返回服务器和信任其证书的TLS配置
+func serveDoH(t *testing.T) (*httptest.Server, *tls.Config) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dns-query" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != dohMediaType {
+			http.Error(w, "unsupported", http.StatusUnsupportedMediaType)
+			return
+		}
+		query, _ := io.ReadAll(r.Body)
+		reply := dnstest.Reply(testZone, query, true)
+		if reply == nil {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", dohMediaType)
+		w.Write(reply)
+	}))
+	t.Cleanup(server.Close)
+	return server, trustConfig(server)
+}
+
+// serveDoT 使用测试服务器的证书启动DoT服务器，返回 tls:// 地址
+func serveDoT(t *testing.T, server *httptest.Server) string {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: server.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go dnstest.ServeStream(ln, testZone)
+	return "tls://" + ln.Addr().String()
+}
+
+// trustConfig 返回信任测试服务器证书的TLS配置
+func trustConfig(server *httptest.Server) *tls.Config {
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	return &tls.Config{RootCAs: roots}
+}
+
+func TestDNSClientEncrypted(t *testing.T) {
+	doh, config := serveDoH(t)
+	dot := serveDoT(t, doh)
+
+	tests := []struct {
+		name        string
+		server      string
+		wantServer  string
+		wantNetwork string
+	}{
+		{"DoH", doh.URL + "/dns-query", doh.URL + "/dns-query", "https"},
+		{"DoH默认路径", doh.URL, doh.URL + "/dns-query", "https"},
+		{"DoT", dot, dot, "tls"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewDNSClient(time.Second)
+			client.TLSConfig = config
+
+			// TXT记录只能通过TCP格式完整返回
+			resp, err := client.Lookup(context.Background(), tt.server, "example.com", dnsmessage.TypeTXT)
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			want := []DNSRecord{{Name: "example.com", Type: "TXT", TTL: 120, Value: "v=spf1 -all"}}
+			if resp.Network != tt.wantNetwork || resp.Server != tt.wantServer || len(resp.Answers) != 1 ||
+				resp.Answers[0] != want[0] {
+				t.Errorf("Lookup() = %+v", resp)
+			}
+		})
+	}
+}
+
+func TestDNSResolverEncrypted(t *testing.T) {
+	doh, config := serveDoH(t)
+	dot := serveDoT(t, doh)
+
+	for _, server := range []string{doh.URL + "/dns-query", dot} {
+		t.Run(dnsTransport(server), func(t *testing.T) {
+			resolver := NewDNSResolver(server, time.Second)
+			resolver.TLSConfig = config
+
+			ips, _, err := resolver.Resolve(context.Background(), "example.com")
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			want := map[string]bool{"192.0.2.1": true, "2001:db8::1": true}
+			if len(ips) != len(want) {
+				t.Fatalf("Resolve() = %v, want %v", ips, want)
+			}
+			for _, ip := range ips {
+				if !want[ip.String()] {
+					t.Errorf("Resolve() 返回了意外的地址 %s", ip)
+				}
+			}
+
+			hosts, err := resolver.LookupNS(context.Background(), "example.com")
+			if err != nil || len(hosts) != 1 || hosts[0] != "ns1.example.com" {
+				t.Errorf("LookupNS() = %v, %v", hosts, err)
+			}
+		})
+	}
+}
+
+func TestResolverPoolEncrypted(t *testing.T) {
+	doh, config := serveDoH(t)
+
+	pool := NewResolverPool([]string{doh.URL, serveDoT(t, doh)}, time.Second)
+	pool.SetTLSConfig(config)
+	for i := 0; i < 4; i++ {
+		if _, _, err := pool.Resolve(context.Background(), "example.com"); err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+	}
+	for _, stats := range pool.Stats() {
+		if stats.Queries != 2 || stats.ErrorRate() != 0 {
+			t.Errorf("stats = %+v, want 两个解析器各成功查询2次", stats)
+		}
+	}
+}
+
+func TestDoHErrors(t *testing.T) {
+	doh, config := serveDoH(t)
+	client := NewDNSClient(time.Second)
+
+	// 不信任服务器证书
+	if _, err := client.Lookup(context.Background(), doh.URL, "example.com", dnsmessage.TypeA); err == nil {
+		t.Error("Lookup() 在证书不受信任时应返回错误")
+	}
+
+	client.TLSConfig = config
+	if _, err := client.Lookup(context.Background(), doh.URL+"/resolve", "example.com", dnsmessage.TypeA); err == nil {
+		t.Error("Lookup() 在DoH服务器返回404时应返回错误")
+	}
+	if _, err := client.Transfer(context.Background(), doh.URL, "example.com"); err == nil {
+		t.Error("Transfer() 对DoH服务器应返回错误")
+	}
+}
+
+func TestDNSAddress(t *testing.T) {
+	tests := []struct {
+		server string
+		want   string
+	}{
+		{"8.8.8.8", "8.8.8.8:53"},
+		{"127.0.0.1:5353", "127.0.0.1:5353"},
+		{"[2001:db8::1]", "[2001:db8::1]:53"},
+		{"tls://1.1.1.1", "tls://1.1.1.1:853"},
+		{"tls://dns.google:8853", "tls://dns.google:8853"},
+		{"https://dns.google", "https://dns.google/dns-query"},
+		{"https://cloudflare-dns.com/dns-query", "https://cloudflare-dns.com/dns-query"},
+	}
+
+	for _, tt := range tests {
+		if got := dnsAddress(tt.server); got != tt.want {
+			t.Errorf("dnsAddress(%s) = %s, want %s", tt.server, got, tt.want)
+		}
+	}
+
+	if config := dotConfig(net.JoinHostPort("dns.google", "853"), nil); config.ServerName != "dns.google" {
+		t.Errorf("ServerName = %s, want dns.google", config.ServerName)
+	}
+}
@@ -2,14 +2,20 @@ package utils
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
+	"strings"
 	"time"
 )
 
 // DNSResolver 自定义DNS解析器
 type DNSResolver struct {
 	Timeout time.Duration
-	Server  string
+	// Server 解析器地址，可以是IP或 host:port，未指定端口时使用53端口；
+	// https:// 开头时使用DoH，tls:// 开头时使用DoT，默认853端口
+	Server string
+	// TLSConfig DoH和DoT连接使用的TLS配置，为空时使用系统根证书
+	TLSConfig *tls.Config
 }
 
 // NewDNSResolver 创建新的DNS解析器
@@ -20,20 +26,70 @@ func NewDNSResolver(server string, timeout time.Duration) *DNSResolver {
 	}
 }
 
+// Address 返回解析器的 host:port 地址，DoH和DoT解析器返回带协议前缀的地址
+func (r *DNSResolver) Address() string {
+	return dnsAddress(r.Server)
+}
+
+// resolver 返回把全部查询发往Server的Go解析器。DoH和DoT连接不是 net.PacketConn，
+// Go解析器会按TCP格式收发报文
+func (r *DNSResolver) resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialDNS(ctx, network, r.Server, r.Timeout, r.TLSConfig)
+		},
+	}
+}
+
 // LookupIP 查询IP地址
 func (r *DNSResolver) LookupIP(domain string) ([]net.IP, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
 	defer cancel()
 
-	resolver := &net.Resolver{
-		PreferGo: true,
-		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-			d := net.Dialer{
-				Timeout: r.Timeout,
-			}
-			return d.DialContext(ctx, network, r.Server+":53")
-		},
+	return r.resolver().LookupIP(ctx, "ip4", domain)
+}
+
+// Resolve 查询域名的IPv4和IPv6地址以及CNAME指向的规范名称，
+// 域名没有CNAME记录时cname为空。域名按绝对名称查询，不会追加搜索域
+func (r *DNSResolver) Resolve(ctx context.Context, domain string) (ips []net.IP, cname string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	fqdn := strings.TrimSuffix(domain, ".") + "."
+	resolver := r.resolver()
+	if ips, err = resolver.LookupIP(ctx, "ip", fqdn); err != nil {
+		return nil, "", err
+	}
+
+	// 地址已查到时规范名称查询失败不影响结果
+	if canonical, err := resolver.LookupCNAME(ctx, fqdn); err == nil && !strings.EqualFold(canonical, fqdn) {
+		cname = strings.TrimSuffix(canonical, ".")
 	}
+	return ips, cname, nil
+}
+
+// LookupNS 查询域名的权威服务器名称，名称不带末尾的点
+func (r *DNSResolver) LookupNS(ctx context.Context, domain string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	records, err := r.resolver().LookupNS(ctx, strings.TrimSuffix(domain, ".")+".")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(records))
+	for _, ns := range records {
+		names = append(names, strings.TrimSuffix(ns.Host, "."))
+	}
+	return names, nil
+}
+
+// LookupSRV 查询完整名称(如 _ldap._tcp.example.com)的SRV记录
+func (r *DNSResolver) LookupSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
 
-	return resolver.LookupIP(ctx, "ip4", domain)
+	_, records, err := r.resolver().LookupSRV(ctx, "", "", strings.TrimSuffix(name, ".")+".")
+	return records, err
 }
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TokenBucket 令牌桶限速器
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒产生的令牌数
+	burst  float64 // 桶容量
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket 创建令牌桶，rate为每秒请求数，burst为允许的突发数量
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait 获取一个令牌，令牌不足时等待，上下文取消时归还预占的令牌并返回错误
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	// 预占令牌，不足部分按速率换算为等待时间
+	b.tokens--
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// RateLimiter 全局及按主机的请求速率限制，供各扫描模块共享
+// 零值速率表示不限速，nil 的 RateLimiter 同样不限速
+type RateLimiter struct {
+	global   *TokenBucket
+	hostRate float64
+
+	mu    sync.Mutex
+	hosts map[string]*TokenBucket
+}
+
+// NewRateLimiter 创建速率限制器，rate为全局每秒请求数，hostRate为单个主机每秒请求数
+func NewRateLimiter(rate, hostRate float64) *RateLimiter {
+	l := &RateLimiter{
+		hostRate: hostRate,
+		hosts:    make(map[string]*TokenBucket),
+	}
+	if rate > 0 {
+		l.global = NewTokenBucket(rate, burstFor(rate))
+	}
+	return l
+}
+
+// Wait 在向host发送请求前调用，直到全局和该主机的限速都允许为止
+func (l *RateLimiter) Wait(ctx context.Context, host string) error {
+	if l == nil {
+		return nil
+	}
+
+	if l.hostRate > 0 {
+		if err := l.hostBucket(host).Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if l.global != nil {
+		return l.global.Wait(ctx)
+	}
+	return nil
+}
+
+// hostBucket 返回主机对应的令牌桶，不存在时创建
+func (l *RateLimiter) hostBucket(host string) *TokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.hosts[host]
+	if !ok {
+		bucket = NewTokenBucket(l.hostRate, burstFor(l.hostRate))
+		l.hosts[host] = bucket
+	}
+	return bucket
+}
+
+// burstFor 允许约100毫秒的突发流量
+func burstFor(rate float64) int {
+	return int(rate / 10)
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := NewTokenBucket(100, 1)
+
+	start := time.Now()
+	for i := 0; i < 11; i++ {
+		if err := bucket.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+
+	// 首个令牌立即可用，其余10个按每秒100个的速率发放
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("11 次请求耗时 %v, 期望至少 90ms", elapsed)
+	}
+}
+
+func TestTokenBucketContext(t *testing.T) {
+	bucket := NewTokenBucket(1, 1)
+	bucket.Wait(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := bucket.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Wait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRateLimiterPerHost(t *testing.T) {
+	limiter := NewRateLimiter(0, 10)
+	ctx := context.Background()
+
+	// 不同主机互不影响
+	start := time.Now()
+	for _, host := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		if err := limiter.Wait(ctx, host); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("不同主机的首次请求耗时 %v, 期望立即返回", elapsed)
+	}
+
+	// 同一主机受限速约束
+	start = time.Now()
+	limiter.Wait(ctx, "10.0.0.1")
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("同一主机的第二次请求耗时 %v, 期望约 100ms", elapsed)
+	}
+
+	// nil限速器不限速
+	var unlimited *RateLimiter
+	if err := unlimited.Wait(ctx, "10.0.0.1"); err != nil {
+		t.Errorf("nil RateLimiter Wait() error = %v", err)
+	}
+}
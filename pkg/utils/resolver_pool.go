@@ -0,0 +1,432 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// 单次查询最多尝试的解析器数量
+const maxResolverAttempts = 3
+
+// 解析器被剔除的原因
+const (
+	// EvictErrorRate 出错和超时的比例过高
+	EvictErrorRate = "error-rate"
+	// EvictUnreachable 控制查询没有响应
+	EvictUnreachable = "unreachable"
+	// EvictPoisoned 控制查询返回了错误的地址
+	EvictPoisoned = "poisoned"
+	// EvictNXDomainHijack 不存在的名称返回了地址
+	EvictNXDomainHijack = "nxdomain-hijack"
+)
+
+// errServerFailure 解析器返回了SERVFAIL或REFUSED
+var errServerFailure = errors.New("DNS服务器查询失败")
+
+// ControlQuery 验证解析器的控制查询，Addrs 为空表示该名称不应存在。
+// 以 *. 开头的名称在查询时把 * 替换为随机标签
+type ControlQuery struct {
+	Name  string
+	Addrs []string
+}
+
+// DefaultControlQueries 默认的控制查询：地址固定的公共名称和一定不存在的随机名称
+var DefaultControlQueries = []ControlQuery{
+	{Name: "one.one.one.one", Addrs: []string{"1.1.1.1", "1.0.0.1", "2606:4700:4700::1111", "2606:4700:4700::1001"}},
+	{Name: "*.example.com"},
+}
+
+// ResolverStats 单个解析器的查询统计
+type ResolverStats struct {
+	Server   string `json:"server"`
+	Queries  int    `json:"queries"`
+	Errors   int    `json:"errors"`
+	Timeouts int    `json:"timeouts"`
+	ServFail int    `json:"servfail"`
+	Evicted  bool   `json:"evicted"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ErrorRate 出错、超时和SERVFAIL占全部查询的比例
+func (s ResolverStats) ErrorRate() float64 {
+	if s.Queries == 0 {
+		return 0
+	}
+	return float64(s.Errors+s.Timeouts+s.ServFail) / float64(s.Queries)
+}
+
+// pooledResolver 池中的解析器及其统计
+type pooledResolver struct {
+	resolver *DNSResolver
+	stats    ResolverStats
+}
+
+// ResolverPool DNS解析器池，轮流使用健康的解析器查询，解析器超时、出错或返回SERVFAIL时
+// 换另一个解析器重试，并剔除出错率过高或通不过控制查询的解析器。池中至少保留一个解析器
+type ResolverPool struct {
+	mu        sync.Mutex
+	resolvers []*pooledResolver
+	client    *DNSClient
+	limiter   *RateLimiter
+	next      uint32
+
+	// 查询次数达到minQueries后，出错率超过maxErrorRate的解析器被剔除
+	maxErrorRate float64
+	minQueries   int
+}
+
+// NewResolverPool 创建解析器池，地址可以是IP、host:port 或DoH/DoT地址，重复的地址只保留一个
+func NewResolverPool(servers []string, timeout time.Duration) *ResolverPool {
+	p := &ResolverPool{
+		client:       NewDNSClient(timeout),
+		maxErrorRate: 0.5,
+		minQueries:   10,
+	}
+	seen := make(map[string]bool)
+	for _, server := range servers {
+		server = strings.TrimSpace(server)
+		if server == "" || seen[server] {
+			continue
+		}
+		seen[server] = true
+		p.resolvers = append(p.resolvers, &pooledResolver{
+			resolver: NewDNSResolver(server, timeout),
+			stats:    ResolverStats{Server: server},
+		})
+	}
+	return p
+}
+
+// LoadResolvers 读取解析器列表文件，每行一个IP或 host:port，忽略空行和 # 开头的注释
+func LoadResolvers(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取解析器文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		servers = append(servers, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("解析器文件 %s 为空", path)
+	}
+	return servers, nil
+}
+
+// SetRateLimiter 设置共享的速率限制器，按解析器地址限速
+func (p *ResolverPool) SetRateLimiter(limiter *RateLimiter) {
+	p.limiter = limiter
+}
+
+// SetTLSConfig 设置DoH和DoT解析器使用的TLS配置，需要在查询前设置
+func (p *ResolverPool) SetTLSConfig(config *tls.Config) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.client.TLSConfig = config
+	for _, r := range p.resolvers {
+		r.resolver.TLSConfig = config
+	}
+}
+
+// SetEviction 设置剔除条件，解析器查询次数达到minQueries后出错率超过maxErrorRate即被剔除，
+// maxErrorRate 不大于0时不按出错率剔除
+func (p *ResolverPool) SetEviction(maxErrorRate float64, minQueries int) {
+	p.maxErrorRate = maxErrorRate
+	if minQueries > 0 {
+		p.minQueries = minQueries
+	}
+}
+
+// Size 返回未被剔除的解析器数量
+func (p *ResolverPool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.healthy())
+}
+
+// Stats 返回全部解析器的统计，包括已剔除的解析器
+func (p *ResolverPool) Stats() []ResolverStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]ResolverStats, 0, len(p.resolvers))
+	for _, r := range p.resolvers {
+		stats = append(stats, r.stats)
+	}
+	return stats
+}
+
+// Validate 对每个解析器执行控制查询，剔除返回错误地址、劫持不存在的名称或没有响应的解析器，
+// queries 为空时使用默认控制查询。返回剩余的解析器数量，全部解析器都未通过时返回错误
+func (p *ResolverPool) Validate(ctx context.Context, queries []ControlQuery) (int, error) {
+	if len(queries) == 0 {
+		queries = DefaultControlQueries
+	}
+
+	p.mu.Lock()
+	resolvers := append([]*pooledResolver{}, p.resolvers...)
+	p.mu.Unlock()
+
+	reasons := make([]string, len(resolvers))
+	group := NewTaskGroup(ctx, len(resolvers)+1, false)
+	for i, r := range resolvers {
+		i, r := i, r
+		group.Go(func(ctx context.Context) error {
+			reasons[i] = p.check(ctx, r.resolver, queries)
+			return nil
+		})
+	}
+	group.Wait()
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, r := range resolvers {
+		if reasons[i] != "" {
+			r.stats.Evicted = true
+			r.stats.Reason = reasons[i]
+		}
+	}
+	if len(p.healthy()) == 0 {
+		return 0, fmt.Errorf("没有解析器通过控制查询")
+	}
+	return len(p.healthy()), nil
+}
+
+// check 执行控制查询，返回剔除原因，通过时返回空字符串
+func (p *ResolverPool) check(ctx context.Context, resolver *DNSResolver, queries []ControlQuery) string {
+	for _, query := range queries {
+		name := query.Name
+		if strings.HasPrefix(name, "*.") {
+			name = randomLabel() + name[1:]
+		}
+		if err := p.limiter.Wait(ctx, resolver.Address()); err != nil {
+			return ""
+		}
+
+		ips, _, err := resolver.Resolve(ctx, name)
+		var dnsErr *net.DNSError
+		notFound := errors.As(err, &dnsErr) && dnsErr.IsNotFound
+		switch {
+		case err != nil && !notFound:
+			return EvictUnreachable
+		case len(query.Addrs) == 0 && len(ips) > 0:
+			return EvictNXDomainHijack
+		case len(query.Addrs) > 0 && notFound:
+			return EvictPoisoned
+		}
+		for _, ip := range ips {
+			if !containsIP(query.Addrs, ip) {
+				return EvictPoisoned
+			}
+		}
+	}
+	return ""
+}
+
+// Resolve 查询域名的地址和CNAME，解析器出错时换下一个解析器重试，
+// 名称不存在时返回 IsNotFound 为true的 *net.DNSError
+func (p *ResolverPool) Resolve(ctx context.Context, name string) (ips []net.IP, cname string, err error) {
+	err = p.Do(ctx, func(ctx context.Context, r *DNSResolver) error {
+		ips, cname, err = r.Resolve(ctx, name)
+		return err
+	})
+	return ips, cname, err
+}
+
+// LookupNS 查询域名的权威服务器名称
+func (p *ResolverPool) LookupNS(ctx context.Context, name string) (hosts []string, err error) {
+	err = p.Do(ctx, func(ctx context.Context, r *DNSResolver) error {
+		hosts, err = r.LookupNS(ctx, name)
+		return err
+	})
+	return hosts, err
+}
+
+// LookupSRV 查询完整名称的SRV记录
+func (p *ResolverPool) LookupSRV(ctx context.Context, name string) (records []*net.SRV, err error) {
+	err = p.Do(ctx, func(ctx context.Context, r *DNSResolver) error {
+		records, err = r.LookupSRV(ctx, name)
+		return err
+	})
+	return records, err
+}
+
+// Lookup 通过DNS客户端查询任意类型的记录，返回带TTL的结果。
+// SERVFAIL和REFUSED响应换下一个解析器重试，NXDOMAIN等其他响应直接返回
+func (p *ResolverPool) Lookup(ctx context.Context, name string, qtype dnsmessage.Type) (resp *DNSResponse, err error) {
+	err = p.Do(ctx, func(ctx context.Context, r *DNSResolver) error {
+		resp, err = p.client.Lookup(ctx, r.Server, name, qtype)
+		if err == nil && (resp.RCode == "SERVFAIL" || resp.RCode == "REFUSED") {
+			return fmt.Errorf("%w: %s %s", errServerFailure, r.Address(), resp.RCode)
+		}
+		return err
+	})
+	return resp, err
+}
+
+// Do 轮流选择健康的解析器执行查询，超时、出错或SERVFAIL时换另一个解析器重试，
+// 最多尝试3个解析器。名称不存在等确定的结果直接返回。每次查询都计入解析器的统计
+func (p *ResolverPool) Do(ctx context.Context, query func(ctx context.Context, r *DNSResolver) error) error {
+	var lastErr error
+	tried := make(map[*pooledResolver]bool)
+	for attempt := 0; attempt < maxResolverAttempts; attempt++ {
+		r := p.pick(tried)
+		if r == nil {
+			break
+		}
+		tried[r] = true
+
+		if err := p.limiter.Wait(ctx, r.resolver.Address()); err != nil {
+			return err
+		}
+		err := query(ctx, r.resolver)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		outcome := classifyDNSError(err)
+		p.record(r, outcome)
+		if outcome == outcomeOK {
+			return err
+		}
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		return fmt.Errorf("没有可用的DNS解析器")
+	}
+	return lastErr
+}
+
+// pick 轮询选择一个未被剔除且本次未尝试过的解析器
+func (p *ResolverPool) pick(tried map[*pooledResolver]bool) *pooledResolver {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	healthy := p.healthy()
+	if len(healthy) == 0 {
+		return nil
+	}
+	start := int(atomic.AddUint32(&p.next, 1))
+	for i := 0; i < len(healthy); i++ {
+		if r := healthy[(start+i)%len(healthy)]; !tried[r] {
+			return r
+		}
+	}
+	return nil
+}
+
+// healthy 返回未被剔除的解析器，调用方需持有锁
+func (p *ResolverPool) healthy() []*pooledResolver {
+	var healthy []*pooledResolver
+	for _, r := range p.resolvers {
+		if !r.stats.Evicted {
+			healthy = append(healthy, r)
+		}
+	}
+	return healthy
+}
+
+// 查询结果分类
+const (
+	outcomeOK = iota
+	outcomeError
+	outcomeTimeout
+	outcomeServFail
+)
+
+// record 更新解析器的统计，出错率过高且还有其他健康的解析器时剔除该解析器
+func (p *ResolverPool) record(r *pooledResolver, outcome int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	r.stats.Queries++
+	switch outcome {
+	case outcomeError:
+		r.stats.Errors++
+	case outcomeTimeout:
+		r.stats.Timeouts++
+	case outcomeServFail:
+		r.stats.ServFail++
+	}
+
+	if p.maxErrorRate > 0 && !r.stats.Evicted && r.stats.Queries >= p.minQueries &&
+		r.stats.ErrorRate() > p.maxErrorRate && len(p.healthy()) > 1 {
+		r.stats.Evicted = true
+		r.stats.Reason = EvictErrorRate
+	}
+}
+
+// classifyDNSError 区分确定的结果(成功或名称不存在)与需要换解析器重试的错误
+func classifyDNSError(err error) int {
+	if err == nil {
+		return outcomeOK
+	}
+	if errors.Is(err, errServerFailure) {
+		return outcomeServFail
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		switch {
+		case dnsErr.IsNotFound:
+			return outcomeOK
+		case dnsErr.IsTimeout:
+			return outcomeTimeout
+		case dnsErr.Err == "server misbehaving":
+			// Go解析器把SERVFAIL报告为 server misbehaving
+			return outcomeServFail
+		}
+		return outcomeError
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return outcomeTimeout
+	}
+	return outcomeError
+}
+
+// containsIP 判断地址列表中是否包含ip
+func containsIP(addrs []string, ip net.IP) bool {
+	for _, addr := range addrs {
+		if parsed := net.ParseIP(addr); parsed != nil && parsed.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// randomLabel 生成几乎不可能真实存在的随机标签
+func randomLabel() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "rc-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Marryname/WebScanner/internal/dnstest"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// honest 只有 www.example.com 存在
+var honest = &dnstest.Zone{Records: []dnsmessage.Resource{dnstest.A("www.example.com", 60, "192.0.2.1")}}
+
+var servfail = &dnstest.Zone{RCode: dnsmessage.RCodeServerFailure}
+
+func TestResolverPoolResolve(t *testing.T) {
+	broken := dnstest.Serve(t, servfail)
+	good := dnstest.Serve(t, honest)
+
+	pool := NewResolverPool([]string{broken, good, good}, time.Second)
+	pool.SetEviction(0.5, 5)
+	if pool.Size() != 2 {
+		t.Fatalf("Size() = %d, want 2", pool.Size())
+	}
+
+	for i := 0; i < 20; i++ {
+		ips, _, err := pool.Resolve(context.Background(), "www.example.com")
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+		if len(ips) != 1 || ips[0].String() != "192.0.2.1" {
+			t.Fatalf("Resolve() = %v, want [192.0.2.1]", ips)
+		}
+	}
+
+	// 名称不存在是确定的结果，不换解析器重试
+	_, _, err := pool.Resolve(context.Background(), "missing.example.com")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("Resolve() error = %v, want not found", err)
+	}
+
+	stats := pool.Stats()
+	if !stats[0].Evicted || stats[0].Reason != EvictErrorRate || stats[0].ServFail == 0 {
+		t.Errorf("broken stats = %+v, want 因出错率被剔除", stats[0])
+	}
+	if stats[1].Evicted || stats[1].Errors+stats[1].Timeouts+stats[1].ServFail != 0 || stats[1].Queries < 20 {
+		t.Errorf("good stats = %+v", stats[1])
+	}
+	if pool.Size() != 1 {
+		t.Errorf("Size() = %d, want 1", pool.Size())
+	}
+}
+
+func TestResolverPoolKeepsLastResolver(t *testing.T) {
+	pool := NewResolverPool([]string{dnstest.Serve(t, servfail)}, time.Second)
+	pool.SetEviction(0.5, 1)
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := pool.Resolve(context.Background(), "www.example.com"); err == nil {
+			t.Fatal("Resolve() 在解析器出错时应返回错误")
+		}
+	}
+	if pool.Size() != 1 {
+		t.Errorf("Size() = %d, 最后一个解析器不应被剔除", pool.Size())
+	}
+}
+
+func TestResolverPoolLookup(t *testing.T) {
+	pool := NewResolverPool([]string{dnstest.Serve(t, servfail), dnstest.Serve(t, honest)}, time.Second)
+
+	for i := 0; i < 4; i++ {
+		resp, err := pool.Lookup(context.Background(), "www.example.com", dnsmessage.TypeA)
+		if err != nil {
+			t.Fatalf("Lookup() error = %v", err)
+		}
+		if resp.RCode != "NOERROR" || len(resp.Answers) != 1 || resp.Answers[0].TTL != 60 {
+			t.Errorf("Lookup() = %+v", resp)
+		}
+	}
+}
+
+func TestResolverPoolValidate(t *testing.T) {
+	good := dnstest.Serve(t, honest)
+	// 劫持不存在的名称
+	hijack := dnstest.Serve(t, &dnstest.Zone{Records: append([]dnsmessage.Resource{
+		dnstest.A("*.example.com", 60, "10.0.0.1"),
+	}, honest.Records...)})
+	// 返回错误的地址
+	poisoned := dnstest.Serve(t, &dnstest.Zone{Records: []dnsmessage.Resource{
+		dnstest.A("www.example.com", 60, "10.0.0.2"),
+		dnstest.A("*.example.com", 60, "10.0.0.2"),
+	}})
+	silent := dnstest.Serve(t, nil)
+
+	pool := NewResolverPool([]string{good, hijack, poisoned, silent}, 200*time.Millisecond)
+	remaining, err := pool.Validate(context.Background(), []ControlQuery{
+		{Name: "www.example.com", Addrs: []string{"192.0.2.1"}},
+		{Name: "*.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if remaining != 1 {
+		t.Errorf("Validate() = %d, want 1", remaining)
+	}
+
+	var reasons []string
+	for _, s := range pool.Stats() {
+		reasons = append(reasons, s.Reason)
+	}
+	want := []string{"", EvictNXDomainHijack, EvictPoisoned, EvictUnreachable}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("reasons = %v, want %v", reasons, want)
+	}
+
+	// 全部解析器都未通过时返回错误
+	pool = NewResolverPool([]string{poisoned}, time.Second)
+	if _, err := pool.Validate(context.Background(), []ControlQuery{{Name: "*.example.com"}}); err == nil {
+		t.Error("Validate() 在没有解析器通过时应返回错误")
+	}
+	if _, _, err := pool.Resolve(context.Background(), "www.example.com"); err == nil {
+		t.Error("Resolve() 在没有可用解析器时应返回错误")
+	}
+}
+
+func TestLoadResolvers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolvers.txt")
+	if err := os.WriteFile(path, []byte("# 公共DNS\n8.8.8.8\n\n 1.1.1.1:53 \n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadResolvers(path)
+	if err != nil {
+		t.Fatalf("LoadResolvers() error = %v", err)
+	}
+	if want := []string{"8.8.8.8", "1.1.1.1:53"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadResolvers() = %v, want %v", got, want)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	os.WriteFile(empty, []byte("# 空\n"), 0644)
+	if _, err := LoadResolvers(empty); err == nil {
+		t.Error("LoadResolvers() 对空文件应返回错误")
+	}
+}